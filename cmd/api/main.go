@@ -10,12 +10,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/cirvee/referral-backend/internal/banks"
 	"github.com/cirvee/referral-backend/internal/cache"
 	"github.com/cirvee/referral-backend/internal/config"
 	"github.com/cirvee/referral-backend/internal/database"
 	"github.com/cirvee/referral-backend/internal/handlers"
 	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
 	"github.com/cirvee/referral-backend/internal/repository"
 	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/cirvee/referral-backend/internal/utils"
@@ -79,17 +81,54 @@ func main() {
 	payoutRepo := repository.NewPayoutRepository(db)
 	clickRepo := repository.NewClickRepository(db)
 	resetTokenRepo := repository.NewResetTokenRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	verificationTokenRepo := repository.NewVerificationTokenRepository(db)
+	courseRepo := repository.NewCourseRepository(db)
+	commissionRepo := repository.NewCommissionRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	adminInviteRepo := repository.NewAdminInviteRepository(db)
+	bankChangeRepo := repository.NewBankChangeRepository(db)
 
 	// Services
-	authService := services.NewAuthService(userRepo, jwtManager)
+	accessService := services.NewAccessService(userRepo, redisCache)
+	mfaService := services.NewMFAService(mfaRepo, redisCache, cfg.MFA.EncryptionKey, cfg.MFA.Issuer)
 	emailService := services.NewEmailService(&cfg.SMTP)
+	loginSecurityService := services.NewLoginSecurityService(redisCache, loginHistoryRepo, emailService)
+	authService := services.NewAuthService(userRepo, refreshTokenRepo, accessService, mfaService, loginSecurityService, jwtManager)
+	adminService := services.NewAdminService(userRepo, roleRepo, adminInviteRepo, accessService, emailService)
+	commissionService := services.NewCommissionService(commissionRepo, courseRepo)
+	payoutProvider, err := payouts.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure payout provider: %v", err)
+	}
+	bankDirectory := banks.NewDirectory(payoutProvider, redisCache)
+	ledgerService := services.NewLedgerService(ledgerRepo, userRepo)
+	payoutService := services.NewPayoutService(payoutRepo, referralRepo, userRepo, ledgerService, payoutProvider)
+	webhookService := services.NewWebhookService(webhookRepo, payoutRepo, ledgerService)
+	referralService := services.NewReferralService(referralRepo, ledgerService)
+	bankAccountService := services.NewBankAccountService(bankChangeRepo, userRepo, payoutProvider, bankDirectory, emailService, cfg.Paystack.RejectNameMismatch, cfg.Payout.BankChangeCooldown)
 
 	// Handlers
-	authHandler := handlers.NewAuthHandler(authService, emailService, userRepo, resetTokenRepo)
-	adminHandler := handlers.NewAdminHandler(userRepo, referralRepo, payoutRepo)
-	userHandler := handlers.NewUserHandler(userRepo, referralRepo, clickRepo)
-	studentHandler := handlers.NewStudentHandler(userRepo, referralRepo, clickRepo, emailService, &cfg.Admin)
-	paystackHandler := handlers.NewPaystackHandler(&cfg.Paystack)
+	authHandler := handlers.NewAuthHandler(authService, emailService, userRepo, resetTokenRepo, verificationTokenRepo)
+	adminHandler := handlers.NewAdminHandler(userRepo, referralRepo, payoutRepo, payoutService, ledgerService, referralService, accessService)
+	userHandler := handlers.NewUserHandler(userRepo, referralRepo, clickRepo, ledgerRepo, bankAccountService)
+	studentHandler := handlers.NewStudentHandler(userRepo, referralRepo, clickRepo, courseRepo, commissionService, ledgerService, emailService, &cfg.Admin)
+	courseHandler := handlers.NewCourseHandler(courseRepo)
+	commissionHandler := handlers.NewCommissionHandler(commissionService, commissionRepo)
+	payoutHandler := handlers.NewPayoutHandler(payoutService, payoutRepo)
+	bankHandler := handlers.NewBankHandler(payoutProvider, bankDirectory)
+	webhookHandler := handlers.NewWebhookHandler(&cfg.Paystack, &cfg.Flutterwave, webhookService)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	securityHandler := handlers.NewSecurityHandler(loginHistoryRepo)
+	teamHandler := handlers.NewTeamHandler(adminService, authService)
+	userDirectoryHandler := handlers.NewUserDirectoryHandler(userRepo, referralRepo, clickRepo, payoutRepo, ledgerRepo, loginHistoryRepo, bankChangeRepo)
+	exportHandler := handlers.NewExportHandler(referralRepo, payoutRepo)
 	healthHandler := handlers.NewHealthHandler(db, redisCache)
 
 	// Seed Admin User
@@ -98,7 +137,8 @@ func main() {
 	ensureAdminExists(seedCtx, userRepo, authService, &cfg.Admin)
 
 	// Middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtManager)
+	authMiddleware := middleware.NewAuthMiddleware(jwtManager, accessService)
+	auditMiddleware := middleware.NewAuditMiddleware(auditRepo)
 	rateLimiter := middleware.NewRateLimiter(redisCache, cfg.RateLimit.Requests, cfg.RateLimit.Window)
 	authRateLimiter := middleware.NewAuthRateLimiter(redisCache, 5, time.Minute) // 5 requests per minute for auth
 
@@ -106,6 +146,11 @@ func main() {
 	r := chi.NewRouter()
 
 	// Global middleware
+	if cfg.Server.TrustProxy {
+		// Client addresses come from the proxy's headers, which clients
+		// could otherwise forge
+		r.Use(chiMiddleware.RealIP)
+	}
 	r.Use(chiMiddleware.RequestID)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
@@ -144,8 +189,23 @@ func main() {
 			r.Post("/register", authHandler.Register)
 			r.Post("/login", authHandler.Login)
 			r.Post("/refresh", authHandler.RefreshToken)
+			r.Post("/logout", authHandler.Logout)
+			r.With(authMiddleware.Authenticate).Post("/logout-all", authHandler.LogoutAll)
+			r.Post("/verify-email", authHandler.VerifyEmail)
+			r.Post("/resend-verification", authHandler.ResendVerification)
 			r.Post("/forgot-password", authHandler.ForgotPassword)
 			r.Post("/reset-password", authHandler.ResetPassword)
+			r.Post("/accept-invite", teamHandler.AcceptInvite)
+			r.Post("/confirm-bank-change", userHandler.ConfirmBankChange)
+
+			// Two-factor authentication
+			r.Post("/2fa/verify", authHandler.VerifyMFA)
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.Authenticate)
+				r.Post("/2fa/setup", mfaHandler.Setup)
+				r.Post("/2fa/enable", mfaHandler.Enable)
+				r.Post("/2fa/disable", mfaHandler.Disable)
+			})
 		})
 
 		// Student routes (public)
@@ -154,38 +214,82 @@ func main() {
 			r.Post("/track-click", studentHandler.TrackClick)
 		})
 
-		// Banks routes (public - Paystack proxy)
+		// Course catalog (public)
+		r.Get("/courses", courseHandler.ListCourses)
+
+		// Banks routes (public - payout provider proxy)
 		r.Route("/banks", func(r chi.Router) {
-			r.Get("/", paystackHandler.ListBanks)
-			r.Get("/resolve", paystackHandler.ResolveAccount)
+			r.Get("/", bankHandler.ListBanks)
+			r.Get("/resolve", bankHandler.ResolveAccount)
 		})
 
-		// Admin routes (authenticated + admin only)
+		// Provider webhooks (public, authenticated by signature)
+		r.Post("/webhooks/paystack", webhookHandler.Paystack)
+		r.Post("/webhooks/flutterwave", webhookHandler.Flutterwave)
+
+		// Admin routes (authenticated + admin only, two-factor session
+		// required). Each route needs a permission from the admin's role.
 		r.Route("/admin", func(r chi.Router) {
 			r.Use(authMiddleware.Authenticate)
 			r.Use(authMiddleware.RequireRole(models.RoleAdmin))
-
-			r.Get("/dashboard", adminHandler.GetDashboard)
-			r.Post("/users/{id}/block", adminHandler.BlockUser)
-			r.Get("/referrals", adminHandler.GetReferrals)
-			r.Post("/referrals/{id}/paid", adminHandler.MarkReferralPaid)
-			r.Patch("/referrals/{id}/status", adminHandler.UpdateReferralStatus)
-			r.Get("/referrers", adminHandler.GetReferrers)
-			r.Post("/referrers/{id}/paid", adminHandler.MarkReferrerPaid)
-			r.Get("/students", adminHandler.GetStudents)
-			r.Get("/payouts", adminHandler.GetPayouts)
-			r.Patch("/payouts/{id}", adminHandler.UpdatePayoutStatus)
+			r.Use(authMiddleware.RequireMFA)
+			r.Use(auditMiddleware.Record)
+			can := authMiddleware.RequirePermission
+
+			r.With(can(models.PermReportsRead)).Get("/dashboard", adminHandler.GetDashboard)
+			r.With(can(models.PermReportsRead)).Get("/users", userDirectoryHandler.ListUsers)
+			r.With(can(models.PermReportsRead)).Get("/users/{id}", userDirectoryHandler.GetUser)
+			r.With(can(models.PermReportsRead)).Get("/users/{id}/bank-changes", userDirectoryHandler.GetBankChanges)
+			r.With(can(models.PermUsersBlock)).Post("/users/{id}/block", adminHandler.BlockUser)
+			r.With(can(models.PermReportsRead)).Get("/referrals", adminHandler.GetReferrals)
+			r.With(can(models.PermReportsRead)).Get("/referrals/export", exportHandler.ExportReferrals)
+			r.With(can(models.PermReferralsWrite)).Post("/referrals/{id}/paid", adminHandler.MarkReferralPaid)
+			r.With(can(models.PermReferralsWrite)).Patch("/referrals/{id}/status", adminHandler.UpdateReferralStatus)
+			r.With(can(models.PermReportsRead)).Get("/referrals/{id}/history", adminHandler.GetReferralHistory)
+			r.With(can(models.PermReportsRead)).Get("/referrers", adminHandler.GetReferrers)
+			r.With(can(models.PermReportsRead)).Get("/referrers/export", exportHandler.ExportReferrers)
+			r.With(can(models.PermReferralsWrite)).Post("/referrers/{id}/paid", adminHandler.MarkReferrerPaid)
+			r.With(can(models.PermReportsRead)).Get("/students", adminHandler.GetStudents)
+			r.With(can(models.PermPayoutsRead)).Get("/payouts", adminHandler.GetPayouts)
+			r.With(can(models.PermPayoutsRead)).Get("/payouts/export", exportHandler.ExportPayouts)
+			r.With(can(models.PermPayoutsRead)).Get("/payouts/{id}", adminHandler.GetPayout)
+			r.With(can(models.PermPayoutsApprove)).Patch("/payouts/{id}", adminHandler.UpdatePayoutStatus)
+			r.With(can(models.PermPayoutsApprove)).Post("/payouts/{id}/sync-transfer", adminHandler.SyncPayoutTransfer)
+			r.With(can(models.PermReportsRead)).Get("/courses", courseHandler.AdminListCourses)
+			r.With(can(models.PermCatalogWrite)).Post("/courses", courseHandler.CreateCourse)
+			r.With(can(models.PermCatalogWrite)).Patch("/courses/{id}", courseHandler.UpdateCoursePrice)
+			r.With(can(models.PermCatalogWrite)).Post("/courses/{id}/archive", courseHandler.ArchiveCourse)
+			r.With(can(models.PermReportsRead)).Get("/commission-rules", commissionHandler.ListRules)
+			r.With(can(models.PermCatalogWrite)).Post("/commission-rules", commissionHandler.CreateRule)
+			r.With(can(models.PermCatalogWrite)).Post("/commission-rules/{id}/end", commissionHandler.EndRule)
+			r.With(can(models.PermLedgerAdjust)).Post("/ledger/adjustments", adminHandler.CreateLedgerAdjustment)
+			r.With(can(models.PermAuditRead)).Get("/audit", auditHandler.ListEvents)
+			r.With(can(models.PermAdminsManage)).Post("/admins/invite", teamHandler.InviteAdmin)
+			r.With(can(models.PermAdminsManage)).Get("/admins", teamHandler.ListAdmins)
+			r.With(can(models.PermAdminsManage)).Patch("/admins/{id}", teamHandler.UpdateAdmin)
+			r.With(can(models.PermAdminsManage)).Get("/roles", teamHandler.ListRoles)
 		})
 
-		// User routes (authenticated + user only)
+		// User routes (authenticated)
 		r.Route("/user", func(r chi.Router) {
 			r.Use(authMiddleware.Authenticate)
-			r.Use(authMiddleware.RequireRole(models.RoleUser))
 
-			r.Get("/dashboard", userHandler.GetDashboard)
-			r.Get("/referrals", userHandler.GetMyReferrals)
-			r.Get("/profile", userHandler.GetProfile)
-			r.Patch("/profile", userHandler.UpdateProfile)
+			// Available to every role
+			r.Patch("/password", authHandler.ChangePassword)
+			r.Get("/security/logins", securityHandler.GetLogins)
+
+			// Referrers only
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequireRole(models.RoleUser))
+
+				r.With(authMiddleware.RequireVerifiedEmail).Get("/dashboard", userHandler.GetDashboard)
+				r.Get("/referrals", userHandler.GetMyReferrals)
+				r.Get("/profile", userHandler.GetProfile)
+				r.Patch("/profile", userHandler.UpdateProfile)
+				r.Get("/ledger", userHandler.GetLedger)
+				r.Get("/payouts", payoutHandler.GetMyPayouts)
+				r.With(authMiddleware.RequireVerifiedEmail).Post("/payouts", payoutHandler.RequestPayout)
+			})
 		})
 	})
 
@@ -198,6 +302,11 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Keep the bank list fresh ahead of requests
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go bankDirectory.Run(backgroundCtx)
+
 	// Graceful shutdown
 	go func() {
 		log.Printf("Server starting on port %s", cfg.Server.Port)
@@ -39,7 +39,7 @@ func main() {
 		cfg.JWT.RefreshExpiry,
 	)
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, jwtManager)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
 
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -0,0 +1,270 @@
+// Package apiclient holds the HTTP plumbing shared by the payment provider
+// clients. Calls are time limited, reads are retried, and a circuit breaker
+// stops calls for a while when a provider keeps failing.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTimeout bounds a single attempt at a call when the config does
+	// not set one
+	defaultTimeout = 10 * time.Second
+	// MaxAttempts is how many times an idempotent call is tried
+	MaxAttempts = 3
+	// retryBackoff is the base delay before a retry. It doubles with each
+	// attempt and is jittered so instances do not retry in step.
+	retryBackoff = 250 * time.Millisecond
+	// breakerThreshold consecutive failures stop calls for breakerCooldown
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("unavailable after repeated failures")
+
+// APIError is returned when a provider responds with a non-2xx status or its
+// response envelope marks the call as failed
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s (status %d)", e.Provider, e.Message, e.StatusCode)
+}
+
+// Temporary reports whether the call may succeed if tried again
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// RequestError is returned when a provider could not be reached or did not
+// answer in time. Err is the underlying error, so errors.Is matches
+// context.DeadlineExceeded for timeouts. Path leaves out the query, which
+// can hold account numbers.
+type RequestError struct {
+	Provider string
+	Method   string
+	Path     string
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s: %s %s: %v", e.Provider, e.Method, e.Path, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Envelope is the part of a provider's response wrapper the client needs
+type Envelope struct {
+	OK      bool
+	Message string
+	Data    json.RawMessage
+}
+
+// DecodeFunc unwraps a provider's response body
+type DecodeFunc func(raw []byte) (*Envelope, error)
+
+// Config describes a provider API
+type Config struct {
+	// Provider names the provider in errors
+	Provider  string
+	SecretKey string
+	BaseURL   string
+	Timeout   time.Duration
+	Decode    DecodeFunc
+}
+
+// Client calls a provider API with its secret key as a bearer token. Timeout,
+// Backoff and Breaker default from NewClient and may be replaced before use.
+type Client struct {
+	provider   string
+	secretKey  string
+	baseURL    string
+	decode     DecodeFunc
+	httpClient *http.Client
+
+	Timeout time.Duration
+	Backoff time.Duration
+	Breaker *Breaker
+}
+
+func NewClient(cfg Config) *Client {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Client{
+		provider:   cfg.Provider,
+		secretKey:  cfg.SecretKey,
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		decode:     cfg.Decode,
+		httpClient: &http.Client{},
+		Timeout:    timeout,
+		Backoff:    retryBackoff,
+		Breaker:    NewBreaker(breakerThreshold, breakerCooldown),
+	}
+}
+
+// Enabled reports whether a secret key is configured
+func (c *Client) Enabled() bool {
+	return c.secretKey != ""
+}
+
+// Do calls the provider and decodes the response data into out. GET requests
+// are retried on network errors, timeouts, 429 and 5xx responses; other
+// requests are tried once, since the provider may have acted on one that
+// failed.
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	attempts := 1
+	if method == http.MethodGet {
+		attempts = MaxAttempts
+	}
+	return c.Call(ctx, method, path, body, out, attempts)
+}
+
+// Call is Do with the number of attempts given, for requests that are safe
+// to retry whatever their method
+func (c *Client) Call(ctx context.Context, method, path string, body, out interface{}, attempts int) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		if !c.Breaker.allow() {
+			return fmt.Errorf("%s: %w", c.provider, ErrCircuitOpen)
+		}
+
+		err := c.attempt(ctx, method, path, payload, out)
+		switch {
+		case ctx.Err() != nil:
+			// The caller gave up, which says nothing about the provider
+			c.Breaker.release()
+			return err
+		case temporary(err):
+			c.Breaker.record(false)
+		default:
+			c.Breaker.record(true)
+			return err
+		}
+
+		if attempt >= attempts {
+			return err
+		}
+		if !sleep(ctx, c.retryDelay(attempt)) {
+			return err
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.secretKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	// Queries can hold account numbers, so they are left out of errors
+	endpoint, _, _ := strings.Cut(path, "?")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &RequestError{Provider: c.provider, Method: method, Path: endpoint, Err: unwrapURLError(err)}
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &RequestError{Provider: c.provider, Method: method, Path: endpoint, Err: err}
+	}
+
+	env, err := c.decode(raw)
+	if err != nil {
+		return &APIError{Provider: c.provider, StatusCode: resp.StatusCode, Message: "invalid response body"}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || !env.OK {
+		message := env.Message
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{Provider: c.provider, StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// retryDelay returns how long to wait after the given failed attempt:
+// between half and all of the doubled backoff
+func (c *Client) retryDelay(attempt int) time.Duration {
+	delay := c.Backoff << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// temporary reports whether err means the provider is unreachable or
+// struggling
+func temporary(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var reqErr *RequestError
+	return errors.As(err, &reqErr)
+}
+
+// unwrapURLError drops the *url.Error wrapper, whose message repeats the
+// full URL including the query
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,87 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeTest(raw []byte) (*Envelope, error) {
+	var env struct {
+		OK      bool            `json:"ok"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	return &Envelope{OK: env.OK, Message: env.Message, Data: env.Data}, nil
+}
+
+func newTestClient(url string) *Client {
+	client := NewClient(Config{Provider: "test", SecretKey: "sk_test", BaseURL: url + "/", Decode: decodeTest})
+	client.Backoff = time.Millisecond
+	return client
+}
+
+func TestClient_Do(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer sk_test", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"ok": true, "data": {"name": "Ada"}}`))
+		case "/refused":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok": false, "message": "Invalid account"}`))
+		default:
+			w.Write([]byte(`not json`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := newTestClient(server.URL)
+
+	var out struct{ Name string }
+	require.NoError(t, client.Do(context.Background(), http.MethodGet, "/ok", nil, &out))
+	assert.Equal(t, "Ada", out.Name)
+
+	err := client.Do(context.Background(), http.MethodPost, "/refused", map[string]string{"a": "b"}, nil)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "test: Invalid account (status 400)", err.Error())
+	assert.False(t, apiErr.Temporary())
+
+	err = client.Do(context.Background(), http.MethodPost, "/garbled", nil, nil)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "invalid response body", apiErr.Message)
+}
+
+func TestClient_RequestErrorOmitsQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	err := newTestClient(server.URL).Do(context.Background(), http.MethodGet, "/resolve?account_number=0123456789", nil, nil)
+	var reqErr *RequestError
+	require.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, "/resolve", reqErr.Path)
+	assert.NotContains(t, err.Error(), "0123456789", "account numbers must not leak into errors")
+}
+
+func TestClient_RetryDelay(t *testing.T) {
+	client := newTestClient("http://localhost")
+	client.Backoff = retryBackoff
+	for attempt := 1; attempt <= 3; attempt++ {
+		full := retryBackoff << (attempt - 1)
+		for i := 0; i < 20; i++ {
+			delay := client.retryDelay(attempt)
+			assert.GreaterOrEqual(t, delay, full/2)
+			assert.LessOrEqual(t, delay, full)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Package banks keeps the list of Nigerian banks users can be paid into. The
+// list comes from the payout provider, is shared between instances through
+// Redis and falls back to a snapshot bundled with the binary when the
+// provider cannot be reached.
+package banks
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/cache"
+	"github.com/cirvee/referral-backend/internal/payouts"
+)
+
+const (
+	// cacheKeyPrefix is followed by the provider name, since bank codes
+	// differ between providers
+	cacheKeyPrefix = "banks:nigeria:v2:"
+	// defaultFreshFor is how long a fetched list is served before requests
+	// trigger a refresh
+	defaultFreshFor = 12 * time.Hour
+	// keepFor is how long Redis keeps a list, so it can still be served
+	// after the provider has been unreachable for a while
+	keepFor = 30 * 24 * time.Hour
+	// fetchTimeout bounds a single fetch from the provider
+	fetchTimeout = 5 * time.Second
+	// defaultRetryAfter spaces out fetches after one has been attempted, so
+	// an outage does not turn every request into a slow provider call
+	defaultRetryAfter = time.Minute
+	// checkEvery is how often Run looks at the age of the list
+	checkEvery = time.Hour
+)
+
+var errRetryLater = errors.New("bank list was fetched too recently")
+
+// Bank is a bank users can be paid into
+type Bank struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// list is a bank list and when it was fetched. Lists from the bundled
+// snapshot have a zero FetchedAt.
+type list struct {
+	Banks     []Bank    `json:"banks"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+//go:embed snapshot.json
+var snapshotJSON []byte
+
+// snapshot is the bank list bundled with the binary. Its codes are
+// Paystack's; Flutterwave uses the same codes for banks but not for every
+// mobile money operator, so its list only stands in until one is fetched.
+var snapshot = func() *list {
+	var banks []Bank
+	if err := json.Unmarshal(snapshotJSON, &banks); err != nil {
+		panic("banks: invalid snapshot.json: " + err.Error())
+	}
+	return &list{Banks: banks}
+}()
+
+// Directory serves the bank list. A list is only waited for when no instance
+// has fetched one yet; after that a stale list is served while a fresh one is
+// fetched in the background, and the last good list keeps being served while
+// the provider fails.
+type Directory struct {
+	provider payouts.PayoutProvider
+	cache    *cache.Cache
+
+	freshFor   time.Duration
+	retryAfter time.Duration
+
+	mu          sync.Mutex
+	current     *list
+	lastAttempt time.Time
+	refreshing  bool
+}
+
+// NewDirectory returns a Directory that fetches banks from provider and
+// shares them through cache, which may be nil
+func NewDirectory(provider payouts.PayoutProvider, cache *cache.Cache) *Directory {
+	return &Directory{
+		provider:   provider,
+		cache:      cache,
+		freshFor:   defaultFreshFor,
+		retryAfter: defaultRetryAfter,
+	}
+}
+
+// List returns the active banks, sorted by name
+func (d *Directory) List(ctx context.Context) []Bank {
+	current := d.load(ctx)
+	if current == nil {
+		fetched, err := d.refresh(ctx)
+		if err != nil {
+			if !errors.Is(err, errRetryLater) && !errors.Is(err, payouts.ErrNotConfigured) {
+				log.Printf("failed to fetch bank list, using bundled snapshot: %v", err)
+			}
+			return snapshot.Banks
+		}
+		return fetched.Banks
+	}
+
+	if d.stale(current) {
+		d.refreshInBackground()
+	}
+	return current.Banks
+}
+
+// ByCode returns the bank with code
+func (d *Directory) ByCode(ctx context.Context, code string) (Bank, bool) {
+	code = strings.TrimSpace(code)
+	for _, b := range d.List(ctx) {
+		if b.Code == code {
+			return b, true
+		}
+	}
+	return Bank{}, false
+}
+
+// ByName returns the bank called name, ignoring case and surrounding
+// whitespace
+func (d *Directory) ByName(ctx context.Context, name string) (Bank, bool) {
+	name = strings.TrimSpace(name)
+	for _, b := range d.List(ctx) {
+		if strings.EqualFold(b.Name, name) {
+			return b, true
+		}
+	}
+	return Bank{}, false
+}
+
+// Run keeps the list fresh until ctx is done, refreshing it once it is half
+// way to going stale so requests rarely see a stale list
+func (d *Directory) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkEvery)
+	defer ticker.Stop()
+
+	for {
+		current := d.load(ctx)
+		if current == nil || time.Since(current.FetchedAt) >= d.freshFor/2 {
+			if _, err := d.refresh(ctx); err != nil && !errors.Is(err, errRetryLater) && !errors.Is(err, payouts.ErrNotConfigured) {
+				log.Printf("failed to refresh bank list: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Directory) stale(l *list) bool {
+	return time.Since(l.FetchedAt) >= d.freshFor
+}
+
+// load returns the newest list held in memory or Redis, or nil if none has
+// been fetched
+func (d *Directory) load(ctx context.Context) *list {
+	d.mu.Lock()
+	current := d.current
+	d.mu.Unlock()
+
+	if current != nil && !d.stale(current) {
+		return current
+	}
+
+	// Another instance may have refreshed the shared list
+	if shared := d.loadShared(ctx); shared != nil && (current == nil || shared.FetchedAt.After(current.FetchedAt)) {
+		d.mu.Lock()
+		d.current = shared
+		d.mu.Unlock()
+		return shared
+	}
+
+	return current
+}
+
+func (d *Directory) loadShared(ctx context.Context) *list {
+	if d.cache == nil || d.provider == nil {
+		return nil
+	}
+
+	cached, err := d.cache.Get(ctx, d.cacheKey())
+	if err != nil {
+		return nil
+	}
+
+	var shared list
+	if err := json.Unmarshal([]byte(cached), &shared); err != nil || len(shared.Banks) == 0 {
+		return nil
+	}
+	return &shared
+}
+
+// refresh fetches the list from the provider and stores it in memory and
+// Redis. The fetch is detached from ctx, so a request that gives up does not
+// abandon a fetch other requests will want; only a provider failure delays
+// the next attempt.
+func (d *Directory) refresh(ctx context.Context) (*list, error) {
+	if d.provider == nil || !d.provider.Enabled() {
+		return nil, payouts.ErrNotConfigured
+	}
+
+	d.mu.Lock()
+	if time.Since(d.lastAttempt) < d.retryAfter {
+		d.mu.Unlock()
+		return nil, errRetryLater
+	}
+	d.mu.Unlock()
+
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+	defer cancel()
+
+	fetched, err := d.fetch(fetchCtx)
+	if err != nil {
+		d.mu.Lock()
+		d.lastAttempt = time.Now()
+		d.mu.Unlock()
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.current = fetched
+	d.mu.Unlock()
+
+	if d.cache != nil {
+		if listJSON, err := json.Marshal(fetched); err == nil {
+			_ = d.cache.Set(fetchCtx, d.cacheKey(), listJSON, keepFor)
+		}
+	}
+
+	return fetched, nil
+}
+
+// fetch gets the active banks from the provider, sorted by name
+func (d *Directory) fetch(ctx context.Context) (*list, error) {
+	banks, err := d.provider.ListBanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := &list{Banks: make([]Bank, 0, len(banks)), FetchedAt: time.Now()}
+	for _, b := range banks {
+		fetched.Banks = append(fetched.Banks, Bank{Name: b.Name, Code: b.Code})
+	}
+	if len(fetched.Banks) == 0 {
+		return nil, errors.New(d.provider.Name() + " returned no active banks")
+	}
+	sort.Slice(fetched.Banks, func(i, j int) bool {
+		return strings.ToLower(fetched.Banks[i].Name) < strings.ToLower(fetched.Banks[j].Name)
+	})
+	return fetched, nil
+}
+
+func (d *Directory) cacheKey() string {
+	return cacheKeyPrefix + d.provider.Name()
+}
+
+// refreshInBackground starts a refresh unless one is already running
+func (d *Directory) refreshInBackground() {
+	d.mu.Lock()
+	if d.refreshing {
+		d.mu.Unlock()
+		return
+	}
+	d.refreshing = true
+	d.mu.Unlock()
+
+	go func() {
+		defer func() {
+			d.mu.Lock()
+			d.refreshing = false
+			d.mu.Unlock()
+		}()
+
+		if _, err := d.refresh(context.Background()); err != nil && !errors.Is(err, errRetryLater) {
+			log.Printf("failed to refresh bank list, serving stale list: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,170 @@
+package banks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/paystack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePaystack serves the bank list in banks, failing while failing is set,
+// and counts the requests it gets
+type fakePaystack struct {
+	banks    atomic.Value
+	failing  atomic.Bool
+	requests atomic.Int32
+}
+
+func newFakePaystack(t *testing.T, banks []paystack.Bank) (*fakePaystack, *payouts.Paystack) {
+	fake := &fakePaystack{}
+	fake.banks.Store(banks)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if fake.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Service unavailable"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "message": "Banks retrieved", "data": fake.banks.Load()})
+	}))
+	t.Cleanup(server.Close)
+
+	return fake, payouts.NewPaystack(paystack.NewClient(&config.PaystackConfig{SecretKey: "sk_test", BaseURL: server.URL}))
+}
+
+var testBanks = []paystack.Bank{
+	{Name: "Zenith Bank", Code: "057", Active: true},
+	{Name: "Access Bank", Code: "044", Active: true},
+	{Name: "Old Bank", Code: "999", Active: false},
+	{Name: "Deleted Bank", Code: "998", Active: true, IsDeleted: true},
+}
+
+func TestDirectory_List(t *testing.T) {
+	fake, client := newFakePaystack(t, testBanks)
+	directory := NewDirectory(client, nil)
+	ctx := context.Background()
+
+	want := []Bank{{Name: "Access Bank", Code: "044"}, {Name: "Zenith Bank", Code: "057"}}
+	assert.Equal(t, want, directory.List(ctx))
+	assert.Equal(t, want, directory.List(ctx))
+	assert.Equal(t, int32(1), fake.requests.Load(), "a fresh list must be served from memory")
+}
+
+func TestDirectory_RefreshesStaleList(t *testing.T) {
+	fake, client := newFakePaystack(t, testBanks)
+	directory := NewDirectory(client, nil)
+	ctx := context.Background()
+
+	require.Len(t, directory.List(ctx), 2)
+
+	directory.freshFor = 0
+	directory.retryAfter = 0
+	fake.banks.Store([]paystack.Bank{{Name: "Wema Bank", Code: "035", Active: true}})
+
+	// The stale list is served while the new one is fetched
+	assert.Len(t, directory.List(ctx), 2)
+	assert.Eventually(t, func() bool {
+		banks := directory.List(ctx)
+		return len(banks) == 1 && banks[0].Code == "035"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDirectory_ServesStaleListWhilePaystackFails(t *testing.T) {
+	fake, client := newFakePaystack(t, testBanks)
+	directory := NewDirectory(client, nil)
+	ctx := context.Background()
+
+	require.Len(t, directory.List(ctx), 2)
+
+	directory.freshFor = 0
+	directory.retryAfter = 0
+	fake.failing.Store(true)
+
+	for i := 0; i < 3; i++ {
+		assert.Len(t, directory.List(ctx), 2)
+	}
+	assert.Eventually(t, func() bool { return fake.requests.Load() > 1 }, time.Second, 10*time.Millisecond)
+	assert.Len(t, directory.List(ctx), 2)
+}
+
+func TestDirectory_FallsBackToSnapshot(t *testing.T) {
+	fake, client := newFakePaystack(t, testBanks)
+	fake.failing.Store(true)
+	directory := NewDirectory(client, nil)
+	ctx := context.Background()
+
+	banks := directory.List(ctx)
+	assert.Equal(t, snapshot.Banks, banks)
+	assert.NotEmpty(t, banks)
+
+	// Retries are spaced out rather than made on every request
+	requests := fake.requests.Load()
+	directory.List(ctx)
+	assert.Equal(t, requests, fake.requests.Load())
+
+	unconfigured := NewDirectory(payouts.NewPaystack(paystack.NewClient(&config.PaystackConfig{})), nil)
+	assert.Equal(t, snapshot.Banks, unconfigured.List(ctx))
+}
+
+func TestDirectory_FetchOutlivesCaller(t *testing.T) {
+	fake, client := newFakePaystack(t, testBanks)
+	directory := NewDirectory(client, nil)
+
+	// A request that has already given up still fetches the list for the
+	// ones after it, and does not hold them off
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Len(t, directory.List(ctx), 2)
+	assert.Equal(t, int32(1), fake.requests.Load())
+	assert.True(t, directory.lastAttempt.IsZero(), "only provider failures delay the next fetch")
+}
+
+func TestDirectory_Lookup(t *testing.T) {
+	_, client := newFakePaystack(t, testBanks)
+	directory := NewDirectory(client, nil)
+	ctx := context.Background()
+
+	bank, ok := directory.ByCode(ctx, " 044 ")
+	require.True(t, ok)
+	assert.Equal(t, "Access Bank", bank.Name)
+
+	bank, ok = directory.ByName(ctx, "  zenith BANK ")
+	require.True(t, ok)
+	assert.Equal(t, "057", bank.Code)
+
+	_, ok = directory.ByCode(ctx, "999")
+	assert.False(t, ok, "inactive banks are not listed")
+	_, ok = directory.ByName(ctx, "Unknown Bank")
+	assert.False(t, ok)
+}
+
+func TestDirectory_Fake(t *testing.T) {
+	directory := NewDirectory(payouts.NewFake(), nil)
+
+	bank, ok := directory.ByCode(context.Background(), "058")
+	require.True(t, ok)
+	assert.Equal(t, "Guaranty Trust Bank", bank.Name)
+	assert.Len(t, directory.List(context.Background()), len(payouts.FakeBanks))
+}
+
+func TestSnapshot(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, b := range snapshot.Banks {
+		assert.NotEmpty(t, b.Name)
+		assert.NotEmpty(t, b.Code)
+		assert.False(t, seen[b.Code], "duplicate code %s", b.Code)
+		seen[b.Code] = true
+	}
+	assert.True(t, seen["058"])
+}
@@ -37,6 +37,11 @@ func (c *Cache) Set(ctx context.Context, key string, value interface{}, expirati
 	return c.client.Set(ctx, key, value, expiration).Err()
 }
 
+// SetNX sets key only if it does not exist yet, reporting whether it did
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (c *Cache) Get(ctx context.Context, key string) (string, error) {
 	return c.client.Get(ctx, key).Result()
 }
@@ -1,28 +1,37 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	Redis     RedisConfig
-	JWT       JWTConfig
-	CORS      CORSConfig
-	Admin     AdminConfig
-	RateLimit RateLimitConfig
-	Paystack  PaystackConfig
-	SMTP      SMTPConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	JWT         JWTConfig
+	CORS        CORSConfig
+	Admin       AdminConfig
+	RateLimit   RateLimitConfig
+	Paystack    PaystackConfig
+	Flutterwave FlutterwaveConfig
+	Payout      PayoutConfig
+	SMTP        SMTPConfig
+	MFA         MFAConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Env  string
+	// TrustProxy takes the client address from the X-Forwarded-For and
+	// X-Real-IP headers. Only enable it behind a proxy that sets them.
+	TrustProxy bool
 }
 
 type DatabaseConfig struct {
@@ -60,6 +69,30 @@ type RateLimitConfig struct {
 type PaystackConfig struct {
 	SecretKey string
 	BaseURL   string
+	// Timeout bounds each attempt at a Paystack API call
+	Timeout time.Duration
+	// RejectNameMismatch refuses bank accounts whose holder name does not
+	// match the user's name instead of only flagging them
+	RejectNameMismatch bool
+}
+
+type FlutterwaveConfig struct {
+	SecretKey string
+	BaseURL   string
+	// Timeout bounds each attempt at a Flutterwave API call
+	Timeout time.Duration
+	// WebhookHash is the secret hash set on the Flutterwave dashboard, which
+	// Flutterwave sends back in the verif-hash header of each webhook
+	WebhookHash string
+}
+
+type PayoutConfig struct {
+	// Provider names the payout provider banks are looked up and transfers
+	// are made with: "paystack" or "flutterwave"
+	Provider string
+	// BankChangeCooldown is how long payouts are held after a user replaces
+	// their bank details
+	BankChangeCooldown time.Duration
 }
 
 type SMTPConfig struct {
@@ -72,6 +105,12 @@ type SMTPConfig struct {
 	FrontendURL string
 }
 
+type MFAConfig struct {
+	// EncryptionKey is the 32-byte AES key TOTP secrets are encrypted with
+	EncryptionKey []byte
+	Issuer        string
+}
+
 func Load() (*Config, error) {
 	// Load .env file if exists
 	_ = godotenv.Load()
@@ -79,11 +118,15 @@ func Load() (*Config, error) {
 	accessExpiry, _ := time.ParseDuration(getEnv("JWT_ACCESS_EXPIRY", "15m"))
 	refreshExpiry, _ := time.ParseDuration(getEnv("JWT_REFRESH_EXPIRY", "168h"))
 	rateWindow, _ := time.ParseDuration(getEnv("RATE_LIMIT_WINDOW", "1m"))
+	paystackTimeout, _ := time.ParseDuration(getEnv("PAYSTACK_TIMEOUT", "10s"))
+	flutterwaveTimeout, _ := time.ParseDuration(getEnv("FLUTTERWAVE_TIMEOUT", "10s"))
+	bankChangeCooldown, _ := time.ParseDuration(getEnv("PAYOUT_BANK_CHANGE_COOLDOWN", "48h"))
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
-			Env:  getEnv("ENV", "development"),
+			Port:       getEnv("PORT", "8080"),
+			Env:        getEnv("ENV", "development"),
+			TrustProxy: getEnvBool("TRUST_PROXY", false),
 		},
 		Database: DatabaseConfig{
 			URL:      getEnv("DATABASE_URL", ""),
@@ -112,8 +155,20 @@ func Load() (*Config, error) {
 			Window:   rateWindow,
 		},
 		Paystack: PaystackConfig{
-			SecretKey: getEnv("PAYSTACK_SECRET_KEY", ""),
-			BaseURL:   getEnv("PAYSTACK_BASE_URL", "https://api.paystack.co"),
+			SecretKey:          getEnv("PAYSTACK_SECRET_KEY", ""),
+			BaseURL:            getEnv("PAYSTACK_BASE_URL", "https://api.paystack.co"),
+			Timeout:            paystackTimeout,
+			RejectNameMismatch: getEnvBool("PAYSTACK_REJECT_NAME_MISMATCH", false),
+		},
+		Flutterwave: FlutterwaveConfig{
+			SecretKey:   getEnv("FLUTTERWAVE_SECRET_KEY", ""),
+			BaseURL:     getEnv("FLUTTERWAVE_BASE_URL", "https://api.flutterwave.com"),
+			Timeout:     flutterwaveTimeout,
+			WebhookHash: getEnv("FLUTTERWAVE_WEBHOOK_HASH", ""),
+		},
+		Payout: PayoutConfig{
+			Provider:           getEnv("PAYOUT_PROVIDER", "paystack"),
+			BankChangeCooldown: bankChangeCooldown,
 		},
 		SMTP: SMTPConfig{
 			Host:        getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -124,6 +179,9 @@ func Load() (*Config, error) {
 			FromEmail:   getEnv("SMTP_FROM_EMAIL", "noreply@cirvee.com"),
 			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
 		},
+		MFA: MFAConfig{
+			Issuer: getEnv("MFA_ISSUER", "Cirvee"),
+		},
 	}
 
 	// Validate critical configuration
@@ -136,6 +194,18 @@ func Load() (*Config, error) {
 	if cfg.Database.URL == "" {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
+	// Flutterwave webhooks are refused without the hash, so failed transfers
+	// would only be noticed by a manual sync
+	if strings.EqualFold(strings.TrimSpace(cfg.Payout.Provider), "flutterwave") &&
+		cfg.Flutterwave.SecretKey != "" && cfg.Flutterwave.WebhookHash == "" {
+		return nil, fmt.Errorf("FLUTTERWAVE_WEBHOOK_HASH is required when payouts are sent through Flutterwave")
+	}
+
+	mfaKey, err := base64.StdEncoding.DecodeString(getEnv("MFA_ENCRYPTION_KEY", ""))
+	if err != nil || len(mfaKey) != 32 {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY is required and must be 32 bytes, base64 encoded")
+	}
+	cfg.MFA.EncryptionKey = mfaKey
 
 	return cfg, nil
 }
@@ -147,6 +217,15 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if result, err := strconv.ParseBool(value); err == nil {
+			return result
+		}
+	}
+	return fallback
+}
+
 func getEnvInt(key string, fallback int) int {
 	if value, exists := os.LookupEnv(key); exists {
 		var result int
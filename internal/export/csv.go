@@ -0,0 +1,56 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// utf8BOM lets Excel recognise the file as UTF-8, so names with accents
+// survive the round trip
+const utf8BOM = "\ufeff"
+
+type csvWriter struct {
+	out     io.Writer
+	w       *csv.Writer
+	started bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{out: w, w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(row []interface{}) error {
+	if !c.started {
+		c.started = true
+		if _, err := io.WriteString(c.out, utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = text(v)
+		if _, ok := v.(string); ok {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula stops spreadsheet apps from running text that looks like a
+// formula, since names and emails in exports come from users
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+// Package export writes tables as CSV or XLSX files one row at a time, so
+// large exports can be streamed straight to the client.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Format is a file format a table can be exported in
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+var ErrUnknownFormat = errors.New("format must be csv or xlsx")
+
+// timeLayout is how times are written. Exports are meant for spreadsheets,
+// which read this more readily than RFC3339.
+const timeLayout = "2006-01-02 15:04:05"
+
+// ParseFormat parses a format name, defaulting to CSV
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX:
+		return FormatXLSX, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ContentType is the MIME type of files in the format
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename names an export file, e.g. "referrals-2025-01-31.csv"
+func (f Format) Filename(name string, at time.Time) string {
+	return fmt.Sprintf("%s-%s.%s", name, at.Format("2006-01-02"), f)
+}
+
+// Writer writes a table one row at a time. Values may be strings, integers,
+// floats, bools, times (written in UTC) or nil; anything else is written
+// with fmt. Close must be called to finish the file.
+type Writer interface {
+	Write(row []interface{}) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format. sheet names the worksheet in XLSX
+// files and is ignored for CSV.
+func NewWriter(format Format, w io.Writer, sheet string) Writer {
+	if format == FormatXLSX {
+		return newXLSXWriter(w, sheet)
+	}
+	return newCSVWriter(w)
+}
+
+// text returns the text form of a cell value
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(timeLayout)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(timeLayout)
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// numeric reports whether a cell value is a number
+func numeric(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, float64:
+		return true
+	}
+	return false
+}
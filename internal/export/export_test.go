@@ -0,0 +1,153 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	for input, want := range map[string]Format{"": FormatCSV, "csv": FormatCSV, "xlsx": FormatXLSX} {
+		got, err := ParseFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseFormat("pdf"); err != ErrUnknownFormat {
+		t.Errorf("ParseFormat(pdf) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(FormatCSV, &buf, "ignored")
+
+	paidAt := time.Date(2025, 1, 31, 9, 30, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{"Name", "Amount", "Paid At"},
+		{"Ada, Countess", int64(-500), &paidAt},
+		{"=HYPERLINK(\"http://evil\")", 0, nil},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), utf8BOM) {
+		t.Error("CSV does not start with a byte order mark")
+	}
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+
+	want := [][]string{
+		{"Name", "Amount", "Paid At"},
+		{"Ada, Countess", "-500", "2025-01-31 09:30:00"},
+		{"'=HYPERLINK(\"http://evil\")", "0", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("record %d field %d = %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(FormatXLSX, &buf, "Referrals: January")
+
+	if err := w.Write([]interface{}{"Name", "Earnings", "Account Number"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write([]interface{}{"Tom & <Jerry>", int64(1500), "0123456789"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("not a zip file: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		body, ok := files[name]
+		if !ok {
+			t.Errorf("missing part %s", name)
+			continue
+		}
+		if err := xml.Unmarshal([]byte(body), new(interface{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", name, err)
+		}
+	}
+
+	if !strings.Contains(files["xl/workbook.xml"], `name="Referrals_ January"`) {
+		t.Errorf("sheet name not sanitised: %s", files["xl/workbook.xml"])
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal([]byte(files["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatalf("parsing sheet: %v", err)
+	}
+	if len(sheet.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(sheet.Rows))
+	}
+
+	cells := sheet.Rows[1].Cells
+	if cells[0].Ref != "A2" || cells[0].Inline != "Tom & <Jerry>" {
+		t.Errorf("text cell = %+v", cells[0])
+	}
+	if cells[1].Type != "" || cells[1].Value != "1500" {
+		t.Errorf("number cell = %+v", cells[1])
+	}
+	// Account numbers stay text so leading zeros survive
+	if cells[2].Type != "inlineStr" || cells[2].Inline != "0123456789" {
+		t.Errorf("account number cell = %+v", cells[2])
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package flutterwave is a minimal client for the Flutterwave v3 API
+// endpoints used to look up banks and disburse payouts through Flutterwave
+// Transfers. Calls go through apiclient, so they are time limited, reads are
+// retried, and a circuit breaker stops calls for a while when Flutterwave
+// keeps failing.
+package flutterwave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/apiclient"
+	"github.com/cirvee/referral-backend/internal/config"
+)
+
+var (
+	ErrNotConfigured    = errors.New("flutterwave secret key is not configured")
+	ErrTransferNotFound = errors.New("transfer not found by flutterwave")
+	ErrCircuitOpen      = apiclient.ErrCircuitOpen
+)
+
+// APIError is returned when Flutterwave responds with a non-2xx status or
+// status "error" in the response envelope.
+type APIError = apiclient.APIError
+
+// RequestError is returned when Flutterwave could not be reached or did not
+// answer in time.
+type RequestError = apiclient.RequestError
+
+type Client struct {
+	api *apiclient.Client
+}
+
+func NewClient(cfg *config.FlutterwaveConfig) *Client {
+	return &Client{api: apiclient.NewClient(apiclient.Config{
+		Provider:  "flutterwave",
+		SecretKey: cfg.SecretKey,
+		BaseURL:   cfg.BaseURL,
+		Timeout:   cfg.Timeout,
+		Decode:    decode,
+	})}
+}
+
+// Enabled reports whether a secret key is configured
+func (c *Client) Enabled() bool {
+	return c != nil && c.api.Enabled()
+}
+
+// Bank is a bank as listed by Flutterwave
+type Bank struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// ResolvedAccount is the account holder returned by account resolution
+type ResolvedAccount struct {
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+}
+
+// BeneficiaryRequest describes a transfer destination to save
+type BeneficiaryRequest struct {
+	AccountBank     string `json:"account_bank"`
+	AccountNumber   string `json:"account_number"`
+	BeneficiaryName string `json:"beneficiary_name"`
+	Currency        string `json:"currency"`
+}
+
+// Beneficiary is a saved transfer destination
+type Beneficiary struct {
+	ID            int64  `json:"id"`
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	FullName      string `json:"full_name"`
+	BankName      string `json:"bank_name"`
+}
+
+// TransferRequest describes a transfer from the Flutterwave balance. Unlike
+// Paystack, Amount is in naira.
+type TransferRequest struct {
+	AccountBank     string  `json:"account_bank"`
+	AccountNumber   string  `json:"account_number"`
+	Amount          float64 `json:"amount"`
+	Narration       string  `json:"narration,omitempty"`
+	Currency        string  `json:"currency"`
+	Reference       string  `json:"reference"`
+	BeneficiaryName string  `json:"beneficiary_name,omitempty"`
+	DebitCurrency   string  `json:"debit_currency,omitempty"`
+}
+
+// Transfer is Flutterwave's view of an initiated transfer. Status is one of
+// NEW, PENDING, SUCCESSFUL or FAILED.
+type Transfer struct {
+	ID              int64   `json:"id"`
+	Reference       string  `json:"reference"`
+	Status          string  `json:"status"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	CompleteMessage string  `json:"complete_message"`
+}
+
+type envelope struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ListBanks returns the Nigerian banks Flutterwave supports
+func (c *Client) ListBanks(ctx context.Context) ([]Bank, error) {
+	var banks []Bank
+	if err := c.do(ctx, http.MethodGet, "/v3/banks/NG", nil, &banks); err != nil {
+		return nil, err
+	}
+	return banks, nil
+}
+
+// ResolveAccount looks up the holder of an account number at a bank. It is
+// a POST but changes nothing, so it is retried like a read.
+func (c *Client) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*ResolvedAccount, error) {
+	if !c.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	body := map[string]string{
+		"account_number": accountNumber,
+		"account_bank":   bankCode,
+	}
+
+	account := &ResolvedAccount{}
+	if err := c.api.Call(ctx, http.MethodPost, "/v3/accounts/resolve", body, account, apiclient.MaxAttempts); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// CreateBeneficiary saves a Nigerian bank account as a transfer destination
+func (c *Client) CreateBeneficiary(ctx context.Context, name, accountNumber, bankCode string) (*Beneficiary, error) {
+	body := &BeneficiaryRequest{
+		AccountBank:     bankCode,
+		AccountNumber:   accountNumber,
+		BeneficiaryName: name,
+		Currency:        "NGN",
+	}
+
+	beneficiary := &Beneficiary{}
+	if err := c.do(ctx, http.MethodPost, "/v3/beneficiaries", body, beneficiary); err != nil {
+		return nil, err
+	}
+	return beneficiary, nil
+}
+
+// InitiateTransfer sends money to a bank account. Flutterwave rejects a
+// repeated reference, so retrying with the same reference cannot pay twice.
+func (c *Client) InitiateTransfer(ctx context.Context, req *TransferRequest) (*Transfer, error) {
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+	if req.DebitCurrency == "" {
+		req.DebitCurrency = req.Currency
+	}
+
+	transfer := &Transfer{}
+	if err := c.do(ctx, http.MethodPost, "/v3/transfers", req, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetTransfer returns the current state of the transfer with id
+func (c *Client) GetTransfer(ctx context.Context, id int64) (*Transfer, error) {
+	transfer := &Transfer{}
+	if err := c.do(ctx, http.MethodGet, "/v3/transfers/"+url.PathEscape(strconv.FormatInt(id, 10)), nil, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// TransferByReference returns the transfer sent with reference, for when
+// the ID Flutterwave gave it is not known, such as after the response to
+// InitiateTransfer was lost. It returns ErrTransferNotFound if there is no
+// such transfer.
+func (c *Client) TransferByReference(ctx context.Context, reference string) (*Transfer, error) {
+	query := url.Values{}
+	query.Set("reference", reference)
+
+	var transfers []Transfer
+	if err := c.do(ctx, http.MethodGet, "/v3/transfers?"+query.Encode(), nil, &transfers); err != nil {
+		return nil, err
+	}
+	for i := range transfers {
+		if transfers[i].Reference == reference {
+			return &transfers[i], nil
+		}
+	}
+	return nil, ErrTransferNotFound
+}
+
+// do calls Flutterwave and decodes the response data into out. GET
+// requests are retried; other requests are tried once, since Flutterwave may
+// have acted on one that failed.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	if !c.Enabled() {
+		return ErrNotConfigured
+	}
+	return c.api.Do(ctx, method, path, body, out)
+}
+
+// decode unwraps Flutterwave's {status, message, data} response envelope
+func decode(raw []byte) (*apiclient.Envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	return &apiclient.Envelope{OK: env.Status == "success", Message: env.Message, Data: env.Data}, nil
+}
@@ -0,0 +1,219 @@
+package flutterwave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/apiclient"
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixture is a recorded Flutterwave response in testdata
+type fixture struct {
+	status int
+	file   string
+}
+
+// replay serves the recorded responses for each "METHOD /path" in routes in
+// turn, repeating the last one, and counts the requests it gets. The body of
+// the latest POST is decoded into the returned map.
+func replay(t *testing.T, routes map[string][]fixture) (*Client, *atomic.Int32, *map[string]interface{}) {
+	requests := &atomic.Int32{}
+	body := &map[string]interface{}{}
+	var mu sync.Mutex
+	served := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.Header.Get("Authorization") != "Bearer FLWSECK_TEST-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		}
+
+		key := r.Method + " " + r.URL.Path
+		fixtures, ok := routes[key]
+		if !ok {
+			t.Errorf("unexpected request %s", key)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		f := fixtures[min(served[key], len(fixtures)-1)]
+		served[key]++
+		mu.Unlock()
+
+		raw, err := os.ReadFile(filepath.Join("testdata", f.file))
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		w.Write(raw)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(&config.FlutterwaveConfig{SecretKey: "FLWSECK_TEST-secret", BaseURL: server.URL + "/"})
+	client.api.Backoff = time.Millisecond
+	return client, requests, body
+}
+
+func TestClient_Enabled(t *testing.T) {
+	assert.False(t, NewClient(&config.FlutterwaveConfig{}).Enabled())
+	assert.True(t, NewClient(&config.FlutterwaveConfig{SecretKey: "FLWSECK_TEST-secret"}).Enabled())
+
+	_, err := NewClient(&config.FlutterwaveConfig{}).ListBanks(context.Background())
+	assert.ErrorIs(t, err, ErrNotConfigured)
+}
+
+func TestClient_ListBanks(t *testing.T) {
+	client, _, _ := replay(t, map[string][]fixture{"GET /v3/banks/NG": {{http.StatusOK, "banks.json"}}})
+
+	banks, err := client.ListBanks(context.Background())
+	require.NoError(t, err)
+	require.Len(t, banks, 3)
+	assert.Equal(t, Bank{ID: 177, Code: "058", Name: "GTBank Plc"}, banks[1])
+}
+
+func TestClient_ResolveAccount(t *testing.T) {
+	client, _, body := replay(t, map[string][]fixture{"POST /v3/accounts/resolve": {{http.StatusOK, "resolve_account.json"}}})
+
+	account, err := client.ResolveAccount(context.Background(), "0690000032", "044")
+	require.NoError(t, err)
+	assert.Equal(t, &ResolvedAccount{AccountNumber: "0690000032", AccountName: "Pastor Bright"}, account)
+	assert.Equal(t, map[string]interface{}{"account_number": "0690000032", "account_bank": "044"}, *body)
+}
+
+func TestClient_ResolveAccountInvalid(t *testing.T) {
+	client, requests, _ := replay(t, map[string][]fixture{"POST /v3/accounts/resolve": {{http.StatusBadRequest, "resolve_account_invalid.json"}}})
+
+	_, err := client.ResolveAccount(context.Background(), "0000000000", "044")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "Sorry, that account number is invalid, please check and try again", apiErr.Message)
+	assert.False(t, apiErr.Temporary())
+	assert.Equal(t, int32(1), requests.Load(), "client errors must not be retried")
+}
+
+func TestClient_CreateBeneficiary(t *testing.T) {
+	client, _, body := replay(t, map[string][]fixture{"POST /v3/beneficiaries": {{http.StatusOK, "beneficiary.json"}}})
+
+	beneficiary, err := client.CreateBeneficiary(context.Background(), "Pastor Bright", "0690000032", "044")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7369), beneficiary.ID)
+	assert.Equal(t, "Pastor Bright", beneficiary.FullName)
+	assert.Equal(t, "NGN", (*body)["currency"])
+}
+
+func TestClient_InitiateTransfer(t *testing.T) {
+	client, _, body := replay(t, map[string][]fixture{"POST /v3/transfers": {{http.StatusOK, "transfer.json"}}})
+
+	transfer, err := client.InitiateTransfer(context.Background(), &TransferRequest{
+		AccountBank: "044", AccountNumber: "0690000032", Amount: 10000, Reference: "payout-3f2c1a", Narration: "Referral payout",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Transfer{ID: 396456, Reference: "payout-3f2c1a", Status: "NEW", Amount: 10000, Currency: "NGN"}, transfer)
+	assert.Equal(t, "NGN", (*body)["currency"])
+	assert.Equal(t, "NGN", (*body)["debit_currency"])
+}
+
+func TestClient_GetTransfer(t *testing.T) {
+	client, _, _ := replay(t, map[string][]fixture{"GET /v3/transfers/396456": {{http.StatusOK, "transfer_failed.json"}}})
+
+	transfer, err := client.GetTransfer(context.Background(), 396456)
+	require.NoError(t, err)
+	assert.Equal(t, "FAILED", transfer.Status)
+	assert.Equal(t, "DISBURSE FAILED: Insufficient funds in customer wallet", transfer.CompleteMessage)
+}
+
+func TestClient_TransferByReference(t *testing.T) {
+	client, _, _ := replay(t, map[string][]fixture{"GET /v3/transfers": {
+		{http.StatusOK, "transfers_by_reference.json"},
+		{http.StatusOK, "transfers_empty.json"},
+	}})
+
+	transfer, err := client.TransferByReference(context.Background(), "payout-3f2c1a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(396456), transfer.ID)
+	assert.Equal(t, "SUCCESSFUL", transfer.Status)
+
+	_, err = client.TransferByReference(context.Background(), "payout-unknown")
+	assert.ErrorIs(t, err, ErrTransferNotFound)
+}
+
+func TestClient_RetriesReads(t *testing.T) {
+	client, requests, _ := replay(t, map[string][]fixture{"GET /v3/banks/NG": {
+		{http.StatusServiceUnavailable, "server_error.json"},
+		{http.StatusTooManyRequests, "server_error.json"},
+		{http.StatusOK, "banks.json"},
+	}})
+
+	banks, err := client.ListBanks(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, banks, 3)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClient_DoesNotRetryWrites(t *testing.T) {
+	client, requests, _ := replay(t, map[string][]fixture{"POST /v3/transfers": {{http.StatusBadGateway, "server_error.json"}}})
+
+	_, err := client.InitiateTransfer(context.Background(), &TransferRequest{AccountBank: "044", AccountNumber: "0690000032", Amount: 100, Reference: "payout-ref-0002"})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "a transfer Flutterwave may have queued must not be sent again")
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	client, requests, _ := replay(t, map[string][]fixture{"GET /v3/banks/NG": {{http.StatusServiceUnavailable, "server_error.json"}}})
+	client.api.Breaker = apiclient.NewBreaker(3, time.Hour)
+
+	_, err := client.ListBanks(context.Background())
+	require.Error(t, err)
+	require.Equal(t, int32(3), requests.Load())
+
+	// Open: calls fail without reaching Flutterwave
+	_, err = client.ListBanks(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClient_InvalidKey(t *testing.T) {
+	client, _, _ := replay(t, map[string][]fixture{"GET /v3/banks/NG": {{http.StatusUnauthorized, "invalid_key.json"}}})
+
+	_, err := client.ListBanks(context.Background())
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "Invalid authorization key", apiErr.Message)
+	assert.False(t, apiErr.Temporary())
+}
+
+func TestClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(&config.FlutterwaveConfig{SecretKey: "FLWSECK_TEST-secret", BaseURL: server.URL, Timeout: 20 * time.Millisecond})
+	client.api.Backoff = time.Millisecond
+
+	_, err := client.GetTransfer(context.Background(), 1)
+	var reqErr *RequestError
+	require.True(t, errors.As(err, &reqErr))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "/v3/transfers/1", reqErr.Path)
+}
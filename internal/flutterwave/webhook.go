@@ -0,0 +1,52 @@
+package flutterwave
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+)
+
+// EventTransferCompleted is sent once a transfer succeeds or fails; the
+// outcome is in the transfer's status
+const EventTransferCompleted = "transfer.completed"
+
+// Event is the envelope Flutterwave posts to the webhook URL
+type Event struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// TransferEventData is the data payload of transfer events
+type TransferEventData struct {
+	ID              int64   `json:"id"`
+	Reference       string  `json:"reference"`
+	Status          string  `json:"status"`
+	Amount          float64 `json:"amount"`
+	CompleteMessage string  `json:"complete_message"`
+}
+
+// ID returns a stable identifier for deduplicating deliveries of the same
+// event. Flutterwave events carry no id of their own, so it is made from the
+// event name and the id and status of the object.
+func (e *Event) ID() (string, error) {
+	var data struct {
+		ID     json.Number `json:"id"`
+		Status string      `json:"status"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return "", err
+	}
+	if data.ID == "" {
+		return "", fmt.Errorf("flutterwave: event %q has no data.id", e.Event)
+	}
+	return e.Event + ":" + data.ID.String() + ":" + data.Status, nil
+}
+
+// VerifyHash reports whether hash, as sent in the verif-hash header, is the
+// secret hash set on the Flutterwave dashboard
+func VerifyHash(secretHash, hash string) bool {
+	if secretHash == "" || hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secretHash), []byte(hash)) == 1
+}
@@ -0,0 +1,42 @@
+package flutterwave
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyHash(t *testing.T) {
+	assert.True(t, VerifyHash("whsec-hash", "whsec-hash"))
+	assert.False(t, VerifyHash("whsec-hash", "whsec-other"), "wrong hash")
+	assert.False(t, VerifyHash("whsec-hash", ""))
+	assert.False(t, VerifyHash("", ""), "unconfigured hash never verifies")
+}
+
+func TestEvent(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "webhook_transfer_failed.json"))
+	require.NoError(t, err)
+
+	var event Event
+	require.NoError(t, json.Unmarshal(raw, &event))
+	assert.Equal(t, EventTransferCompleted, event.Event)
+
+	id, err := event.ID()
+	require.NoError(t, err)
+	assert.Equal(t, "transfer.completed:396456:FAILED", id)
+
+	var data TransferEventData
+	require.NoError(t, json.Unmarshal(event.Data, &data))
+	assert.Equal(t, TransferEventData{
+		ID: 396456, Reference: "payout-3f2c1a", Status: "FAILED", Amount: 10000,
+		CompleteMessage: "DISBURSE FAILED: Insufficient funds in customer wallet",
+	}, data)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"event":"transfer.completed","data":{"reference":"ref"}}`), &event))
+	_, err = event.ID()
+	assert.Error(t, err)
+}
@@ -1,27 +1,47 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
 	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 type AdminHandler struct {
-	userRepo     *repository.UserRepository
-	referralRepo *repository.ReferralRepository
-	payoutRepo   *repository.PayoutRepository
+	userRepo        *repository.UserRepository
+	referralRepo    *repository.ReferralRepository
+	payoutRepo      *repository.PayoutRepository
+	payoutService   *services.PayoutService
+	ledgerService   *services.LedgerService
+	referralService *services.ReferralService
+	accessService   *services.AccessService
+	validate        *validator.Validate
 }
 
-func NewAdminHandler(userRepo *repository.UserRepository, referralRepo *repository.ReferralRepository, payoutRepo *repository.PayoutRepository) *AdminHandler {
+func NewAdminHandler(
+	userRepo *repository.UserRepository,
+	referralRepo *repository.ReferralRepository,
+	payoutRepo *repository.PayoutRepository,
+	payoutService *services.PayoutService,
+	ledgerService *services.LedgerService,
+	referralService *services.ReferralService,
+	accessService *services.AccessService,
+) *AdminHandler {
 	return &AdminHandler{
-		userRepo:     userRepo,
-		referralRepo: referralRepo,
-		payoutRepo:   payoutRepo,
+		userRepo:        userRepo,
+		referralRepo:    referralRepo,
+		payoutRepo:      payoutRepo,
+		payoutService:   payoutService,
+		ledgerService:   ledgerService,
+		referralService: referralService,
+		accessService:   accessService,
+		validate:        validator.New(),
 	}
 }
 
@@ -38,14 +58,21 @@ func NewAdminHandler(userRepo *repository.UserRepository, referralRepo *reposito
 func (h *AdminHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	totalReferrals, totalEarnings, pendingEarnings, totalPaidEarnings, paidCount, totalCodes, activeCodes, totalEnrollments, totalUniqueCourses, _ := h.referralRepo.GetTotalStats(ctx)
+	totalReferrals, _, _, _, paidCount, totalCodes, activeCodes, totalEnrollments, totalUniqueCourses, _ := h.referralRepo.GetTotalStats(ctx)
+
+	// Money figures come from the ledger so adjustments and clawbacks are reflected
+	summary, err := h.ledgerService.PlatformSummary(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load ledger balances")
+		return
+	}
 
 	stats := models.DashboardStats{
-		TotalEarnings:      totalEarnings,
+		TotalEarnings:      summary.Earned,
 		TotalReferrals:     totalReferrals,
-		TotalPayouts:       totalPaidEarnings, // Use paid earnings as total payouts
-		PendingBalance:     pendingEarnings,
-		TotalPaidEarnings:  totalPaidEarnings,
+		TotalPayouts:       summary.PaidOut,
+		PendingBalance:     summary.Balance,
+		TotalPaidEarnings:  summary.PaidOut,
 		PaidCount:          paidCount,
 		ActiveCodes:        activeCodes,
 		TotalCodes:         totalCodes,
@@ -58,28 +85,35 @@ func (h *AdminHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 
 // GetReferrals godoc
 // @Summary Get all referrals
-// @Description Get paginated list of all referrals
+// @Description Get paginated list of all referrals. q matches the student's name, email or phone and the referrer's name or code.
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param per_page query int false "Items per page" default(10)
+// @Param status query string false "Status (pending, approved, processing, paid, rejected, reversed)"
+// @Param course query string false "Course name"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Earliest creation date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest creation date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (created_at, earnings, course_price, course, status, referred_name)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
 // @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Router /api/v1/admin/referrals [get]
 func (h *AdminHandler) GetReferrals(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-	if perPage < 1 || perPage > 100 {
-		perPage = 10
+	params, err := parseListParams(r, referralStatuses, repository.ReferralSorts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	page, perPage := params.Page, params.PerPage
 
 	// Revert to ListAll for raw transactions (used by Dashboard)
-	referrals, total, err := h.referralRepo.ListAll(r.Context(), page, perPage)
+	referrals, total, err := h.referralRepo.ListAll(r.Context(), params.Filter, page, perPage)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to get referrals: "+err.Error())
 		return
@@ -101,27 +135,34 @@ func (h *AdminHandler) GetReferrals(w http.ResponseWriter, r *http.Request) {
 
 // GetReferrers godoc
 // @Summary Get all referrers with stats
-// @Description Get paginated list of referrers with aggregated stats
+// @Description Get paginated list of referrers with aggregated stats. course, from and to limit which referrals are counted; q matches the referrer's name, email or code.
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param per_page query int false "Items per page" default(10)
+// @Param status query string false "active (has referrals) or inactive"
+// @Param course query string false "Course name"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Earliest referral date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest referral date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (total_earnings, total_usage, name, joined_at)" default(total_earnings)
+// @Param order query string false "asc or desc" default(desc)
 // @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Router /api/v1/admin/referrers [get]
 func (h *AdminHandler) GetReferrers(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-	if perPage < 1 || perPage > 100 {
-		perPage = 10
+	params, err := parseListParams(r, referrerStatuses, repository.ReferrerSorts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	page, perPage := params.Page, params.PerPage
 
-	referrers, total, err := h.referralRepo.GetReferrersStats(r.Context(), page, perPage)
+	referrers, total, err := h.referralRepo.GetReferrersStats(r.Context(), params.Filter, page, perPage)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to get referrers: "+err.Error())
 		return
@@ -143,28 +184,35 @@ func (h *AdminHandler) GetReferrers(w http.ResponseWriter, r *http.Request) {
 
 // GetStudents godoc
 // @Summary Get all students (users)
-// @Description Get paginated list of all users
+// @Description Get paginated list of referred students. Takes the same filters as /admin/referrals.
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param per_page query int false "Items per page" default(10)
+// @Param status query string false "Status (pending, approved, processing, paid, rejected, reversed)"
+// @Param course query string false "Course name"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Earliest creation date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest creation date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (created_at, earnings, course_price, course, status, referred_name)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
 // @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Router /api/v1/admin/students [get]
 func (h *AdminHandler) GetStudents(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-	if perPage < 1 || perPage > 100 {
-		perPage = 10
+	params, err := parseListParams(r, referralStatuses, repository.ReferralSorts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	page, perPage := params.Page, params.PerPage
 
 	// Use ListAll to get all students (referrals)
-	students, total, err := h.referralRepo.ListAll(r.Context(), page, perPage)
+	students, total, err := h.referralRepo.ListAll(r.Context(), params.Filter, page, perPage)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to get students: "+err.Error())
 		return
@@ -186,34 +234,33 @@ func (h *AdminHandler) GetStudents(w http.ResponseWriter, r *http.Request) {
 
 // GetPayouts godoc
 // @Summary Get all payouts
-// @Description Get paginated list of all payouts with optional status filter
+// @Description Get paginated list of all payouts. referrer_id is the user paid; q matches their name, email or code, or the transfer reference.
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param per_page query int false "Items per page" default(10)
-// @Param status query string false "Filter by status (pending, approved, rejected)"
+// @Param status query string false "Filter by status (pending, approved, rejected, failed, reversed)"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Earliest request date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest request date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (created_at, amount, status, paid_at)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
 // @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Router /api/v1/admin/payouts [get]
 func (h *AdminHandler) GetPayouts(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-	if perPage < 1 || perPage > 100 {
-		perPage = 10
-	}
-
-	var statusFilter *models.PayoutStatus
-	if status := r.URL.Query().Get("status"); status != "" {
-		s := models.PayoutStatus(status)
-		statusFilter = &s
+	params, err := parseListParams(r, payoutStatuses, repository.PayoutSorts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	page, perPage := params.Page, params.PerPage
 
-	payouts, total, err := h.payoutRepo.ListAll(r.Context(), page, perPage, statusFilter)
+	payouts, total, err := h.payoutRepo.ListAll(r.Context(), params.Filter, page, perPage)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to get payouts: "+err.Error())
 		return
@@ -233,9 +280,40 @@ func (h *AdminHandler) GetPayouts(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetPayout godoc
+// @Summary Get payout details
+// @Description Get a payout together with the referrals it settles
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Payout ID"
+// @Success 200 {object} models.Payout
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/payouts/{id} [get]
+func (h *AdminHandler) GetPayout(w http.ResponseWriter, r *http.Request) {
+	payoutID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid payout ID")
+		return
+	}
+
+	payout, err := h.payoutService.GetPayout(r.Context(), payoutID)
+	if err != nil {
+		if err == repository.ErrPayoutNotFound {
+			respondError(w, http.StatusNotFound, "payout not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to get payout: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, payout)
+}
+
 // UpdatePayoutStatus godoc
 // @Summary Update payout status
-// @Description Approve or reject a payout request
+// @Description Approve or reject a pending payout. Approval marks the reserved referrals paid; rejection releases them back to pending. When a payout provider is configured, approval also initiates a bank transfer. Approval is refused (409) while the referrer's bank details are on hold after a change. If the provider refuses the transfer the payout is marked failed; if its outcome is unknown the payout stays approved until the transfer is synced (502 in both cases). A payout with a transfer that has not failed cannot be rejected (409).
 // @Tags Admin
 // @Security BearerAuth
 // @Accept json
@@ -247,6 +325,8 @@ func (h *AdminHandler) GetPayouts(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
 // @Router /api/v1/admin/payouts/{id} [patch]
 func (h *AdminHandler) UpdatePayoutStatus(w http.ResponseWriter, r *http.Request) {
 	payoutID, err := uuid.Parse(chi.URLParam(r, "id"))
@@ -263,9 +343,9 @@ func (h *AdminHandler) UpdatePayoutStatus(w http.ResponseWriter, r *http.Request
 
 	claims, _ := middleware.GetUserFromContext(r.Context())
 
-	err = h.payoutRepo.UpdateStatus(r.Context(), payoutID, req.Status, claims.UserID)
+	before, err := h.payoutRepo.GetByID(r.Context(), payoutID)
 	if err != nil {
-		if err == repository.ErrPayoutNotFound {
+		if errors.Is(err, repository.ErrPayoutNotFound) {
 			respondError(w, http.StatusNotFound, "payout not found")
 			return
 		}
@@ -273,9 +353,108 @@ func (h *AdminHandler) UpdatePayoutStatus(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	err = h.payoutService.UpdateStatus(r.Context(), payoutID, req.Status, claims.UserID)
+	// An approval is committed before its transfer is sent, so it stands even
+	// when the transfer then fails
+	committed := err == nil || errors.Is(err, services.ErrTransferRejected) || errors.Is(err, services.ErrTransferUnconfirmed)
+	if !committed {
+		switch {
+		case errors.Is(err, services.ErrInvalidPayoutStatus):
+			respondError(w, http.StatusBadRequest, "status must be approved or rejected")
+		case errors.Is(err, repository.ErrPayoutNotFound):
+			respondError(w, http.StatusNotFound, "payout not found")
+		case errors.Is(err, repository.ErrPayoutNotPending):
+			respondError(w, http.StatusConflict, "payout has already been processed")
+		case errors.Is(err, repository.ErrPayoutHasTransfer), errors.Is(err, services.ErrBankChangeHold),
+			errors.Is(err, services.ErrBankDetailsChanged):
+			respondError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrMissingBankDetails), errors.Is(err, services.ErrUnsupportedBank):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrTransferFailed):
+			respondError(w, http.StatusBadGateway, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to update payout: "+err.Error())
+		}
+		return
+	}
+
+	// Invalidate dashboard cache
+	_ = h.referralRepo.InvalidateDashboardCache(r.Context())
+
+	after, _ := h.payoutRepo.GetByID(r.Context(), payoutID)
+	middleware.Audit(r.Context(), "payout.update_status", "payout", payoutID.String(), before, after)
+
+	if err != nil {
+		respondError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "payout status updated"})
 }
 
+// SyncPayoutTransfer godoc
+// @Summary Sync payout transfer status
+// @Description Fetch the current status of a payout's bank transfer from the payout provider and apply it. A failed or reversed transfer marks the payout failed or reversed and releases its referrals back to pending. A transfer that was never confirmed as sent is sent again under the same reference.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Payout ID"
+// @Success 200 {object} models.Payout
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /api/v1/admin/payouts/{id}/sync-transfer [post]
+func (h *AdminHandler) SyncPayoutTransfer(w http.ResponseWriter, r *http.Request) {
+	payoutID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid payout ID")
+		return
+	}
+
+	before, err := h.payoutRepo.GetByID(r.Context(), payoutID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPayoutNotFound) {
+			respondError(w, http.StatusNotFound, "payout not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to sync payout transfer: "+err.Error())
+		return
+	}
+
+	payout, err := h.payoutService.SyncTransfer(r.Context(), payoutID)
+	if errors.Is(err, services.ErrTransferRejected) {
+		// The resent transfer was refused and the payout unwound
+		_ = h.referralRepo.InvalidateDashboardCache(r.Context())
+		after, _ := h.payoutRepo.GetByID(r.Context(), payoutID)
+		middleware.Audit(r.Context(), "payout.sync_transfer", "payout", payoutID.String(), before, after)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrPayoutNotFound):
+			respondError(w, http.StatusNotFound, "payout not found")
+		case errors.Is(err, services.ErrNoTransfer), errors.Is(err, services.ErrTransferProvider), errors.Is(err, services.ErrBankChangeHold):
+			respondError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrMissingBankDetails), errors.Is(err, services.ErrUnsupportedBank):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrTransferFailed), errors.Is(err, services.ErrTransferRejected), errors.Is(err, services.ErrTransferUnconfirmed):
+			respondError(w, http.StatusBadGateway, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to sync payout transfer: "+err.Error())
+		}
+		return
+	}
+
+	if payout.Status != before.Status {
+		_ = h.referralRepo.InvalidateDashboardCache(r.Context())
+		middleware.Audit(r.Context(), "payout.sync_transfer", "payout", payoutID.String(), before, payout)
+	}
+
+	respondJSON(w, http.StatusOK, payout)
+}
+
 // MarkReferrerPaid godoc
 // @Summary Mark user's referrals as paid
 // @Description Mark all pending referrals for a user as paid
@@ -295,18 +474,22 @@ func (h *AdminHandler) MarkReferrerPaid(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.referralRepo.MarkReferralsAsPaid(r.Context(), referrerID)
+	claims, _ := middleware.GetUserFromContext(r.Context())
+
+	err = h.referralService.MarkReferrerPaid(r.Context(), referrerID, claims.UserID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to mark referrals as paid: "+err.Error())
 		return
 	}
 
+	middleware.Audit(r.Context(), "referrer.mark_paid", "user", referrerID.String(), nil, nil)
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "referrals marked as paid"})
 }
 
 // BlockUser godoc
 // @Summary Block/Unblock a user
-// @Description Block or unblock a user by ID
+// @Description Block or unblock a user by ID. Blocking an admin needs the admins:manage permission, and the last active super admin cannot be blocked.
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
@@ -314,6 +497,8 @@ func (h *AdminHandler) MarkReferrerPaid(w http.ResponseWriter, r *http.Request)
 // @Param request body models.BlockUserRequest true "Block status"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/admin/users/{id}/block [post]
 func (h *AdminHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
@@ -336,7 +521,7 @@ func (h *AdminHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.userRepo.UpdateStatus(r.Context(), userID, req.IsBlocked)
+	user, err := h.userRepo.GetByID(r.Context(), userID)
 	if err != nil {
 		if err == repository.ErrUserNotFound {
 			respondError(w, http.StatusNotFound, "user not found")
@@ -346,17 +531,47 @@ func (h *AdminHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Blocking another admin is team management, not user moderation
+	if user.Role == models.RoleAdmin {
+		allowed, err := h.accessService.HasPermission(r.Context(), claims.UserID, models.PermAdminsManage)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to update user status: "+err.Error())
+			return
+		}
+		if !allowed {
+			respondError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+	}
+
+	err = h.accessService.SetBlocked(r.Context(), userID, req.IsBlocked)
+	if err != nil {
+		if err == repository.ErrUserNotFound {
+			respondError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		if err == repository.ErrLastSuperAdmin {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to update user status: "+err.Error())
+		return
+	}
+
 	action := "blocked"
 	if !req.IsBlocked {
 		action = "unblocked"
 	}
 
+	middleware.Audit(r.Context(), "user.update_block", "user", userID.String(),
+		map[string]bool{"is_blocked": user.IsBlocked}, map[string]bool{"is_blocked": req.IsBlocked})
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "user " + action})
 }
 
 // UpdateReferralStatus godoc
 // @Summary Update referral status
-// @Description Update the status of a specific referral (e.g. paid, rejected)
+// @Description Move a referral to a new status. Allowed: pending to approved, rejected or paid; approved to paid or rejected; paid to reversed. Rejecting or reversing claws the commission back.
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
@@ -367,6 +582,7 @@ func (h *AdminHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Router /api/v1/admin/referrals/{id}/status [patch]
 func (h *AdminHandler) UpdateReferralStatus(w http.ResponseWriter, r *http.Request) {
 	referralID, err := uuid.Parse(chi.URLParam(r, "id"))
@@ -381,25 +597,23 @@ func (h *AdminHandler) UpdateReferralStatus(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = h.referralRepo.UpdateStatus(r.Context(), referralID, req.Status)
+	claims, _ := middleware.GetUserFromContext(r.Context())
+
+	from, err := h.referralService.Transition(r.Context(), referralID, req.Status, claims.UserID)
 	if err != nil {
-		if err == repository.ErrReferralNotFound {
-			respondError(w, http.StatusNotFound, "referral not found")
-			return
-		}
-		respondError(w, http.StatusInternalServerError, "failed to update referral status: "+err.Error())
+		respondTransitionError(w, err)
 		return
 	}
 
-	// Invalidate dashboard cache
-	_ = h.referralRepo.InvalidateDashboardCache(r.Context())
+	middleware.Audit(r.Context(), "referral.update_status", "referral", referralID.String(),
+		map[string]string{"status": from}, map[string]string{"status": req.Status})
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "referral status updated"})
 }
 
 // MarkReferralPaid godoc
 // @Summary Mark specific referral as paid
-// @Description Mark a single pending referral as paid
+// @Description Mark a single pending or approved referral as paid
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
@@ -409,6 +623,7 @@ func (h *AdminHandler) UpdateReferralStatus(w http.ResponseWriter, r *http.Reque
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Router /api/v1/admin/referrals/{id}/paid [post]
 func (h *AdminHandler) MarkReferralPaid(w http.ResponseWriter, r *http.Request) {
 	referralID, err := uuid.Parse(chi.URLParam(r, "id"))
@@ -417,17 +632,109 @@ func (h *AdminHandler) MarkReferralPaid(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.referralRepo.MarkReferralAsPaid(r.Context(), referralID)
+	claims, _ := middleware.GetUserFromContext(r.Context())
+
+	from, err := h.referralService.Transition(r.Context(), referralID, models.ReferralStatusPaid, claims.UserID)
 	if err != nil {
-		if err == repository.ErrReferralNotFound {
-			respondError(w, http.StatusNotFound, "referral not found or already paid")
+		respondTransitionError(w, err)
+		return
+	}
+
+	middleware.Audit(r.Context(), "referral.mark_paid", "referral", referralID.String(),
+		map[string]string{"status": from}, map[string]string{"status": models.ReferralStatusPaid})
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "referral marked as paid"})
+}
+
+// GetReferralHistory godoc
+// @Summary Get referral status history
+// @Description List every status change of a referral with who made it and when, oldest first
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Referral ID"
+// @Success 200 {array} models.ReferralStatusChange
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/referrals/{id}/history [get]
+func (h *AdminHandler) GetReferralHistory(w http.ResponseWriter, r *http.Request) {
+	referralID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid referral ID")
+		return
+	}
+
+	history, err := h.referralService.History(r.Context(), referralID)
+	if err != nil {
+		if errors.Is(err, repository.ErrReferralNotFound) {
+			respondError(w, http.StatusNotFound, "referral not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to get referral history: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+// respondTransitionError maps referral state machine errors to responses
+func respondTransitionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownReferralStatus):
+		respondError(w, http.StatusBadRequest, "status must be one of: pending, approved, processing, paid, rejected, reversed")
+	case errors.Is(err, repository.ErrReferralNotFound):
+		respondError(w, http.StatusNotFound, "referral not found")
+	case errors.Is(err, services.ErrIllegalTransition):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "failed to update referral status: "+err.Error())
+	}
+}
+
+// CreateLedgerAdjustment godoc
+// @Summary Adjust a referrer's balance
+// @Description Post a manual correction or bonus to a referrer's ledger account. Positive amounts credit the referrer, negative amounts debit them.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.LedgerAdjustmentRequest true "Adjustment"
+// @Success 201 {object} models.LedgerEntry
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/ledger/adjustments [post]
+func (h *AdminHandler) CreateLedgerAdjustment(w http.ResponseWriter, r *http.Request) {
+	var req models.LedgerAdjustmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	claims, _ := middleware.GetUserFromContext(r.Context())
+
+	entry, err := h.ledgerService.Adjust(r.Context(), &req, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			respondError(w, http.StatusNotFound, "user not found")
 			return
 		}
-		respondError(w, http.StatusInternalServerError, "failed to mark referral as paid: "+err.Error())
+		respondError(w, http.StatusInternalServerError, "failed to post adjustment")
+		return
 	}
 
 	// Invalidate dashboard cache
 	_ = h.referralRepo.InvalidateDashboardCache(r.Context())
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "referral marked as paid"})
+	middleware.Audit(r.Context(), "ledger.adjust", "user", req.UserID.String(), nil, entry)
+
+	respondJSON(w, http.StatusCreated, entry)
 }
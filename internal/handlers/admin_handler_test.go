@@ -1,17 +1,23 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cirvee/referral-backend/internal/database"
 	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
 	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,8 +29,10 @@ func setupAdminHandler(t *testing.T) (*AdminHandler, *database.DB, func()) {
 	userRepo := repository.NewUserRepository(db)
 	referralRepo := repository.NewReferralRepository(db, nil)
 	payoutRepo := repository.NewPayoutRepository(db)
+	ledgerService := services.NewLedgerService(repository.NewLedgerRepository(db), userRepo)
+	payoutService := services.NewPayoutService(payoutRepo, referralRepo, userRepo, ledgerService, nil)
 
-	handler := NewAdminHandler(userRepo, referralRepo, payoutRepo)
+	handler := NewAdminHandler(userRepo, referralRepo, payoutRepo, payoutService, ledgerService, services.NewReferralService(referralRepo, ledgerService), services.NewAccessService(userRepo, nil))
 
 	return handler, db, cleanup
 }
@@ -195,3 +203,395 @@ func TestAdminHandler_UpdatePayoutStatus_NotFound(t *testing.T) {
 	// Without proper chi routing, this will fail to parse UUID from URL
 	assert.Contains(t, []int{http.StatusBadRequest, http.StatusNotFound}, rr.Code)
 }
+
+func TestAdminHandler_SyncPayoutTransfer_InvalidID(t *testing.T) {
+	handler := NewAdminHandler(nil, nil, nil, nil, nil, nil, nil)
+
+	req := withURLParam(httptest.NewRequest("POST", "/api/v1/admin/payouts/invalid-uuid/sync-transfer", nil), "id", "invalid-uuid")
+	rr := httptest.NewRecorder()
+
+	handler.SyncPayoutTransfer(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAdminHandler_SyncPayoutTransfer_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	provider := payouts.NewFake()
+	userRepo := repository.NewUserRepository(db)
+	referralRepo := repository.NewReferralRepository(db, nil)
+	payoutRepo := repository.NewPayoutRepository(db)
+	ledgerService := services.NewLedgerService(repository.NewLedgerRepository(db), userRepo)
+	payoutService := services.NewPayoutService(payoutRepo, referralRepo, userRepo, ledgerService, provider)
+	handler := NewAdminHandler(userRepo, referralRepo, payoutRepo, payoutService, ledgerService, services.NewReferralService(referralRepo, ledgerService), services.NewAccessService(userRepo, nil))
+
+	response, err := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager).Register(ctx, &models.RegisterRequest{
+		Email:    "sync@example.com",
+		Password: "password123",
+		Name:     "Sync User",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	user := response.User
+	user.BankCode = "058"
+	user.BankName = "Guaranty Trust Bank"
+	user.AccountNumber = "0123456789"
+	user.AccountName = "SYNC USER"
+	require.NoError(t, userRepo.Update(ctx, &user))
+
+	referral := &models.Referral{
+		ID:            uuid.New(),
+		ReferrerID:    &user.ID,
+		ReferredName:  "Student",
+		ReferredEmail: "student@example.com",
+		ReferredPhone: "08000000000",
+		Course:        "Web Development",
+		CoursePrice:   750000,
+		Earnings:      10000,
+		Status:        "pending",
+	}
+	require.NoError(t, referralRepo.CreateWithHook(ctx, referral, ledgerService.AccrueCommission(referral)))
+
+	payout, err := payoutService.RequestPayout(ctx, user.ID, 10000)
+	require.NoError(t, err)
+
+	sync := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/admin/payouts/"+payout.ID.String()+"/sync-transfer", nil)
+		req = withURLParam(req.WithContext(createUserContext(user.ID, "admin")), "id", payout.ID.String())
+		rr := httptest.NewRecorder()
+		handler.SyncPayoutTransfer(rr, req)
+		return rr
+	}
+
+	// Nothing to sync before the payout is approved
+	require.Equal(t, http.StatusConflict, sync().Code)
+
+	// The transfer is accepted but its response times out; the approval
+	// finds the transfer by its reference instead of failing
+	provider.LoseNextResponse(context.DeadlineExceeded)
+	require.NoError(t, payoutService.UpdateStatus(ctx, payout.ID, models.PayoutStatusApproved, user.ID))
+
+	transfers := provider.Transfers()
+	require.Len(t, transfers, 1)
+	assert.Equal(t, payouts.TransferRequest{
+		Amount: 1000000, RecipientCode: transfers[0].RecipientCode, AccountNumber: "0123456789", AccountName: "SYNC USER",
+		BankCode: "058", Reference: payout.ID.String(), Reason: "Cirvee referral payout",
+	}, transfers[0])
+	assert.NotEmpty(t, transfers[0].RecipientCode)
+
+	recipient, err := userRepo.GetPayoutRecipient(ctx, user.ID, provider.Name())
+	require.NoError(t, err)
+	assert.Equal(t, transfers[0].RecipientCode, recipient)
+	other, err := userRepo.GetPayoutRecipient(ctx, user.ID, payouts.ProviderPaystack)
+	require.NoError(t, err)
+	assert.Empty(t, other, "recipients are not shared between providers")
+
+	// A still-pending transfer leaves the payout approved
+	rr := sync()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var synced models.Payout
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &synced))
+	assert.Equal(t, models.PayoutStatusApproved, synced.Status)
+	require.NotNil(t, synced.TransferProvider)
+	assert.Equal(t, payouts.ProviderFake, *synced.TransferProvider)
+
+	require.NoError(t, provider.SetTransferStatus(payout.ID.String(), payouts.TransferFailed))
+
+	rr = sync()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &synced))
+	assert.Equal(t, models.PayoutStatusFailed, synced.Status)
+	require.NotNil(t, synced.TransferStatus)
+	assert.Equal(t, payouts.TransferFailed, *synced.TransferStatus)
+
+	ref, err := referralRepo.GetByID(ctx, referral.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", ref.Status)
+
+	// Syncing again changes nothing
+	rr = sync()
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &synced))
+	assert.Equal(t, models.PayoutStatusFailed, synced.Status)
+
+	// Transfers sent with another provider cannot be synced with this one
+	_, err = db.Pool.Exec(ctx, `UPDATE payouts SET transfer_provider = $2 WHERE id = $1`, payout.ID, payouts.ProviderPaystack)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, sync().Code)
+}
+
+func TestAdminHandler_CreateLedgerAdjustment_Validation(t *testing.T) {
+	handler := NewAdminHandler(nil, nil, nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"missing user", `{"amount": 5000, "reason": "Bonus"}`},
+		{"zero amount", `{"user_id": "` + uuid.New().String() + `", "amount": 0, "reason": "Bonus"}`},
+		{"missing reason", `{"user_id": "` + uuid.New().String() + `", "amount": 5000}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/admin/ledger/adjustments", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			handler.CreateLedgerAdjustment(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestAdminHandler_CreateLedgerAdjustment_Integration(t *testing.T) {
+	handler, db, cleanup := setupAdminHandler(t)
+	defer cleanup()
+
+	userRepo := repository.NewUserRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+	response, err := authService.Register(context.Background(), &models.RegisterRequest{
+		Email:    "ledger@example.com",
+		Password: "password123",
+		Name:     "Ledger User",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+
+	adjust := func(amount int64) int {
+		body, _ := json.Marshal(models.LedgerAdjustmentRequest{UserID: response.User.ID, Amount: amount, Reason: "Launch bonus"})
+		req := httptest.NewRequest("POST", "/api/v1/admin/ledger/adjustments", bytes.NewReader(body))
+		req = req.WithContext(createUserContext(response.User.ID, "admin"))
+		rr := httptest.NewRecorder()
+		handler.CreateLedgerAdjustment(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusCreated, adjust(5000))
+	require.Equal(t, http.StatusCreated, adjust(-2000))
+
+	summary, err := handler.ledgerService.UserSummary(context.Background(), response.User.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3000), summary.Earned)
+	assert.Equal(t, int64(3000), summary.Balance)
+	assert.Equal(t, int64(0), summary.PaidOut)
+}
+
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestAdminHandler_UpdateReferralStatus_Transitions_Integration(t *testing.T) {
+	handler, db, cleanup := setupAdminHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	userRepo := repository.NewUserRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+	response, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "transitions@example.com",
+		Password: "password123",
+		Name:     "Transition User",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	referrerID := response.User.ID
+
+	referral := &models.Referral{
+		ID:            uuid.New(),
+		ReferrerID:    &referrerID,
+		ReferredName:  "Student",
+		ReferredEmail: "student@example.com",
+		ReferredPhone: "08000000000",
+		Course:        "Web Development",
+		CoursePrice:   750000,
+		Earnings:      10000,
+		Status:        models.ReferralStatusPending,
+	}
+	require.NoError(t, repository.NewReferralRepository(db, nil).Create(ctx, referral))
+
+	transition := func(status string) int {
+		body, _ := json.Marshal(models.UpdateReferralStatusRequest{Status: status})
+		req := httptest.NewRequest("PATCH", "/api/v1/admin/referrals/"+referral.ID.String()+"/status", bytes.NewReader(body))
+		req = req.WithContext(createUserContext(referrerID, "admin"))
+		req = withURLParam(req, "id", referral.ID.String())
+		rr := httptest.NewRecorder()
+		handler.UpdateReferralStatus(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, transition("archived"))
+	assert.Equal(t, http.StatusOK, transition(models.ReferralStatusApproved))
+	assert.Equal(t, http.StatusConflict, transition(models.ReferralStatusPending), "approved cannot go back to pending")
+	assert.Equal(t, http.StatusOK, transition(models.ReferralStatusPaid))
+	assert.Equal(t, http.StatusConflict, transition(models.ReferralStatusRejected), "paid cannot be rejected")
+	assert.Equal(t, http.StatusOK, transition(models.ReferralStatusReversed))
+
+	req := httptest.NewRequest("GET", "/api/v1/admin/referrals/"+referral.ID.String()+"/history", nil)
+	req = withURLParam(req, "id", referral.ID.String())
+	rr := httptest.NewRecorder()
+	handler.GetReferralHistory(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var history []models.ReferralStatusChange
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &history))
+	require.Len(t, history, 3)
+	assert.Equal(t, models.ReferralStatusPending, history[0].FromStatus)
+	assert.Equal(t, models.ReferralStatusApproved, history[0].ToStatus)
+	assert.Equal(t, models.ReferralStatusReversed, history[2].ToStatus)
+	require.NotNil(t, history[0].ChangedBy)
+	assert.Equal(t, referrerID, *history[0].ChangedBy)
+}
+
+func TestAdminPermissions_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	userRepo := repository.NewUserRepository(db)
+	accessService := services.NewAccessService(userRepo, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), accessService, nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+
+	admin, err := authService.CreateAdmin(ctx, "super@example.com", "password123", "Super Admin")
+	require.NoError(t, err)
+	require.NotNil(t, admin.AdminRole)
+	assert.Equal(t, models.AdminRoleSuperAdmin, *admin.AdminRole)
+
+	referrer, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "referrer@example.com",
+		Password: "password123",
+		Name:     "Referrer",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+
+	for _, permission := range []models.Permission{models.PermPayoutsApprove, models.PermAuditRead, models.PermUsersBlock} {
+		allowed, err := accessService.HasPermission(ctx, admin.ID, permission)
+		require.NoError(t, err)
+		assert.True(t, allowed, permission)
+
+		allowed, err = accessService.HasPermission(ctx, referrer.User.ID, permission)
+		require.NoError(t, err)
+		assert.False(t, allowed, permission)
+	}
+
+	// Support can look things up but not move money
+	_, err = db.Pool.Exec(ctx, "UPDATE users SET admin_role = 'support' WHERE id = $1", admin.ID)
+	require.NoError(t, err)
+	allowed, err := accessService.HasPermission(ctx, admin.ID, models.PermReportsRead)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = accessService.HasPermission(ctx, admin.ID, models.PermPayoutsApprove)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestAdminHandler_ListFilters_Integration(t *testing.T) {
+	handler, db, cleanup := setupAdminHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	userRepo := repository.NewUserRepository(db)
+	referralRepo := repository.NewReferralRepository(db, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+
+	register := func(email, name string) uuid.UUID {
+		response, err := authService.Register(ctx, &models.RegisterRequest{Email: email, Password: "password123", Name: name, Phone: "08012345678"})
+		require.NoError(t, err)
+		return response.User.ID
+	}
+	ada := register("ada@example.com", "Ada")
+	grace := register("grace@example.com", "Grace")
+
+	lastMonth := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	thisMonth := time.Date(2025, 2, 10, 12, 0, 0, 0, time.UTC)
+	refer := func(referrerID uuid.UUID, student, course, status string, earnings int64, at time.Time) {
+		id := referrerID
+		referral := &models.Referral{
+			ID:            uuid.New(),
+			ReferrerID:    &id,
+			ReferredName:  student,
+			ReferredEmail: strings.ToLower(student) + "@students.test",
+			ReferredPhone: "08000000000",
+			Course:        course,
+			CoursePrice:   earnings * 10,
+			Earnings:      earnings,
+			Status:        status,
+		}
+		require.NoError(t, referralRepo.Create(ctx, referral))
+		_, err := db.Pool.Exec(ctx, "UPDATE referrals SET created_at = $2 WHERE id = $1", referral.ID, at)
+		require.NoError(t, err)
+	}
+	refer(ada, "Alice", "Web Development", "pending", 5000, lastMonth)
+	refer(ada, "Bob", "Web Development", "paid", 7000, lastMonth)
+	refer(ada, "Carol", "Data Science", "pending", 9000, lastMonth)
+	refer(grace, "Dave", "Web Development", "pending", 3000, thisMonth)
+
+	list := func(handle http.HandlerFunc, query string) ([]map[string]interface{}, int64) {
+		req := httptest.NewRequest("GET", "/?"+query, nil)
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var response struct {
+			Data  []map[string]interface{} `json:"data"`
+			Total int64                    `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.Data, response.Total
+	}
+
+	// Last month's pending Web Development referrals
+	referrals, total := list(handler.GetReferrals, "status=pending&course=web+development&from=2025-01-01&to=2025-01-31")
+	require.Equal(t, int64(1), total)
+	assert.Equal(t, "Alice", referrals[0]["referred_name"])
+
+	// A timestamp bound includes a referral made at that exact time
+	_, total = list(handler.GetReferrals, "from=2025-02-10T12:00:00Z&to=2025-02-10T12:00:00Z")
+	assert.Equal(t, int64(1), total)
+	_, total = list(handler.GetReferrals, "to=2025-02-10T11:59:59Z&from=2025-02-01")
+	assert.Equal(t, int64(0), total)
+
+	_, total = list(handler.GetReferrals, "referrer_id="+grace.String())
+	assert.Equal(t, int64(1), total)
+
+	_, total = list(handler.GetReferrals, "q=grace")
+	assert.Equal(t, int64(1), total)
+
+	referrals, _ = list(handler.GetReferrals, "sort=earnings&order=asc")
+	require.Len(t, referrals, 4)
+	assert.Equal(t, "Dave", referrals[0]["referred_name"])
+	assert.Equal(t, "Carol", referrals[3]["referred_name"])
+
+	_, total = list(handler.GetStudents, "course=Data+Science")
+	assert.Equal(t, int64(1), total)
+
+	// Referrer totals only count referrals in range
+	referrers, total := list(handler.GetReferrers, "from=2025-02-01")
+	require.Equal(t, int64(2), total)
+	assert.Equal(t, "Grace", referrers[0]["referrer_name"])
+	assert.Equal(t, float64(1), referrers[0]["total_usage"])
+
+	_, total = list(handler.GetReferrers, "status=inactive&from=2025-02-01")
+	assert.Equal(t, int64(1), total)
+
+	referrers, _ = list(handler.GetReferrers, "sort=name&order=asc")
+	require.Len(t, referrers, 2)
+	assert.Equal(t, "Ada", referrers[0]["referrer_name"])
+
+	_, total = list(handler.GetPayouts, "referrer_id="+ada.String()+"&sort=amount")
+	assert.Equal(t, int64(0), total)
+
+	req := httptest.NewRequest("GET", "/?sort=password_hash", nil)
+	rr := httptest.NewRecorder()
+	handler.GetPayouts(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
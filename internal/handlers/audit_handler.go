@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+type AuditHandler struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditHandler(auditRepo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{auditRepo: auditRepo}
+}
+
+// ListEvents godoc
+// @Summary List audit events
+// @Description Get the admin audit log, newest first. Every successful change made through an admin route is recorded with the actor, target and state before and after.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param actor_id query string false "Admin who made the change"
+// @Param action query string false "Action, e.g. payout.update_status"
+// @Param target_type query string false "Target type, e.g. payout"
+// @Param target_id query string false "Target ID"
+// @Param from query string false "Earliest time, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest time, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse{data=[]models.AuditEvent}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/admin/audit [get]
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+
+	filter := models.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	if actor := query.Get("actor_id"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid actor_id")
+			return
+		}
+		filter.ActorID = &actorID
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+		respondError(w, http.StatusBadRequest, "from must be a date (YYYY-MM-DD) or RFC3339 time")
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+		respondError(w, http.StatusBadRequest, "to must be a date (YYYY-MM-DD) or RFC3339 time")
+		return
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		respondError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	events, total, err := h.auditRepo.List(r.Context(), filter, page, perPage)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get audit events: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	respondJSON(w, http.StatusOK, models.PaginatedResponse{
+		Data:       events,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
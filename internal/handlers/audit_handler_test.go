@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditHandler_ListEvents_InvalidFilters(t *testing.T) {
+	handler := NewAuditHandler(nil)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid actor", "actor_id=not-a-uuid"},
+		{"invalid from", "from=yesterday"},
+		{"invalid to", "to=tomorrow"},
+		{"from after to", "from=2025-01-02&to=2025-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/admin/audit?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ListEvents(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestAuditHandler_RecordsAdminActions_Integration(t *testing.T) {
+	adminHandler, db, cleanup := setupAdminHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	userRepo := repository.NewUserRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+	admin, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "auditor@example.com",
+		Password: "password123",
+		Name:     "Audit Admin",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	target, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "target@example.com",
+		Password: "password123",
+		Name:     "Target User",
+		Phone:    "08087654321",
+	})
+	require.NoError(t, err)
+
+	auditRepo := repository.NewAuditRepository(db)
+	auditHandler := NewAuditHandler(auditRepo)
+
+	r := chi.NewRouter()
+	r.Use(middleware.NewAuditMiddleware(auditRepo).Record)
+	r.Post("/users/{id}/block", adminHandler.BlockUser)
+	r.Get("/audit", auditHandler.ListEvents)
+
+	block := func(blocked bool) int {
+		body, _ := json.Marshal(models.BlockUserRequest{IsBlocked: blocked})
+		req := httptest.NewRequest("POST", "/users/"+target.User.ID.String()+"/block", bytes.NewReader(body))
+		req = req.WithContext(createUserContext(admin.User.ID, "admin"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusOK, block(true))
+	require.Equal(t, http.StatusOK, block(false))
+
+	// A failed request is not recorded
+	req := httptest.NewRequest("POST", "/users/not-a-uuid/block", bytes.NewReader([]byte(`{}`)))
+	req = req.WithContext(createUserContext(admin.User.ID, "admin"))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/audit?target_id="+target.User.ID.String(), nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Data  []models.AuditEvent `json:"data"`
+		Total int64               `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Equal(t, int64(2), response.Total)
+
+	// Newest first
+	latest := response.Data[0]
+	assert.Equal(t, "user.update_block", latest.Action)
+	assert.Equal(t, "user", latest.TargetType)
+	require.NotNil(t, latest.ActorID)
+	assert.Equal(t, admin.User.ID, *latest.ActorID)
+	assert.JSONEq(t, `{"is_blocked": true}`, string(latest.Before))
+	assert.JSONEq(t, `{"is_blocked": false}`, string(latest.After))
+}
@@ -2,22 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"time"
 
+	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
 	"github.com/cirvee/referral-backend/internal/repository"
 	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
 	"github.com/go-playground/validator/v10"
 )
 
 type AuthHandler struct {
-	authService    *services.AuthService
-	emailService   *services.EmailService
-	userRepo       *repository.UserRepository
-	resetTokenRepo *repository.ResetTokenRepository
-	validate       *validator.Validate
+	authService           *services.AuthService
+	emailService          *services.EmailService
+	userRepo              *repository.UserRepository
+	resetTokenRepo        *repository.ResetTokenRepository
+	verificationTokenRepo *repository.VerificationTokenRepository
+	validate              *validator.Validate
 }
 
 func NewAuthHandler(
@@ -25,19 +31,24 @@ func NewAuthHandler(
 	emailService *services.EmailService,
 	userRepo *repository.UserRepository,
 	resetTokenRepo *repository.ResetTokenRepository,
+	verificationTokenRepo *repository.VerificationTokenRepository,
 ) *AuthHandler {
 	return &AuthHandler{
-		authService:    authService,
-		emailService:   emailService,
-		userRepo:       userRepo,
-		resetTokenRepo: resetTokenRepo,
-		validate:       validator.New(),
+		authService:           authService,
+		emailService:          emailService,
+		userRepo:              userRepo,
+		resetTokenRepo:        resetTokenRepo,
+		verificationTokenRepo: verificationTokenRepo,
+		validate:              validator.New(),
 	}
 }
 
+// verificationTokenExpiry is how long an email verification link stays valid
+const verificationTokenExpiry = 24 * time.Hour
+
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user account (users only, no admin registration)
+// @Description Register a new user account (users only, no admin registration). The password must be 8 to 72 bytes long and contain a letter and a number. A verification link is emailed to the address; payouts and dashboard stats stay locked until it is followed.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -72,6 +83,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusConflict, "referral code collision, please try again")
 			return
 		}
+		if errors.Is(err, utils.ErrPasswordTooShort) || errors.Is(err, utils.ErrPasswordTooLong) ||
+			errors.Is(err, utils.ErrPasswordTooWeak) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		// LOGGING ADDED FOR DEBUGGING
 		fmt.Printf("[Register Error] %v\n", err)
@@ -79,22 +95,49 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Send welcome email (async, don't block response)
-	go h.emailService.SendWelcomeEmail(response.User.Email, response.User.Name)
+	// Send verification email (async, don't block response). If the token
+	// cannot be created the user can ask for a new link.
+	token, err := h.verificationTokenRepo.Create(r.Context(), response.User.ID, verificationTokenExpiry)
+	if err != nil {
+		log.Printf("failed to create verification token for %s: %v", response.User.Email, err)
+	} else {
+		go h.emailService.SendVerificationEmail(response.User.Email, response.User.Name, token.Token)
+	}
 
 	respondJSON(w, http.StatusCreated, response)
 }
 
+// clientInfo identifies the device a request came from, for login history
+func clientInfo(r *http.Request) models.ClientInfo {
+	return models.ClientInfo{
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// respondThrottled answers 429 with Retry-After if err is a login throttle
+func respondThrottled(w http.ResponseWriter, err error) bool {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	w.Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(throttled.RetryAfter.Seconds())))
+	respondError(w, http.StatusTooManyRequests, throttled.Error())
+	return true
+}
+
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return tokens
+// @Description Authenticate user and return tokens. Users with two-factor authentication enabled get an MFAChallengeResponse instead, to be exchanged at /auth/2fa/verify.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param request body models.LoginRequest true "Login credentials"
 // @Success 200 {object} models.AuthResponse
+// @Success 202 {object} models.MFAChallengeResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /api/v1/auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
@@ -108,8 +151,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.authService.Login(r.Context(), &req)
+	response, challenge, err := h.authService.Login(r.Context(), &req, clientInfo(r))
 	if err != nil {
+		if respondThrottled(w, err) {
+			return
+		}
 		if err == services.ErrInvalidCredentials {
 			respondError(w, http.StatusUnauthorized, "invalid credentials")
 			return
@@ -122,12 +168,63 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if challenge != nil {
+		respondJSON(w, http.StatusAccepted, challenge)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// VerifyMFA godoc
+// @Summary Complete a two-factor login
+// @Description Exchange the challenge token returned by login and an authenticator or recovery code for tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.MFAVerifyRequest true "Challenge token and code"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /api/v1/auth/2fa/verify [post]
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	response, err := h.authService.VerifyMFAChallenge(r.Context(), req.ChallengeToken, req.Code, clientInfo(r))
+	if err != nil {
+		if respondThrottled(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUserBlocked):
+			respondError(w, http.StatusForbidden, "user account is blocked")
+		case errors.Is(err, services.ErrInvalidMFACode):
+			respondError(w, http.StatusUnauthorized, "invalid two-factor code")
+		case errors.Is(err, utils.ErrInvalidToken), errors.Is(err, utils.ErrExpiredToken),
+			errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, repository.ErrUserNotFound):
+			respondError(w, http.StatusUnauthorized, "invalid or expired challenge; log in again")
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to verify two-factor code: "+err.Error())
+		}
+		return
+	}
+
 	respondJSON(w, http.StatusOK, response)
 }
 
 // RefreshToken godoc
 // @Summary Refresh access token
-// @Description Get new access token using refresh token
+// @Description Exchange a refresh token for a new token pair. Each refresh token can be used once; reusing one revokes the session.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -150,13 +247,223 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	response, err := h.authService.RefreshToken(r.Context(), req.RefreshToken)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "invalid or expired refresh token: "+err.Error())
+		switch {
+		case errors.Is(err, services.ErrUserBlocked):
+			respondError(w, http.StatusForbidden, "user account is blocked")
+		case errors.Is(err, repository.ErrRefreshTokenReused):
+			respondError(w, http.StatusUnauthorized, "refresh token was already used; the session has been revoked")
+		case errors.Is(err, utils.ErrInvalidToken), errors.Is(err, utils.ErrExpiredToken),
+			errors.Is(err, repository.ErrRefreshTokenNotFound), errors.Is(err, repository.ErrRefreshTokenRevoked),
+			errors.Is(err, repository.ErrUserNotFound):
+			respondError(w, http.StatusUnauthorized, "invalid or expired refresh token: "+err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to refresh token: "+err.Error())
+		}
 		return
 	}
 
 	respondJSON(w, http.StatusOK, response)
 }
 
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the session a refresh token belongs to. The refresh token and any token issued from it stop working; access tokens expire on their own.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	if err := h.authService.Logout(r.Context(), req.RefreshToken); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to log out: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Log out of all devices
+// @Description Revoke every refresh token of the current user
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if err := h.authService.LogoutAll(r.Context(), claims.UserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to log out: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "logged out of all devices"})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the current user's password. The new password must be at least 8 characters with a letter and a number. Every other session is signed out and a new token pair is returned for this one.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/user/password [patch]
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	response, err := h.authService.ChangePassword(r.Context(), claims.UserID, req.CurrentPassword, req.NewPassword, claims.MFA)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrIncorrectPassword), errors.Is(err, services.ErrPasswordUnchanged),
+			errors.Is(err, utils.ErrPasswordTooShort), errors.Is(err, utils.ErrPasswordTooLong),
+			errors.Is(err, utils.ErrPasswordTooWeak):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to change password: "+err.Error())
+		}
+		return
+	}
+
+	// Send security notification (async)
+	go h.emailService.SendPasswordChangedEmail(response.User.Email, response.User.Name)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm a user's email address using the token from the verification email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	// Validate token
+	verificationToken, err := h.verificationTokenRepo.GetByToken(r.Context(), req.Token)
+	if err != nil {
+		if err == repository.ErrTokenNotFound || err == repository.ErrTokenExpired {
+			respondError(w, http.StatusBadRequest, "invalid or expired verification token")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to validate token: "+err.Error())
+		return
+	}
+
+	if err := h.authService.VerifyEmail(r.Context(), verificationToken.UserID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to verify email: "+err.Error())
+		return
+	}
+
+	// Delete the used token
+	h.verificationTokenRepo.DeleteByUserID(r.Context(), verificationToken.UserID)
+
+	// Welcome the user now that we know the address is theirs
+	if user, err := h.userRepo.GetByID(r.Context(), verificationToken.UserID); err == nil {
+		go h.emailService.SendWelcomeEmail(user.Email, user.Name)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Email address has been verified",
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new email verification link. Earlier links stop working.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.ResendVerificationRequest true "Email address"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req models.ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	// Don't reveal if the email exists or is already verified
+	message := map[string]string{
+		"message": "If the email belongs to an unverified account, a verification link will be sent",
+	}
+
+	user, err := h.userRepo.GetByEmail(r.Context(), req.Email)
+	if err != nil || user.EmailVerifiedAt != nil || user.IsBlocked {
+		respondJSON(w, http.StatusOK, message)
+		return
+	}
+
+	token, err := h.verificationTokenRepo.Create(r.Context(), user.ID, verificationTokenExpiry)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create verification token: "+err.Error())
+		return
+	}
+
+	// Send email (async)
+	go h.emailService.SendVerificationEmail(user.Email, user.Name, token.Token)
+
+	respondJSON(w, http.StatusOK, message)
+}
+
 // ForgotPassword godoc
 // @Summary Request password reset
 // @Description Send password reset email to user
@@ -206,7 +513,7 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 
 // ResetPassword godoc
 // @Summary Reset password
-// @Description Reset password using token from email
+// @Description Reset password using token from email. The new password must be 8 to 72 bytes long and contain a letter and a number.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -239,7 +546,13 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 	// Update password
 	if err := h.authService.UpdatePassword(r.Context(), resetToken.UserID, req.NewPassword); err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to update password: "+err.Error())
+		switch {
+		case errors.Is(err, utils.ErrPasswordTooShort), errors.Is(err, utils.ErrPasswordTooLong),
+			errors.Is(err, utils.ErrPasswordTooWeak):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to update password: "+err.Error())
+		}
 		return
 	}
 
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/cirvee/referral-backend/internal/repository"
 	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -48,7 +50,7 @@ func TestAuthHandler_Register_InvalidJSON(t *testing.T) {
 }
 
 func TestAuthHandler_Register_MissingFields(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	tests := []struct {
 		name    string
@@ -74,7 +76,7 @@ func TestAuthHandler_Register_MissingFields(t *testing.T) {
 }
 
 func TestAuthHandler_Register_InvalidEmail(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	payload := models.RegisterRequest{
 		Email:    "not-an-email",
@@ -94,7 +96,7 @@ func TestAuthHandler_Register_InvalidEmail(t *testing.T) {
 }
 
 func TestAuthHandler_Register_WeakPassword(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	payload := models.RegisterRequest{
 		Email:    "test@example.com",
@@ -113,8 +115,30 @@ func TestAuthHandler_Register_WeakPassword(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+func TestAuthHandler_Register_PasswordPolicy(t *testing.T) {
+	// The policy is checked before the user repository is touched
+	authService := services.NewAuthService(nil, nil, nil, nil, nil, nil)
+	handler := NewAuthHandler(authService, nil, nil, nil, nil)
+
+	for _, password := range []string{"passwordonly", "12345678", strings.Repeat("a1", 37)} {
+		body, _ := json.Marshal(models.RegisterRequest{
+			Email:    "test@example.com",
+			Password: password,
+			Name:     "Test User",
+			Phone:    "08012345678",
+		})
+		req := httptest.NewRequest("POST", "/api/v1/auth/register", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+
+		handler.Register(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, password)
+	}
+}
+
 func TestAuthHandler_Login_InvalidJSON(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewReader([]byte("invalid")))
 	req.Header.Set("Content-Type", "application/json")
@@ -126,7 +150,7 @@ func TestAuthHandler_Login_InvalidJSON(t *testing.T) {
 }
 
 func TestAuthHandler_Login_MissingCredentials(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	tests := []struct {
 		name    string
@@ -152,7 +176,7 @@ func TestAuthHandler_Login_MissingCredentials(t *testing.T) {
 }
 
 func TestAuthHandler_RefreshToken_InvalidJSON(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewReader([]byte("invalid")))
 	req.Header.Set("Content-Type", "application/json")
@@ -164,7 +188,7 @@ func TestAuthHandler_RefreshToken_InvalidJSON(t *testing.T) {
 }
 
 func TestAuthHandler_RefreshToken_MissingToken(t *testing.T) {
-	handler := NewAuthHandler(nil, nil, nil, nil)
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
 
 	payload := map[string]interface{}{}
 	body, _ := json.Marshal(payload)
@@ -204,9 +228,9 @@ func TestAuthHandler_Register_Integration(t *testing.T) {
 
 	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, jwtManager)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
 	emailService := services.NewEmailService(&config.SMTPConfig{})
-	handler := NewAuthHandler(authService, emailService, userRepo, nil)
+	handler := NewAuthHandler(authService, emailService, userRepo, nil, repository.NewVerificationTokenRepository(db))
 
 	payload := models.RegisterRequest{
 		Email:    "integration@test.com",
@@ -239,9 +263,9 @@ func TestAuthHandler_Login_Integration(t *testing.T) {
 
 	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, jwtManager)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
 	emailService := services.NewEmailService(&config.SMTPConfig{})
-	handler := NewAuthHandler(authService, emailService, userRepo, nil)
+	handler := NewAuthHandler(authService, emailService, userRepo, nil, repository.NewVerificationTokenRepository(db))
 
 	// First register a user
 	registerPayload := models.RegisterRequest{
@@ -283,9 +307,9 @@ func TestAuthHandler_Login_WrongPassword_Integration(t *testing.T) {
 
 	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, jwtManager)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
 	emailService := services.NewEmailService(&config.SMTPConfig{})
-	handler := NewAuthHandler(authService, emailService, userRepo, nil)
+	handler := NewAuthHandler(authService, emailService, userRepo, nil, repository.NewVerificationTokenRepository(db))
 
 	// Register user
 	registerPayload := models.RegisterRequest{
@@ -321,9 +345,9 @@ func TestAuthHandler_DuplicateEmail_Integration(t *testing.T) {
 
 	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, jwtManager)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
 	emailService := services.NewEmailService(&config.SMTPConfig{})
-	handler := NewAuthHandler(authService, emailService, userRepo, nil)
+	handler := NewAuthHandler(authService, emailService, userRepo, nil, repository.NewVerificationTokenRepository(db))
 
 	payload := models.RegisterRequest{
 		Email:    "duplicate@test.com",
@@ -348,3 +372,280 @@ func TestAuthHandler_DuplicateEmail_Integration(t *testing.T) {
 
 	assert.Equal(t, http.StatusConflict, rr.Code)
 }
+
+func TestAuthHandler_Logout_MissingToken(t *testing.T) {
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/logout", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	handler.Logout(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAuthHandler_RefreshRotation_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
+	handler := NewAuthHandler(authService, services.NewEmailService(&config.SMTPConfig{}), userRepo, nil, repository.NewVerificationTokenRepository(db))
+
+	registered, err := authService.Register(context.Background(), &models.RegisterRequest{
+		Email:    "rotation@test.com",
+		Password: "password123",
+		Name:     "Rotation Test",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+
+	refresh := func(token string) (int, models.AuthResponse) {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: token})
+		req := httptest.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.RefreshToken(rr, req)
+
+		var response models.AuthResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response
+	}
+
+	code, rotated := refresh(registered.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, registered.RefreshToken, rotated.RefreshToken)
+
+	// Replaying the first token revokes the whole session, including the
+	// token it was exchanged for
+	code, _ = refresh(registered.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = refresh(rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Logging out ends only that session
+	first, _, err := authService.Login(context.Background(), &models.LoginRequest{Email: "rotation@test.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+	second, _, err := authService.Login(context.Background(), &models.LoginRequest{Email: "rotation@test.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+
+	body, _ := json.Marshal(models.RefreshRequest{RefreshToken: first.RefreshToken})
+	rr := httptest.NewRecorder()
+	handler.Logout(rr, httptest.NewRequest("POST", "/api/v1/auth/logout", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	code, _ = refresh(first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, renewed := refresh(second.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+
+	// Blocking the user revokes every remaining session
+	require.NoError(t, userRepo.UpdateStatus(context.Background(), registered.User.ID, true))
+	require.NoError(t, userRepo.UpdateStatus(context.Background(), registered.User.ID, false))
+	code, _ = refresh(renewed.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestAuthHandler_LogoutAll_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	accessService := services.NewAccessService(userRepo, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), accessService, nil, nil, jwtManager)
+	handler := NewAuthHandler(authService, services.NewEmailService(&config.SMTPConfig{}), userRepo, nil, repository.NewVerificationTokenRepository(db))
+
+	registered, err := authService.Register(context.Background(), &models.RegisterRequest{
+		Email:    "logoutall@test.com",
+		Password: "password123",
+		Name:     "Logout All Test",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	other, _, err := authService.Login(context.Background(), &models.LoginRequest{Email: "logoutall@test.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/logout-all", nil)
+	req = req.WithContext(createUserContext(registered.User.ID, "user"))
+	rr := httptest.NewRecorder()
+	handler.LogoutAll(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	for _, token := range []string{registered.RefreshToken, other.RefreshToken} {
+		_, err := authService.RefreshToken(context.Background(), token)
+		assert.ErrorIs(t, err, repository.ErrRefreshTokenRevoked)
+	}
+
+	// Access tokens issued before are rejected too
+	assert.ErrorIs(t, accessService.CheckAccess(context.Background(), registered.User.ID, 0), services.ErrTokenRevoked)
+	assert.NoError(t, accessService.CheckAccess(context.Background(), registered.User.ID, 1))
+}
+
+func TestAuthHandler_VerifyEmail_MissingToken(t *testing.T) {
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/verify-email", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	handler.VerifyEmail(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAuthHandler_VerifyEmail_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	accessService := services.NewAccessService(userRepo, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), accessService, nil, nil, jwtManager)
+	handler := NewAuthHandler(authService, services.NewEmailService(&config.SMTPConfig{}), userRepo, nil, repository.NewVerificationTokenRepository(db))
+
+	post := func(path, body string, handle http.HandlerFunc) int {
+		req := httptest.NewRequest("POST", path, bytes.NewReader([]byte(body)))
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		return rr.Code
+	}
+
+	body, _ := json.Marshal(models.RegisterRequest{
+		Email:    "verify@test.com",
+		Password: "password123",
+		Name:     "Verify Test",
+		Phone:    "08012345678",
+	})
+	require.Equal(t, http.StatusCreated, post("/api/v1/auth/register", string(body), handler.Register))
+
+	user, err := userRepo.GetByEmail(ctx, "verify@test.com")
+	require.NoError(t, err)
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	verified, err := accessService.EmailVerified(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	latestToken := func() string {
+		var token string
+		require.NoError(t, db.Pool.QueryRow(ctx, "SELECT token FROM email_verification_tokens WHERE user_id = $1", user.ID).Scan(&token))
+		return token
+	}
+
+	// Resending replaces the earlier link
+	first := latestToken()
+	require.Equal(t, http.StatusOK, post("/api/v1/auth/resend-verification", `{"email": "verify@test.com"}`, handler.ResendVerification))
+	second := latestToken()
+	assert.NotEqual(t, first, second)
+
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/auth/verify-email", `{"token": "`+first+`"}`, handler.VerifyEmail))
+	require.Equal(t, http.StatusOK, post("/api/v1/auth/verify-email", `{"token": "`+second+`"}`, handler.VerifyEmail))
+
+	verified, err = accessService.EmailVerified(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	// The link is single use
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/auth/verify-email", `{"token": "`+second+`"}`, handler.VerifyEmail))
+}
+
+func TestAuthHandler_VerifyMFA_MissingFields(t *testing.T) {
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
+
+	for _, body := range []string{`{}`, `{"challenge_token": "abc"}`, `{"code": "123456"}`} {
+		req := httptest.NewRequest("POST", "/api/v1/auth/2fa/verify", bytes.NewReader([]byte(body)))
+		rr := httptest.NewRecorder()
+
+		handler.VerifyMFA(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestAuthHandler_VerifyMFA_RejectsAccessToken(t *testing.T) {
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	handler := NewAuthHandler(services.NewAuthService(nil, nil, nil, nil, nil, jwtManager), nil, nil, nil, nil)
+
+	accessToken, err := jwtManager.GenerateAccessToken(uuid.New(), "user@test.com", "user", 0, false)
+	require.NoError(t, err)
+
+	body, _ := json.Marshal(models.MFAVerifyRequest{ChallengeToken: accessToken, Code: "123456"})
+	rr := httptest.NewRecorder()
+	handler.VerifyMFA(rr, httptest.NewRequest("POST", "/api/v1/auth/2fa/verify", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthHandler_ChangePassword_MissingFields(t *testing.T) {
+	handler := NewAuthHandler(nil, nil, nil, nil, nil)
+
+	for _, body := range []string{`{}`, `{"current_password": "password123"}`, `{"new_password": "newpassword123"}`} {
+		req := httptest.NewRequest("PATCH", "/api/v1/user/password", bytes.NewReader([]byte(body)))
+		req = req.WithContext(createUserContext(uuid.New(), "user"))
+		rr := httptest.NewRecorder()
+
+		handler.ChangePassword(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestAuthHandler_ChangePassword_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	accessService := services.NewAccessService(userRepo, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), accessService, nil, nil, jwtManager)
+	handler := NewAuthHandler(authService, services.NewEmailService(&config.SMTPConfig{}), userRepo, nil, nil)
+
+	registered, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "change@test.com",
+		Password: "password123",
+		Name:     "Change Password Test",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	oldClaims, err := jwtManager.ValidateAccessToken(registered.AccessToken)
+	require.NoError(t, err)
+
+	change := func(current, next string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+		req := httptest.NewRequest("PATCH", "/api/v1/user/password", bytes.NewReader(body))
+		req = req.WithContext(createUserContext(registered.User.ID, "user"))
+		rr := httptest.NewRecorder()
+		handler.ChangePassword(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusBadRequest, change("wrongpassword1", "newpassword123").Code)
+	assert.Equal(t, http.StatusBadRequest, change("password123", "password123").Code)
+	assert.Equal(t, http.StatusBadRequest, change("password123", "short1").Code)
+	assert.Equal(t, http.StatusBadRequest, change("password123", "lettersonly").Code)
+
+	rr := change("password123", "newpassword123")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var response models.AuthResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+	// Earlier sessions are ended; the new pair works
+	assert.ErrorIs(t, accessService.CheckAccess(ctx, registered.User.ID, oldClaims.TokenVersion), services.ErrTokenRevoked)
+	_, err = authService.RefreshToken(ctx, registered.RefreshToken)
+	assert.Error(t, err)
+
+	newClaims, err := jwtManager.ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+	assert.NoError(t, accessService.CheckAccess(ctx, registered.User.ID, newClaims.TokenVersion))
+	_, err = authService.RefreshToken(ctx, response.RefreshToken)
+	assert.NoError(t, err)
+
+	_, _, err = authService.Login(ctx, &models.LoginRequest{Email: "change@test.com", Password: "password123"}, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	_, _, err = authService.Login(ctx, &models.LoginRequest{Email: "change@test.com", Password: "newpassword123"}, models.ClientInfo{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/cirvee/referral-backend/internal/banks"
+	"github.com/cirvee/referral-backend/internal/payouts"
+)
+
+// BankHandler serves the bank lookups the signup and profile forms need
+type BankHandler struct {
+	provider payouts.PayoutProvider
+	banks    *banks.Directory
+}
+
+func NewBankHandler(provider payouts.PayoutProvider, bankDirectory *banks.Directory) *BankHandler {
+	return &BankHandler{provider: provider, banks: bankDirectory}
+}
+
+// ResolveAccountData is the holder of a resolved bank account
+type ResolveAccountData struct {
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+}
+
+// ResolveAccountResponse is the response to a bank account lookup
+type ResolveAccountResponse struct {
+	Status bool               `json:"status"`
+	Data   ResolveAccountData `json:"data"`
+}
+
+// BankListResponse is the list of banks users can be paid into
+type BankListResponse struct {
+	Status bool         `json:"status"`
+	Data   []banks.Bank `json:"data"`
+}
+
+// ListBanks godoc
+// @Summary List Nigerian banks
+// @Description Get the Nigerian banks users can be paid into. The list is fetched from the payout provider and cached; if the provider is unavailable the last fetched list, or a bundled copy, is returned.
+// @Tags Banks
+// @Produce json
+// @Success 200 {object} BankListResponse
+// @Router /api/v1/banks [get]
+func (h *BankHandler) ListBanks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	respondJSON(w, http.StatusOK, BankListResponse{
+		Status: true,
+		Data:   h.banks.List(r.Context()),
+	})
+}
+
+// ResolveAccount godoc
+// @Summary Resolve bank account
+// @Description Get account holder name from account number and bank code
+// @Tags Banks
+// @Produce json
+// @Param account_number query string true "10 digit account number"
+// @Param bank_code query string true "Bank code"
+// @Success 200 {object} ResolveAccountResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/banks/resolve [get]
+func (h *BankHandler) ResolveAccount(w http.ResponseWriter, r *http.Request) {
+	accountNumber := r.URL.Query().Get("account_number")
+	bankCode := r.URL.Query().Get("bank_code")
+
+	if accountNumber == "" || bankCode == "" {
+		respondError(w, http.StatusBadRequest, "account_number and bank_code are required")
+		return
+	}
+	if len(accountNumber) != 10 || !isDigits(accountNumber) {
+		respondError(w, http.StatusBadRequest, "account_number must be 10 digits")
+		return
+	}
+	if !isDigits(bankCode) {
+		respondError(w, http.StatusBadRequest, "invalid bank_code")
+		return
+	}
+
+	account, err := h.provider.ResolveAccount(r.Context(), accountNumber, bankCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, payouts.ErrNotConfigured):
+			respondError(w, http.StatusServiceUnavailable, "bank account lookup is not available")
+		case errors.Is(err, payouts.ErrAccountNotFound):
+			respondError(w, http.StatusBadRequest, "could not resolve account, check the account number and bank")
+		default:
+			log.Printf("failed to resolve bank account: %v", err)
+			respondError(w, http.StatusBadGateway, "could not reach the bank, please try again")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ResolveAccountResponse{
+		Status: true,
+		Data: ResolveAccountData{
+			AccountNumber: account.AccountNumber,
+			AccountName:   account.AccountName,
+		},
+	})
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
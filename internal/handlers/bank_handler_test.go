@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/banks"
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/paystack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBankHandler_ListBanks_Unreachable(t *testing.T) {
+	// Nothing listens on this address, so the bundled list is served
+	cfg := &config.PaystackConfig{SecretKey: "sk_test", BaseURL: "http://127.0.0.1:1"}
+	provider := payouts.NewPaystack(paystack.NewClient(cfg))
+	handler := NewBankHandler(provider, banks.NewDirectory(provider, nil))
+
+	req := httptest.NewRequest("GET", "/api/v1/banks", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ListBanks(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "public, max-age=3600", rr.Header().Get("Cache-Control"))
+
+	var response BankListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.True(t, response.Status)
+	assert.Contains(t, response.Data, banks.Bank{Name: "Guaranty Trust Bank", Code: "058"})
+}
+
+func TestBankHandler_ResolveAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("account_number") {
+		case "0123456789":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": true, "message": "Account number resolved",
+				"data": paystack.ResolvedAccount{AccountNumber: "0123456789", AccountName: "ADA LOVELACE", BankID: 9},
+			})
+		case "5555555555":
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "An error occurred"})
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Could not resolve account name"})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	handler := NewBankHandler(payouts.NewPaystack(paystack.NewClient(&config.PaystackConfig{SecretKey: "sk_test", BaseURL: server.URL, Timeout: time.Second})), nil)
+	unconfigured := NewBankHandler(payouts.NewPaystack(paystack.NewClient(&config.PaystackConfig{})), nil)
+
+	tests := []struct {
+		name    string
+		handler *BankHandler
+		query   string
+		code    int
+	}{
+		{"resolved", handler, "account_number=0123456789&bank_code=058", http.StatusOK},
+		{"missing params", handler, "account_number=0123456789", http.StatusBadRequest},
+		{"short account number", handler, "account_number=12345&bank_code=058", http.StatusBadRequest},
+		{"injected query", handler, "account_number=0123456789%26x%3D1&bank_code=058", http.StatusBadRequest},
+		{"invalid bank code", handler, "account_number=0123456789&bank_code=05%268", http.StatusBadRequest},
+		{"unresolvable", handler, "account_number=0000000000&bank_code=058", http.StatusBadRequest},
+		{"paystack failing", handler, "account_number=5555555555&bank_code=058", http.StatusBadGateway},
+		{"paystack not configured", unconfigured, "account_number=0123456789&bank_code=058", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/banks/resolve?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			tt.handler.ResolveAccount(rr, req)
+
+			require.Equal(t, tt.code, rr.Code, rr.Body.String())
+			if tt.code == http.StatusOK {
+				var response ResolveAccountResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+				assert.Equal(t, ResolveAccountData{AccountNumber: "0123456789", AccountName: "ADA LOVELACE"}, response.Data)
+			}
+		})
+	}
+}
+
+func TestBankHandler_ResolveAccount_Fake(t *testing.T) {
+	provider := payouts.NewFake()
+	provider.AddAccount("044", "0123456789", "GRACE HOPPER")
+	handler := NewBankHandler(provider, banks.NewDirectory(provider, nil))
+
+	req := httptest.NewRequest("GET", "/api/v1/banks/resolve?account_number=0123456789&bank_code=044", nil)
+	rr := httptest.NewRecorder()
+	handler.ResolveAccount(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response ResolveAccountResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "GRACE HOPPER", response.Data.AccountName)
+
+	req = httptest.NewRequest("GET", "/api/v1/banks/resolve?account_number=0123456789&bank_code=058", nil)
+	rr = httptest.NewRecorder()
+	handler.ResolveAccount(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
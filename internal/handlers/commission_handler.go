@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type CommissionHandler struct {
+	commissionService *services.CommissionService
+	ruleRepo          *repository.CommissionRepository
+	validate          *validator.Validate
+}
+
+func NewCommissionHandler(commissionService *services.CommissionService, ruleRepo *repository.CommissionRepository) *CommissionHandler {
+	return &CommissionHandler{
+		commissionService: commissionService,
+		ruleRepo:          ruleRepo,
+		validate:          validator.New(),
+	}
+}
+
+// ListRules godoc
+// @Summary List commission rules
+// @Description Get all commission rules including ended ones
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.CommissionRule
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/commission-rules [get]
+func (h *CommissionHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.ruleRepo.List(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get commission rules: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, rules)
+}
+
+// CreateRule godoc
+// @Summary Create a commission rule
+// @Description Define a flat (naira) or percentage (basis points) commission, optionally scoped to a course, capped, and limited to a date range
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateCommissionRuleRequest true "Rule definition"
+// @Success 201 {object} models.CommissionRule
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/commission-rules [post]
+func (h *CommissionHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCommissionRuleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	claims, _ := middleware.GetUserFromContext(r.Context())
+
+	rule, err := h.commissionService.CreateRule(r.Context(), &req, claims.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCommissionRule) {
+			respondError(w, http.StatusBadRequest, "percentage must not exceed 10000 basis points and effective_to must be after effective_from")
+			return
+		}
+		if errors.Is(err, repository.ErrCourseNotFound) {
+			respondError(w, http.StatusNotFound, "course not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to create commission rule: "+err.Error())
+		return
+	}
+
+	middleware.Audit(r.Context(), "commission_rule.create", "commission_rule", rule.ID.String(), nil, rule)
+
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+// EndRule godoc
+// @Summary End a commission rule
+// @Description Stop a commission rule from applying to new referrals. Past referrals keep their link to the rule.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/commission-rules/{id}/end [post]
+func (h *CommissionHandler) EndRule(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid rule ID")
+		return
+	}
+
+	if err := h.ruleRepo.End(r.Context(), ruleID, time.Now()); err != nil {
+		if err == repository.ErrCommissionRuleNotFound {
+			respondError(w, http.StatusNotFound, "commission rule not found or already ended")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to end commission rule: "+err.Error())
+		return
+	}
+
+	middleware.Audit(r.Context(), "commission_rule.end", "commission_rule", ruleID.String(), nil, nil)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "commission rule ended"})
+}
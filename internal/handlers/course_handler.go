@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type CourseHandler struct {
+	courseRepo *repository.CourseRepository
+	validate   *validator.Validate
+}
+
+func NewCourseHandler(courseRepo *repository.CourseRepository) *CourseHandler {
+	return &CourseHandler{
+		courseRepo: courseRepo,
+		validate:   validator.New(),
+	}
+}
+
+// ListCourses godoc
+// @Summary List available courses
+// @Description Get the active course catalog with prices
+// @Tags Courses
+// @Produce json
+// @Success 200 {array} models.Course
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/courses [get]
+func (h *CourseHandler) ListCourses(w http.ResponseWriter, r *http.Request) {
+	courses, err := h.courseRepo.List(r.Context(), false)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get courses: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, courses)
+}
+
+// AdminListCourses godoc
+// @Summary List all courses
+// @Description Get the full course catalog including archived courses
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Course
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/courses [get]
+func (h *CourseHandler) AdminListCourses(w http.ResponseWriter, r *http.Request) {
+	courses, err := h.courseRepo.List(r.Context(), true)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get courses: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, courses)
+}
+
+// CreateCourse godoc
+// @Summary Create a course
+// @Description Add a new course to the catalog
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateCourseRequest true "Course details"
+// @Success 201 {object} models.Course
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/courses [post]
+func (h *CourseHandler) CreateCourse(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCourseRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	course := &models.Course{
+		ID:    uuid.New(),
+		Name:  req.Name,
+		Price: req.Price,
+	}
+
+	if err := h.courseRepo.Create(r.Context(), course); err != nil {
+		if err == repository.ErrCourseExists {
+			respondError(w, http.StatusConflict, "course already exists")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to create course: "+err.Error())
+		return
+	}
+
+	middleware.Audit(r.Context(), "course.create", "course", course.ID.String(), nil, course)
+
+	respondJSON(w, http.StatusCreated, course)
+}
+
+// UpdateCoursePrice godoc
+// @Summary Update course price
+// @Description Change the price of a course. Existing referrals keep the price they were created with.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Course ID"
+// @Param request body models.UpdateCoursePriceRequest true "New price"
+// @Success 200 {object} models.Course
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/courses/{id} [patch]
+func (h *CourseHandler) UpdateCoursePrice(w http.ResponseWriter, r *http.Request) {
+	courseID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid course ID")
+		return
+	}
+
+	var req models.UpdateCoursePriceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	before, err := h.courseRepo.GetByID(r.Context(), courseID)
+	if err != nil {
+		if err == repository.ErrCourseNotFound {
+			respondError(w, http.StatusNotFound, "course not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to update course: "+err.Error())
+		return
+	}
+
+	course := &models.Course{ID: courseID, Price: req.Price}
+	if err := h.courseRepo.UpdatePrice(r.Context(), course); err != nil {
+		if err == repository.ErrCourseNotFound {
+			respondError(w, http.StatusNotFound, "course not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to update course: "+err.Error())
+		return
+	}
+
+	middleware.Audit(r.Context(), "course.update_price", "course", courseID.String(), before, course)
+
+	respondJSON(w, http.StatusOK, course)
+}
+
+// ArchiveCourse godoc
+// @Summary Archive a course
+// @Description Remove a course from the active catalog so new students cannot register for it
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Course ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/courses/{id}/archive [post]
+func (h *CourseHandler) ArchiveCourse(w http.ResponseWriter, r *http.Request) {
+	courseID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid course ID")
+		return
+	}
+
+	if err := h.courseRepo.Archive(r.Context(), courseID); err != nil {
+		if err == repository.ErrCourseNotFound {
+			respondError(w, http.StatusNotFound, "course not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to archive course: "+err.Error())
+		return
+	}
+
+	middleware.Audit(r.Context(), "course.archive", "course", courseID.String(), nil, nil)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "course archived"})
+}
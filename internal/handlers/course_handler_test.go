@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCourseHandler(t *testing.T) (*CourseHandler, func()) {
+	db, cleanup := setupTestDB(t)
+
+	courseRepo := repository.NewCourseRepository(db)
+	handler := NewCourseHandler(courseRepo)
+
+	return handler, func() {
+		db.Pool.Exec(t.Context(), "DELETE FROM courses WHERE name LIKE 'Test Course%'")
+		cleanup()
+	}
+}
+
+func TestCourseHandler_CreateCourse_InvalidJSON(t *testing.T) {
+	handler := NewCourseHandler(nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/admin/courses", bytes.NewReader([]byte("invalid")))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	handler.CreateCourse(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCourseHandler_CreateCourse_Validation(t *testing.T) {
+	handler := NewCourseHandler(nil)
+
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+	}{
+		{"missing_name", map[string]interface{}{"price": 100000}},
+		{"missing_price", map[string]interface{}{"name": "Test Course"}},
+		{"negative_price", map[string]interface{}{"name": "Test Course", "price": -5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.payload)
+			req := httptest.NewRequest("POST", "/api/v1/admin/courses", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			handler.CreateCourse(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestCourseHandler_UpdateCoursePrice_InvalidID(t *testing.T) {
+	handler := NewCourseHandler(nil)
+
+	req := httptest.NewRequest("PATCH", "/api/v1/admin/courses/invalid-uuid", nil)
+	rr := httptest.NewRecorder()
+
+	handler.UpdateCoursePrice(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCourseHandler_CreateAndList_Integration(t *testing.T) {
+	handler, cleanup := setupCourseHandler(t)
+	defer cleanup()
+
+	body, _ := json.Marshal(models.CreateCourseRequest{Name: "Test Course Go", Price: 250000})
+	req := httptest.NewRequest("POST", "/api/v1/admin/courses", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	handler.CreateCourse(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	// Duplicate names are rejected
+	req = httptest.NewRequest("POST", "/api/v1/admin/courses", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+
+	handler.CreateCourse(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	req = httptest.NewRequest("GET", "/api/v1/courses", nil)
+	rr = httptest.NewRecorder()
+
+	handler.ListCourses(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var courses []models.Course
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &courses))
+
+	found := false
+	for _, c := range courses {
+		if c.Name == "Test Course Go" {
+			found = true
+			assert.Equal(t, int64(250000), c.Price)
+		}
+	}
+	assert.True(t, found, "new course should be listed in the active catalog")
+}
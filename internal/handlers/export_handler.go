@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/export"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+)
+
+// exportWriteTimeout replaces the server's write timeout for exports, which
+// stream for as long as the table takes to read
+const exportWriteTimeout = 10 * time.Minute
+
+// ExportHandler streams the admin lists as CSV or XLSX files for finance to
+// reconcile against
+type ExportHandler struct {
+	referralRepo *repository.ReferralRepository
+	payoutRepo   *repository.PayoutRepository
+}
+
+func NewExportHandler(referralRepo *repository.ReferralRepository, payoutRepo *repository.PayoutRepository) *ExportHandler {
+	return &ExportHandler{
+		referralRepo: referralRepo,
+		payoutRepo:   payoutRepo,
+	}
+}
+
+// ExportReferrals godoc
+// @Summary Export referrals
+// @Description Download every referral matching the filters of GET /admin/referrals, with the referrer's bank details. Paging parameters are ignored.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param status query string false "Status (pending, approved, processing, paid, rejected, reversed)"
+// @Param course query string false "Course name"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Earliest referral date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest referral date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (created_at, earnings, course_price, course, status, referred_name)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/referrals/export [get]
+func (h *ExportHandler) ExportReferrals(w http.ResponseWriter, r *http.Request) {
+	header := []interface{}{
+		"ID", "Created At", "Status", "Student Name", "Student Email", "Student Phone",
+		"Course", "Course Price", "Earnings", "Referrer", "Referral Code",
+		"Bank", "Account Number", "Account Name",
+	}
+
+	h.serve(w, r, "referrals", referralStatuses, repository.ReferralSorts, header,
+		func(ctx context.Context, filter models.ListFilter, write func([]interface{}) error) error {
+			return h.referralRepo.ExportAll(ctx, filter, func(ref *models.Referral) error {
+				return write([]interface{}{
+					ref.ID, ref.CreatedAt, ref.Status, ref.ReferredName, ref.ReferredEmail, ref.ReferredPhone,
+					ref.Course, ref.CoursePrice, ref.Earnings, ref.ReferrerName, ref.ReferralCode,
+					ref.ReferrerBank, ref.ReferrerAccNo, ref.ReferrerAccName,
+				})
+			})
+		})
+}
+
+// ExportReferrers godoc
+// @Summary Export referrers
+// @Description Download every referrer matching the filters of GET /admin/referrers, with their bank details. Paging parameters are ignored.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param status query string false "active (has referrals) or inactive"
+// @Param course query string false "Only count referrals for this course"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Only count referrals from this date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Only count referrals up to this date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (total_earnings, total_usage, name, joined_at)" default(total_earnings)
+// @Param order query string false "asc or desc" default(desc)
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/referrers/export [get]
+func (h *ExportHandler) ExportReferrers(w http.ResponseWriter, r *http.Request) {
+	header := []interface{}{
+		"ID", "Name", "Referral Code", "Status", "Blocked", "Referrals", "Earnings",
+		"Bank", "Account Number", "Account Name",
+	}
+
+	h.serve(w, r, "referrers", referrerStatuses, repository.ReferrerSorts, header,
+		func(ctx context.Context, filter models.ListFilter, write func([]interface{}) error) error {
+			return h.referralRepo.ExportReferrersStats(ctx, filter, func(s *models.ReferrerStats) error {
+				return write([]interface{}{
+					s.ReferrerID, s.ReferrerName, s.ReferralCode, s.Status, s.IsBlocked, s.TotalUsage, s.TotalEarnings,
+					s.BankName, s.AccountNumber, s.AccountName,
+				})
+			})
+		})
+}
+
+// ExportPayouts godoc
+// @Summary Export payouts
+// @Description Download every payout matching the filters of GET /admin/payouts, with the payee's bank details. Paging parameters are ignored.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param status query string false "Filter by status (pending, approved, rejected, failed, reversed)"
+// @Param referrer_id query string false "Referrer ID"
+// @Param from query string false "Earliest request date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Latest request date, inclusive (YYYY-MM-DD or RFC3339)"
+// @Param q query string false "Search text"
+// @Param sort query string false "Sort by (created_at, amount, status, paid_at)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/payouts/export [get]
+func (h *ExportHandler) ExportPayouts(w http.ResponseWriter, r *http.Request) {
+	header := []interface{}{
+		"ID", "Requested At", "Status", "Amount", "Paid At", "Transfer Reference", "Transfer Status",
+		"Name", "Email", "Phone", "Referral Code", "Bank", "Account Number", "Account Name",
+	}
+
+	h.serve(w, r, "payouts", payoutStatuses, repository.PayoutSorts, header,
+		func(ctx context.Context, filter models.ListFilter, write func([]interface{}) error) error {
+			return h.payoutRepo.ExportAll(ctx, filter, func(p *models.Payout) error {
+				return write([]interface{}{
+					p.ID, p.CreatedAt, string(p.Status), p.Amount, p.PaidAt, p.TransferReference, p.TransferStatus,
+					p.User.Name, p.User.Email, p.User.Phone, p.User.ReferralCode,
+					p.User.BankName, p.User.AccountNumber, p.User.AccountName,
+				})
+			})
+		})
+}
+
+// serve parses the list parameters and format, then streams header and the
+// rows run writes as a file named after name
+func (h *ExportHandler) serve(
+	w http.ResponseWriter,
+	r *http.Request,
+	name string,
+	statuses []string,
+	sorts repository.SortColumns,
+	header []interface{},
+	run func(ctx context.Context, filter models.ListFilter, write func([]interface{}) error) error,
+) {
+	params, err := parseListParams(r, statuses, sorts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Not every writer supports deadlines (httptest's doesn't); the server
+	// timeout then applies
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+format.Filename(name, time.Now())+`"`)
+
+	body := &trackingWriter{w: w}
+	out := export.NewWriter(format, body, name)
+
+	err = out.Write(header)
+	if err == nil {
+		err = run(r.Context(), params.Filter, out.Write)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	log.Printf("Failed to export %s: %v", name, err)
+
+	// Nothing sent yet, so the client can still get a proper error
+	if !body.written {
+		w.Header().Del("Content-Disposition")
+		respondError(w, http.StatusInternalServerError, "failed to export "+name)
+		return
+	}
+
+	// Part of the file has gone out; drop the connection so the client sees
+	// a failed download rather than a truncated file that looks complete
+	panic(http.ErrAbortHandler)
+}
+
+// trackingWriter records whether any of the body has been written
+type trackingWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
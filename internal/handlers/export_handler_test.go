@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportHandler_InvalidParams(t *testing.T) {
+	handler := NewExportHandler(nil, nil)
+
+	for _, query := range []string{"format=pdf", "sort=password_hash", "status=lost", "from=yesterday"} {
+		req := httptest.NewRequest("GET", "/api/v1/admin/referrals/export?"+query, nil)
+		rr := httptest.NewRecorder()
+
+		handler.ExportReferrals(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		assert.Empty(t, rr.Header().Get("Content-Disposition"), query)
+	}
+}
+
+func TestExportHandler_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	userRepo := repository.NewUserRepository(db)
+	referralRepo := repository.NewReferralRepository(db, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+	handler := NewExportHandler(referralRepo, repository.NewPayoutRepository(db))
+
+	ada, err := authService.Register(ctx, &models.RegisterRequest{
+		Email: "ada@example.com", Password: "password123", Name: "Ada Lovelace", Phone: "08011111111",
+		BankName: "Test Bank", AccountNumber: "0123456789", AccountName: "Ada Lovelace",
+	})
+	require.NoError(t, err)
+
+	// One more referral than a cursor batch, so the export has to fetch twice
+	_, err = db.Pool.Exec(ctx, `
+		INSERT INTO referrals (referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status)
+		SELECT $1, 'Student ' || n, 'student' || n || '@students.test', '08000000000', 'Web Development', 50000, 5000, 'pending'
+		FROM generate_series(1, $2::int) n
+	`, ada.User.ID, 1001)
+	require.NoError(t, err)
+	require.NoError(t, referralRepo.Create(ctx, &models.Referral{
+		ID: uuid.New(), ReferrerID: &ada.User.ID, ReferredName: "=cmd()", ReferredEmail: "mallory@students.test", ReferredPhone: "08000000000",
+		Course: "Data Science", CoursePrice: 90000, Earnings: 9000, Status: "paid",
+	}))
+
+	export := func(handle http.HandlerFunc, query string) [][]string {
+		req := httptest.NewRequest("GET", "/?"+query, nil)
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment; filename=")
+
+		records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(rr.Body.String(), "\ufeff"))).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+
+	records := export(handler.ExportReferrals, "")
+	require.Len(t, records, 1003)
+	assert.Equal(t, []string{"Bank", "Account Number", "Account Name"}, records[0][11:])
+
+	records = export(handler.ExportReferrals, "status=paid")
+	require.Len(t, records, 2)
+	assert.Equal(t, "'=cmd()", records[1][3])
+	assert.Equal(t, "9000", records[1][8])
+	assert.Equal(t, []string{"Test Bank", "0123456789", "Ada Lovelace"}, records[1][11:])
+
+	records = export(handler.ExportReferrers, "")
+	require.Len(t, records, 2)
+	assert.Equal(t, "1002", records[1][5])
+	assert.Equal(t, []string{"Test Bank", "0123456789", "Ada Lovelace"}, records[1][7:])
+
+	records = export(handler.ExportPayouts, "")
+	assert.Len(t, records, 1)
+
+	req := httptest.NewRequest("GET", "/?format=xlsx&course=Data+Science", nil)
+	rr := httptest.NewRecorder()
+	handler.ExportReferrals(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", rr.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rr.Body.String(), "PK"), "xlsx export is not a zip file")
+}
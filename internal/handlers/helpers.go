@@ -32,6 +32,8 @@ func formatValidationError(err error) string {
 				messages = append(messages, e.Field()+" must be a valid email")
 			case "min":
 				messages = append(messages, e.Field()+" must be at least "+e.Param()+" characters")
+			case "gt":
+				messages = append(messages, e.Field()+" must be greater than "+e.Param())
 			case "oneof":
 				messages = append(messages, e.Field()+" must be one of: "+e.Param())
 			default:
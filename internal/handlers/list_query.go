@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// listParams is a parsed admin list request
+type listParams struct {
+	Page    int
+	PerPage int
+	Filter  models.ListFilter
+}
+
+// parseListParams reads the paging, filtering and sorting parameters shared
+// by the admin list endpoints: page, per_page, status, course, referrer_id,
+// from, to, q, sort and order. status must be one of statuses and sort one
+// of sorts. Errors are safe to show to the client.
+func parseListParams(r *http.Request, statuses []string, sorts repository.SortColumns) (*listParams, error) {
+	query := r.URL.Query()
+
+	params := &listParams{
+		Page:    getQueryInt(r, "page", 1),
+		PerPage: getQueryInt(r, "per_page", 10),
+		Filter: models.ListFilter{
+			Course: strings.TrimSpace(query.Get("course")),
+			Search: strings.TrimSpace(query.Get("q")),
+		},
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PerPage < 1 || params.PerPage > 100 {
+		params.PerPage = 10
+	}
+
+	if status := query.Get("status"); status != "" {
+		if !containsString(statuses, status) {
+			return nil, errors.New("status must be one of: " + strings.Join(statuses, ", "))
+		}
+		params.Filter.Status = status
+	}
+
+	if referrer := query.Get("referrer_id"); referrer != "" {
+		referrerID, err := uuid.Parse(referrer)
+		if err != nil {
+			return nil, errors.New("invalid referrer_id")
+		}
+		params.Filter.ReferrerID = &referrerID
+	}
+
+	var err error
+	if params.Filter.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+		return nil, errors.New("from must be a date (YYYY-MM-DD) or RFC3339 time")
+	}
+	if params.Filter.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+		return nil, errors.New("to must be a date (YYYY-MM-DD) or RFC3339 time")
+	}
+	if params.Filter.From != nil && params.Filter.To != nil && !params.Filter.From.Before(*params.Filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if _, ok := sorts[sort]; !ok {
+			return nil, errors.New("sort must be one of: " + strings.Join(sorts.Keys(), ", "))
+		}
+		params.Filter.Sort = sort
+	}
+
+	if order := strings.ToLower(query.Get("order")); order != "" {
+		if order != "asc" && order != "desc" {
+			return nil, errors.New("order must be asc or desc")
+		}
+		params.Filter.Order = order
+	}
+
+	return params, nil
+}
+
+// parseTimeParam parses an RFC3339 time or a YYYY-MM-DD date (UTC). Ranges
+// are half-open, so as an upper bound a date means the end of that day and a
+// time means the next microsecond (the database's resolution), making both
+// inclusive.
+func parseTimeParam(value string, upper bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if upper {
+			t = t.Truncate(time.Microsecond).Add(time.Microsecond)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Status values each list accepts
+var (
+	referralStatuses = []string{
+		models.ReferralStatusPending, models.ReferralStatusApproved, models.ReferralStatusProcessing,
+		models.ReferralStatusPaid, models.ReferralStatusRejected, models.ReferralStatusReversed,
+	}
+	referrerStatuses = []string{"active", "inactive"}
+	payoutStatuses   = []string{
+		string(models.PayoutStatusPending), string(models.PayoutStatusApproved), string(models.PayoutStatusRejected),
+		string(models.PayoutStatusFailed), string(models.PayoutStatusReversed),
+	}
+)
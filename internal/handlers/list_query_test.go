@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListParams(t *testing.T) {
+	referrerID := uuid.New()
+	req := httptest.NewRequest("GET", "/?page=2&per_page=500&status=pending&course=Web+Development&referrer_id="+referrerID.String()+
+		"&from=2025-01-01&to=2025-01-31&q=+ada+&sort=earnings&order=ASC", nil)
+
+	params, err := parseListParams(req, referralStatuses, repository.ReferralSorts)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, params.Page)
+	assert.Equal(t, 10, params.PerPage)
+	assert.Equal(t, "pending", params.Filter.Status)
+	assert.Equal(t, "Web Development", params.Filter.Course)
+	assert.Equal(t, referrerID, *params.Filter.ReferrerID)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), *params.Filter.From)
+	// A date as the upper bound includes that whole day
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), *params.Filter.To)
+	assert.Equal(t, "ada", params.Filter.Search)
+	assert.Equal(t, "earnings", params.Filter.Sort)
+	assert.Equal(t, "asc", params.Filter.Order)
+}
+
+func TestParseListParams_Defaults(t *testing.T) {
+	params, err := parseListParams(httptest.NewRequest("GET", "/", nil), payoutStatuses, repository.PayoutSorts)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, params.Page)
+	assert.Equal(t, 10, params.PerPage)
+	assert.Empty(t, params.Filter.Status)
+	assert.Nil(t, params.Filter.ReferrerID)
+	assert.Nil(t, params.Filter.From)
+	assert.Nil(t, params.Filter.To)
+	assert.Empty(t, params.Filter.Sort)
+
+	// A time as the upper bound includes that instant but nothing later
+	params, err = parseListParams(httptest.NewRequest("GET", "/?to=2025-03-01T12:00:00Z", nil), payoutStatuses, repository.PayoutSorts)
+	require.NoError(t, err)
+	boundary := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.True(t, boundary.Before(*params.Filter.To))
+	assert.False(t, boundary.Add(time.Microsecond).Before(*params.Filter.To))
+
+	// A single instant is a valid range
+	params, err = parseListParams(httptest.NewRequest("GET", "/?from=2025-03-01T12:00:00Z&to=2025-03-01T12:00:00Z", nil), payoutStatuses, repository.PayoutSorts)
+	require.NoError(t, err)
+	assert.True(t, params.Filter.From.Before(*params.Filter.To))
+}
+
+func TestParseListParams_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown status", "status=lost"},
+		{"invalid referrer", "referrer_id=nope"},
+		{"invalid from", "from=yesterday"},
+		{"invalid to", "to=31/01/2025"},
+		{"empty range", "from=2025-02-01&to=2025-01-01"},
+		{"unknown sort", "sort=password_hash"},
+		{"sql in sort", "sort=created_at%3BDROP+TABLE+users"},
+		{"invalid order", "order=sideways"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL.RawQuery = tt.query
+
+			_, err := parseListParams(req, referralStatuses, repository.ReferralSorts)
+			assert.Error(t, err)
+		})
+	}
+}
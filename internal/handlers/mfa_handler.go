@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/go-playground/validator/v10"
+)
+
+type MFAHandler struct {
+	mfaService *services.MFAService
+	validate   *validator.Validate
+}
+
+func NewMFAHandler(mfaService *services.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+		validate:   validator.New(),
+	}
+}
+
+// Setup godoc
+// @Summary Start two-factor enrolment
+// @Description Generate a TOTP secret and an otpauth:// URI to show as a QR code. Enrolment is finished by confirming a code at /auth/2fa/enable.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.MFASetupResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/auth/2fa/setup [post]
+func (h *MFAHandler) Setup(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	response, err := h.mfaService.Setup(r.Context(), claims.UserID, claims.Email)
+	if err != nil {
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to start two-factor setup: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// Enable godoc
+// @Summary Enable two-factor authentication
+// @Description Confirm enrolment with a code from the authenticator app. Returns single-use recovery codes, which are not shown again. Log in again afterwards to get a two-factor session.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.MFACodeRequest true "Authenticator code"
+// @Success 200 {object} models.MFAEnableResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/auth/2fa/enable [post]
+func (h *MFAHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	codes, err := h.mfaService.Enable(r.Context(), claims.UserID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMFAAlreadyEnabled):
+			respondError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrMFANotSetUp), errors.Is(err, services.ErrInvalidMFACode):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to enable two-factor authentication: "+err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.MFAEnableResponse{RecoveryCodes: codes})
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Description Turn two-factor authentication off with a current authenticator or recovery code. Not available to admins, for whom it is mandatory.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.MFACodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/auth/2fa/disable [post]
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	if err := h.mfaService.Disable(r.Context(), claims.UserID, models.Role(claims.Role), req.Code); err != nil {
+		switch {
+		case errors.Is(err, services.ErrMFAMandatory):
+			respondError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrInvalidMFACode):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to disable two-factor authentication: "+err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "two-factor authentication disabled"})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMFAHandler_Enable_MissingCode(t *testing.T) {
+	handler := NewMFAHandler(nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/2fa/enable", bytes.NewReader([]byte(`{}`)))
+	req = req.WithContext(createUserContext(uuid.New(), "user"))
+	rr := httptest.NewRecorder()
+
+	handler.Enable(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestMFAHandler_AdminEnrolment_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	mfaService := services.NewMFAService(repository.NewMFARepository(db), nil, []byte("0123456789abcdef0123456789abcdef"), "Cirvee Test")
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), mfaService, nil, jwtManager)
+	handler := NewMFAHandler(mfaService)
+
+	admin, err := authService.CreateAdmin(ctx, "mfa-admin@test.com", "password123", "MFA Admin")
+	require.NoError(t, err)
+
+	post := func(handle http.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/v1/auth/2fa", bytes.NewReader(payload))
+		req = req.WithContext(createUserContext(admin.ID, "admin"))
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		return rr
+	}
+
+	// Until enrolment is confirmed, login issues tokens without a second factor
+	response, challenge, err := authService.Login(ctx, &models.LoginRequest{Email: "mfa-admin@test.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+	require.Nil(t, challenge)
+	claims, err := jwtManager.ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, claims.MFA)
+
+	rr := post(handler.Enable, models.MFACodeRequest{Code: "123456"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = post(handler.Setup, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var setup models.MFASetupResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &setup))
+
+	// The secret is not stored in the clear
+	var stored string
+	require.NoError(t, db.Pool.QueryRow(ctx, "SELECT totp_secret_encrypted FROM users WHERE id = $1", admin.ID).Scan(&stored))
+	assert.NotContains(t, stored, setup.Secret)
+
+	code, err := utils.TOTPCode(setup.Secret, time.Now())
+	require.NoError(t, err)
+	rr = post(handler.Enable, models.MFACodeRequest{Code: code})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = post(handler.Setup, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Admins cannot turn it off
+	rr = post(handler.Disable, models.MFACodeRequest{Code: code})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Login now needs the second factor
+	response, challenge, err = authService.Login(ctx, &models.LoginRequest{Email: "mfa-admin@test.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+	require.Nil(t, response)
+	require.NotNil(t, challenge)
+
+	_, err = authService.VerifyMFAChallenge(ctx, challenge.ChallengeToken, "000000", models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+
+	// The code that enabled 2FA cannot be used again
+	_, err = authService.VerifyMFAChallenge(ctx, challenge.ChallengeToken, code, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+
+	code, err = utils.TOTPCode(setup.Secret, time.Now().Add(30*time.Second))
+	require.NoError(t, err)
+	response, err = authService.VerifyMFAChallenge(ctx, challenge.ChallengeToken, code, models.ClientInfo{})
+	require.NoError(t, err)
+	claims, err = jwtManager.ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, claims.MFA)
+
+	// Refreshing keeps the second factor
+	refreshed, err := authService.RefreshToken(ctx, response.RefreshToken)
+	require.NoError(t, err)
+	claims, err = jwtManager.ValidateAccessToken(refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, claims.MFA)
+}
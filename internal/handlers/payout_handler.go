@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/go-playground/validator/v10"
+)
+
+type PayoutHandler struct {
+	payoutService *services.PayoutService
+	payoutRepo    *repository.PayoutRepository
+	validate      *validator.Validate
+}
+
+func NewPayoutHandler(payoutService *services.PayoutService, payoutRepo *repository.PayoutRepository) *PayoutHandler {
+	return &PayoutHandler{
+		payoutService: payoutService,
+		payoutRepo:    payoutRepo,
+		validate:      validator.New(),
+	}
+}
+
+// RequestPayout godoc
+// @Summary Request a payout
+// @Description Withdraw from the referrer's pending earnings. Requires bank details and at most one pending payout at a time.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.RequestPayoutRequest true "Payout amount in naira"
+// @Success 201 {object} models.Payout
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/user/payouts [post]
+func (h *PayoutHandler) RequestPayout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.RequestPayoutRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	payout, err := h.payoutService.RequestPayout(r.Context(), claims.UserID, req.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMissingBankDetails):
+			respondError(w, http.StatusBadRequest, "add your bank details before requesting a payout")
+		case errors.Is(err, services.ErrInsufficientBalance):
+			respondError(w, http.StatusBadRequest, "payout amount exceeds available earnings")
+		case errors.Is(err, repository.ErrPendingPayoutExists):
+			respondError(w, http.StatusConflict, "you already have a pending payout")
+		case errors.Is(err, repository.ErrUserNotFound):
+			respondError(w, http.StatusNotFound, "user not found")
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to request payout: "+err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, payout)
+}
+
+// GetMyPayouts godoc
+// @Summary Get my payouts
+// @Description Get paginated payout history for the current referrer
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/user/payouts [get]
+func (h *PayoutHandler) GetMyPayouts(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+
+	payouts, total, err := h.payoutRepo.ListByUser(r.Context(), claims.UserID, page, perPage)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get payouts: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	respondJSON(w, http.StatusOK, models.PaginatedResponse{
+		Data:       payouts,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
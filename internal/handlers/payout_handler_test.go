@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPayoutHandler(t *testing.T) (*PayoutHandler, *repository.UserRepository, *referralFixture, uuid.UUID, func()) {
+	db, cleanup := setupTestDB(t)
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	referralRepo := repository.NewReferralRepository(db, nil)
+	payoutRepo := repository.NewPayoutRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
+	ledgerService := services.NewLedgerService(repository.NewLedgerRepository(db), userRepo)
+	payoutService := services.NewPayoutService(payoutRepo, referralRepo, userRepo, ledgerService, nil)
+
+	response, err := authService.Register(context.Background(), &models.RegisterRequest{
+		Email:    "payout@example.com",
+		Password: "password123",
+		Name:     "Payout User",
+		Phone:    "08012345678",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	fixture := &referralFixture{ReferralRepository: referralRepo, ledger: ledgerService}
+	return NewPayoutHandler(payoutService, payoutRepo), userRepo, fixture, response.User.ID, cleanup
+}
+
+// referralFixture creates referrals the way student registration does, so the
+// commission is accrued in the ledger.
+type referralFixture struct {
+	*repository.ReferralRepository
+	ledger *services.LedgerService
+}
+
+func (f *referralFixture) Create(ctx context.Context, referral *models.Referral) error {
+	return f.CreateWithHook(ctx, referral, f.ledger.AccrueCommission(referral))
+}
+
+func requestPayout(handler *PayoutHandler, userID uuid.UUID, amount int64) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(models.RequestPayoutRequest{Amount: amount})
+	req := httptest.NewRequest("POST", "/api/v1/user/payouts", bytes.NewReader(body))
+	req = req.WithContext(createUserContext(userID, "user"))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	handler.RequestPayout(rr, req)
+	return rr
+}
+
+func TestPayoutHandler_RequestPayout_Unauthorized(t *testing.T) {
+	handler := NewPayoutHandler(nil, nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/user/payouts", bytes.NewReader([]byte(`{"amount":1000}`)))
+	rr := httptest.NewRecorder()
+
+	handler.RequestPayout(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestPayoutHandler_RequestPayout_InvalidAmount(t *testing.T) {
+	handler := NewPayoutHandler(nil, nil)
+
+	rr := requestPayout(handler, uuid.New(), 0)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPayoutHandler_RequestPayout_Integration(t *testing.T) {
+	handler, userRepo, referralRepo, userID, cleanup := setupPayoutHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// No bank details yet
+	rr := requestPayout(handler, userID, 5000)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	user, err := userRepo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	user.BankName = "GTBank"
+	user.AccountNumber = "0123456789"
+	user.AccountName = "Payout User"
+	require.NoError(t, userRepo.Update(ctx, user))
+
+	// No earnings yet
+	rr = requestPayout(handler, userID, 5000)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	require.NoError(t, referralRepo.Create(ctx, &models.Referral{
+		ID:            uuid.New(),
+		ReferrerID:    &userID,
+		ReferredName:  "Student",
+		ReferredEmail: "student@example.com",
+		ReferredPhone: "08000000000",
+		Course:        "Web Development",
+		CoursePrice:   750000,
+		Earnings:      10000,
+		Status:        "pending",
+	}))
+
+	rr = requestPayout(handler, userID, 20000)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "cannot withdraw more than earned")
+
+	rr = requestPayout(handler, userID, 10000)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = requestPayout(handler, userID, 5000)
+	assert.Equal(t, http.StatusConflict, rr.Code, "only one pending payout at a time")
+
+	req := httptest.NewRequest("GET", "/api/v1/user/payouts", nil)
+	req = req.WithContext(createUserContext(userID, "user"))
+	rr = httptest.NewRecorder()
+	handler.GetMyPayouts(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response models.PaginatedResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, int64(1), response.Total)
+}
+
+func TestPayoutHandler_PayoutSettlesReservedReferrals_Integration(t *testing.T) {
+	handler, userRepo, referralRepo, userID, cleanup := setupPayoutHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user, err := userRepo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	user.BankName = "GTBank"
+	user.AccountNumber = "0123456789"
+	user.AccountName = "Payout User"
+	require.NoError(t, userRepo.Update(ctx, user))
+
+	referralIDs := make([]uuid.UUID, 0, 3)
+	for i := 0; i < 3; i++ {
+		id := uuid.New()
+		require.NoError(t, referralRepo.Create(ctx, &models.Referral{
+			ID:            id,
+			ReferrerID:    &userID,
+			ReferredName:  "Student",
+			ReferredEmail: "student@example.com",
+			ReferredPhone: "08000000000",
+			Course:        "Web Development",
+			CoursePrice:   750000,
+			Earnings:      10000,
+			Status:        "pending",
+		}))
+		referralIDs = append(referralIDs, id)
+	}
+
+	// 25000 only fits two whole referrals
+	rr := requestPayout(handler, userID, 25000)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var payout models.Payout
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &payout))
+	assert.Equal(t, int64(20000), payout.Amount)
+	assert.Len(t, payout.Items, 2)
+
+	// Rejection releases the reserved referrals
+	adminID := userID
+	require.NoError(t, handler.payoutService.UpdateStatus(ctx, payout.ID, models.PayoutStatusRejected, adminID))
+	for _, id := range referralIDs {
+		ref, err := referralRepo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "pending", ref.Status)
+	}
+
+	// Approval pays exactly the reserved referrals
+	rr = requestPayout(handler, userID, 10000)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &payout))
+	require.NoError(t, handler.payoutService.UpdateStatus(ctx, payout.ID, models.PayoutStatusApproved, adminID))
+
+	paid := 0
+	for _, id := range referralIDs {
+		ref, err := referralRepo.GetByID(ctx, id)
+		require.NoError(t, err)
+		if ref.Status == "paid" {
+			paid++
+			assert.Equal(t, payout.Items[0].ReferralID, ref.ID)
+		}
+	}
+	assert.Equal(t, 1, paid)
+
+	summary, err := referralRepo.ledger.UserSummary(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30000), summary.Earned)
+	assert.Equal(t, int64(10000), summary.PaidOut)
+	assert.Equal(t, int64(20000), summary.Balance)
+
+	err = handler.payoutService.UpdateStatus(ctx, payout.ID, models.PayoutStatusRejected, adminID)
+	assert.ErrorIs(t, err, repository.ErrPayoutNotPending)
+}
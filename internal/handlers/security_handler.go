@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+)
+
+type SecurityHandler struct {
+	loginHistoryRepo *repository.LoginHistoryRepository
+}
+
+func NewSecurityHandler(loginHistoryRepo *repository.LoginHistoryRepository) *SecurityHandler {
+	return &SecurityHandler{loginHistoryRepo: loginHistoryRepo}
+}
+
+// GetLogins godoc
+// @Summary Get my sign-in history
+// @Description Get paginated sign-in attempts on the current account, successful and failed, newest first
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse{data=[]models.LoginEvent}
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/user/security/logins [get]
+func (h *SecurityHandler) GetLogins(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+
+	events, total, err := h.loginHistoryRepo.ListByUser(r.Context(), claims.UserID, page, perPage)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get login history: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	respondJSON(w, http.StatusOK, models.PaginatedResponse{
+		Data:       events,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
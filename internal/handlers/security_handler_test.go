@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHandler_GetLogins_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	historyRepo := repository.NewLoginHistoryRepository(db)
+	loginSecurity := services.NewLoginSecurityService(nil, historyRepo, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, loginSecurity, jwtManager)
+	handler := NewSecurityHandler(historyRepo)
+
+	registered, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "history@test.com",
+		Password: "password123",
+		Name:     "History Test",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+
+	laptop := models.ClientInfo{IPAddress: "198.51.100.7", UserAgent: "Firefox"}
+	_, _, err = authService.Login(ctx, &models.LoginRequest{Email: "history@test.com", Password: "wrongpassword1"}, laptop)
+	require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	_, _, err = authService.Login(ctx, &models.LoginRequest{Email: "history@test.com", Password: "password123"}, laptop)
+	require.NoError(t, err)
+
+	known, err := historyRepo.HasSucceededFrom(ctx, registered.User.ID, laptop.IPAddress, laptop.UserAgent)
+	require.NoError(t, err)
+	assert.True(t, known)
+	known, err = historyRepo.HasSucceededFrom(ctx, registered.User.ID, "203.0.113.9", laptop.UserAgent)
+	require.NoError(t, err)
+	assert.False(t, known)
+
+	req := httptest.NewRequest("GET", "/api/v1/user/security/logins", nil)
+	req = req.WithContext(createUserContext(registered.User.ID, "user"))
+	rr := httptest.NewRecorder()
+	handler.GetLogins(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var response struct {
+		Data  []models.LoginEvent `json:"data"`
+		Total int64               `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.True(t, response.Data[0].Success)
+	assert.False(t, response.Data[1].Success)
+	require.NotNil(t, response.Data[1].FailureReason)
+	assert.Equal(t, models.LoginFailureInvalidPassword, *response.Data[1].FailureReason)
+	assert.Equal(t, "198.51.100.7", response.Data[0].IPAddress)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/cirvee/referral-backend/internal/config"
 	"github.com/cirvee/referral-backend/internal/models"
@@ -12,43 +13,38 @@ import (
 	"github.com/google/uuid"
 )
 
-// Course prices and earnings percentages
-var coursePrices = map[string]int64{
-	"Web Development":    750000,
-	"Data Science":       400000,
-	"Mobile Development": 400000,
-	"UI/UX Design":       350000,
-	"Digital Marketing":  100000,
-	"Cybersecurity":      400000,
-	"Cloud Computing":    400000,
-	"Machine Learning":   400000,
-}
-
-const referralCommission = 10000
-
 type StudentHandler struct {
-	userRepo     *repository.UserRepository
-	referralRepo *repository.ReferralRepository
-	clickRepo    *repository.ClickRepository
-	emailService *services.EmailService
-	adminEmail   string
-	validate     *validator.Validate
+	userRepo          *repository.UserRepository
+	referralRepo      *repository.ReferralRepository
+	clickRepo         *repository.ClickRepository
+	courseRepo        *repository.CourseRepository
+	commissionService *services.CommissionService
+	ledgerService     *services.LedgerService
+	emailService      *services.EmailService
+	adminEmail        string
+	validate          *validator.Validate
 }
 
 func NewStudentHandler(
 	userRepo *repository.UserRepository,
 	referralRepo *repository.ReferralRepository,
 	clickRepo *repository.ClickRepository,
+	courseRepo *repository.CourseRepository,
+	commissionService *services.CommissionService,
+	ledgerService *services.LedgerService,
 	emailService *services.EmailService,
 	adminCfg *config.AdminConfig,
 ) *StudentHandler {
 	return &StudentHandler{
-		userRepo:     userRepo,
-		referralRepo: referralRepo,
-		clickRepo:    clickRepo,
-		emailService: emailService,
-		adminEmail:   adminCfg.Email,
-		validate:     validator.New(),
+		userRepo:          userRepo,
+		referralRepo:      referralRepo,
+		clickRepo:         clickRepo,
+		courseRepo:        courseRepo,
+		commissionService: commissionService,
+		ledgerService:     ledgerService,
+		emailService:      emailService,
+		adminEmail:        adminCfg.Email,
+		validate:          validator.New(),
 	}
 }
 
@@ -79,15 +75,21 @@ func (h *StudentHandler) RegisterStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get course price (default to 100000 if not found)
-	coursePrice, ok := coursePrices[req.Course]
-	if !ok {
-		coursePrice = 100000
+	// Price comes from the active catalog; unknown or archived courses are rejected
+	course, err := h.courseRepo.GetActiveByName(r.Context(), req.Course)
+	if err != nil {
+		if err == repository.ErrCourseNotFound {
+			respondError(w, http.StatusBadRequest, "course is not available")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to verify course: "+err.Error())
+		return
 	}
 
 	// Calculate earnings and set referrer
 	var referrerID *uuid.UUID
 	var earnings int64
+	var commissionRuleID *uuid.UUID
 	var referrerName string
 	var referrerEmail string
 
@@ -102,26 +104,38 @@ func (h *StudentHandler) RegisterStudent(w http.ResponseWriter, r *http.Request)
 		} else {
 			// Valid referral
 			referrerID = &referrer.ID
-			earnings = referralCommission
+			amount, rule, err := h.commissionService.Evaluate(r.Context(), course, time.Now())
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "failed to calculate commission: "+err.Error())
+				return
+			}
+			earnings = amount
+			if rule != nil {
+				commissionRuleID = &rule.ID
+			}
 			referrerName = referrer.Name
-			referrerEmail = referrer.Email
+			// Commission emails only go to confirmed addresses
+			if referrer.EmailVerifiedAt != nil {
+				referrerEmail = referrer.Email
+			}
 		}
 	}
 
 	// ALWAYS Create referral record to persist Course info
 	referral := &models.Referral{
-		ID:            uuid.New(),
-		ReferrerID:    referrerID,
-		ReferredName:  req.Name,
-		ReferredEmail: req.Email,
-		ReferredPhone: req.Phone,
-		Course:        req.Course,
-		CoursePrice:   coursePrice,
-		Earnings:      earnings,
-		Status:        "pending",
+		ID:               uuid.New(),
+		ReferrerID:       referrerID,
+		ReferredName:     req.Name,
+		ReferredEmail:    req.Email,
+		ReferredPhone:    req.Phone,
+		Course:           course.Name,
+		CoursePrice:      course.Price,
+		Earnings:         earnings,
+		Status:           "pending",
+		CommissionRuleID: commissionRuleID,
 	}
 
-	if err := h.referralRepo.Create(r.Context(), referral); err != nil {
+	if err := h.referralRepo.CreateWithHook(r.Context(), referral, h.ledgerService.AccrueCommission(referral)); err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to create referral record: "+err.Error())
 		return
 	}
@@ -133,7 +147,9 @@ func (h *StudentHandler) RegisterStudent(w http.ResponseWriter, r *http.Request)
 
 	if referrerID != nil {
 		// Only notify referrer if one exists
-		go h.emailService.SendReferralNotification(referrerEmail, referrerName, req.Name, req.Course, earnings)
+		if referrerEmail != "" {
+			go h.emailService.SendReferralNotification(referrerEmail, referrerName, req.Name, req.Course, earnings)
+		}
 		go h.emailService.SendAdminNewStudentAlert(h.adminEmail, req.Name, req.Email, req.Course, referrerName)
 
 		respondJSON(w, http.StatusCreated, map[string]string{
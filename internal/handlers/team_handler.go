@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/middleware"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// TeamHandler manages the admin team
+type TeamHandler struct {
+	adminService *services.AdminService
+	authService  *services.AuthService
+	validate     *validator.Validate
+}
+
+func NewTeamHandler(adminService *services.AdminService, authService *services.AuthService) *TeamHandler {
+	return &TeamHandler{
+		adminService: adminService,
+		authService:  authService,
+		validate:     validator.New(),
+	}
+}
+
+// InviteAdmin godoc
+// @Summary Invite an admin
+// @Description Email a one-time link, valid for 72 hours, that lets the recipient set a password and join as an admin with the given role. Inviting the same address again replaces the earlier link.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body models.InviteAdminRequest true "Invitee and role"
+// @Success 201 {object} models.AdminInvite
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/admins/invite [post]
+func (h *TeamHandler) InviteAdmin(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.InviteAdminRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	invite, err := h.adminService.Invite(r.Context(), claims.UserID, req.Email, req.AdminRole)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyExists):
+			respondError(w, http.StatusConflict, "email already registered")
+		case errors.Is(err, services.ErrUnknownAdminRole):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to invite admin: "+err.Error())
+		}
+		return
+	}
+
+	middleware.Audit(r.Context(), "admin.invite", "admin_invite", invite.ID.String(), nil, invite)
+
+	respondJSON(w, http.StatusCreated, invite)
+}
+
+// ListAdmins godoc
+// @Summary List admins
+// @Description Get a paginated list of admin accounts with their roles
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse{data=[]models.User}
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/admins [get]
+func (h *TeamHandler) ListAdmins(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+
+	admins, total, err := h.adminService.ListAdmins(r.Context(), page, perPage)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get admins: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	respondJSON(w, http.StatusOK, models.PaginatedResponse{
+		Data:       admins,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+// UpdateAdmin godoc
+// @Summary Update an admin
+// @Description Change an admin's role, block or unblock them, or both. The last active super admin can be neither demoted nor blocked.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Admin user ID"
+// @Param request body models.UpdateAdminRequest true "New role and/or block status"
+// @Success 200 {object} models.User
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/admins/{id} [patch]
+func (h *TeamHandler) UpdateAdmin(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	var req models.UpdateAdminRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.AdminRole == nil && req.IsBlocked == nil {
+		respondError(w, http.StatusBadRequest, "nothing to update")
+		return
+	}
+
+	if req.IsBlocked != nil && *req.IsBlocked && claims.UserID == userID {
+		respondError(w, http.StatusBadRequest, "cannot block yourself")
+		return
+	}
+
+	before, err := h.adminService.GetAdmin(r.Context(), userID)
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+
+	after, err := h.adminService.UpdateAdmin(r.Context(), userID, &req)
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+
+	middleware.Audit(r.Context(), "admin.update", "user", userID.String(),
+		adminAccess(before), adminAccess(after))
+
+	respondJSON(w, http.StatusOK, after)
+}
+
+// ListRoles godoc
+// @Summary List admin roles
+// @Description Get every admin role and the permissions it grants
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.AdminRoleInfo
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/roles [get]
+func (h *TeamHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.adminService.ListRoles(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get roles: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, roles)
+}
+
+// AcceptInvite godoc
+// @Summary Accept an admin invitation
+// @Description Set a name and password with the token from an invitation email. Creates the admin account and logs it in; two-factor enrolment is required before admin routes can be used.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.AcceptInviteRequest true "Invite token, name and password"
+// @Success 201 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/auth/accept-invite [post]
+func (h *TeamHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	var req models.AcceptInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
+		return
+	}
+
+	user, err := h.adminService.AcceptInvite(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTokenNotFound), errors.Is(err, repository.ErrTokenExpired):
+			respondError(w, http.StatusBadRequest, "invalid or expired invitation")
+		case errors.Is(err, services.ErrEmailAlreadyExists):
+			respondError(w, http.StatusConflict, "email already registered")
+		case errors.Is(err, utils.ErrPasswordTooShort), errors.Is(err, utils.ErrPasswordTooLong),
+			errors.Is(err, utils.ErrPasswordTooWeak):
+			respondError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "failed to accept invitation: "+err.Error())
+		}
+		return
+	}
+
+	response, _, err := h.authService.Login(r.Context(), &models.LoginRequest{Email: user.Email, Password: req.Password}, clientInfo(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "account created but failed to login: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+func respondTeamError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound), errors.Is(err, services.ErrNotAnAdmin):
+		respondError(w, http.StatusNotFound, "admin not found")
+	case errors.Is(err, services.ErrUnknownAdminRole):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrLastSuperAdmin):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "failed to update admin: "+err.Error())
+	}
+}
+
+// adminAccess is the part of an admin recorded in the audit log
+func adminAccess(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"admin_role": user.AdminRole,
+		"is_blocked": user.IsBlocked,
+	}
+}
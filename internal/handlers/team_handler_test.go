@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamHandler_Validation(t *testing.T) {
+	handler := NewTeamHandler(nil, nil)
+
+	tests := []struct {
+		name   string
+		handle http.HandlerFunc
+		body   string
+	}{
+		{"invite without email", handler.InviteAdmin, `{"admin_role": "finance"}`},
+		{"invite with bad email", handler.InviteAdmin, `{"email": "nope", "admin_role": "finance"}`},
+		{"invite without role", handler.InviteAdmin, `{"email": "new@example.com"}`},
+		{"accept without token", handler.AcceptInvite, `{"name": "New Admin", "password": "password123"}`},
+		{"accept without password", handler.AcceptInvite, `{"token": "abc", "name": "New Admin"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(tt.body)))
+			req = req.WithContext(createUserContext(uuid.New(), "admin"))
+			rr := httptest.NewRecorder()
+
+			tt.handle(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func setupTeamHandler(t *testing.T) (*TeamHandler, *database.DB, *services.AuthService, *services.AccessService, func()) {
+	db, cleanup := setupTestDB(t)
+
+	userRepo := repository.NewUserRepository(db)
+	accessService := services.NewAccessService(userRepo, nil)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), accessService, nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+	adminService := services.NewAdminService(userRepo, repository.NewRoleRepository(db), repository.NewAdminInviteRepository(db), accessService, nil)
+
+	return NewTeamHandler(adminService, authService), db, authService, accessService, cleanup
+}
+
+func TestTeamHandler_InviteAndAccept_Integration(t *testing.T) {
+	handler, db, authService, accessService, cleanup := setupTeamHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	super, err := authService.CreateAdmin(ctx, "super@example.com", "password123", "Super Admin")
+	require.NoError(t, err)
+
+	invite := func(email string, role models.AdminRole) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.InviteAdminRequest{Email: email, AdminRole: role})
+		req := httptest.NewRequest("POST", "/api/v1/admin/admins/invite", bytes.NewReader(body))
+		req = req.WithContext(createUserContext(super.ID, "admin"))
+		rr := httptest.NewRecorder()
+		handler.InviteAdmin(rr, req)
+		return rr
+	}
+
+	accept := func(token, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.AcceptInviteRequest{Token: token, Name: "Finance Admin", Password: password})
+		req := httptest.NewRequest("POST", "/api/v1/auth/accept-invite", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.AcceptInvite(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusConflict, invite("super@example.com", models.AdminRoleFinance).Code)
+	assert.Equal(t, http.StatusBadRequest, invite("finance@example.com", "janitor").Code)
+
+	// Inviting again replaces the first link
+	require.Equal(t, http.StatusCreated, invite("finance@example.com", models.AdminRoleSupport).Code)
+	var staleToken string
+	require.NoError(t, db.Pool.QueryRow(ctx, "SELECT token FROM admin_invites WHERE email = $1", "finance@example.com").Scan(&staleToken))
+
+	rr := invite("finance@example.com", models.AdminRoleFinance)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "token")
+
+	// The token only ever goes out by email
+	var token string
+	require.NoError(t, db.Pool.QueryRow(ctx, "SELECT token FROM admin_invites WHERE email = $1", "finance@example.com").Scan(&token))
+
+	assert.Equal(t, http.StatusBadRequest, accept(staleToken, "password123").Code)
+	assert.Equal(t, http.StatusBadRequest, accept(token, "short").Code)
+
+	rr = accept(token, "password123")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var response models.AuthResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, models.RoleAdmin, response.User.Role)
+	require.NotNil(t, response.User.AdminRole)
+	assert.Equal(t, models.AdminRoleFinance, *response.User.AdminRole)
+	assert.NotNil(t, response.User.EmailVerifiedAt)
+
+	allowed, err := accessService.HasPermission(ctx, response.User.ID, models.PermPayoutsApprove)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = accessService.HasPermission(ctx, response.User.ID, models.PermAdminsManage)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// Invites are single use
+	assert.Equal(t, http.StatusBadRequest, accept(token, "password123").Code)
+}
+
+func TestTeamHandler_LastSuperAdmin_Integration(t *testing.T) {
+	handler, db, authService, _, cleanup := setupTeamHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	super, err := authService.CreateAdmin(ctx, "super@example.com", "password123", "Super Admin")
+	require.NoError(t, err)
+	other, err := authService.CreateAdmin(ctx, "other@example.com", "password123", "Other Admin")
+	require.NoError(t, err)
+
+	update := func(actor, target uuid.UUID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/v1/admin/admins/"+target.String(), bytes.NewReader([]byte(body)))
+		req = req.WithContext(createUserContext(actor, "admin"))
+		req = withURLParam(req, "id", target.String())
+		rr := httptest.NewRecorder()
+		handler.UpdateAdmin(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusBadRequest, update(super.ID, other.ID, `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, update(super.ID, other.ID, `{"admin_role": "janitor"}`).Code)
+
+	// With two super admins, one can be demoted
+	rr := update(super.ID, other.ID, `{"admin_role": "support"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var updated models.User
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+	require.NotNil(t, updated.AdminRole)
+	assert.Equal(t, models.AdminRoleSupport, *updated.AdminRole)
+
+	// The remaining one can be neither demoted nor blocked
+	assert.Equal(t, http.StatusConflict, update(other.ID, super.ID, `{"admin_role": "finance"}`).Code)
+	assert.Equal(t, http.StatusConflict, update(other.ID, super.ID, `{"is_blocked": true}`).Code)
+	assert.Equal(t, http.StatusConflict, update(other.ID, super.ID, `{"admin_role": "super_admin", "is_blocked": true}`).Code)
+
+	var role string
+	var blocked bool
+	require.NoError(t, db.Pool.QueryRow(ctx, "SELECT admin_role, is_blocked FROM users WHERE id = $1", super.ID).Scan(&role, &blocked))
+	assert.Equal(t, string(models.AdminRoleSuperAdmin), role)
+	assert.False(t, blocked)
+
+	// Role and access change together
+	rr = update(super.ID, other.ID, `{"admin_role": "finance", "is_blocked": true}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+	require.NotNil(t, updated.AdminRole)
+	assert.Equal(t, models.AdminRoleFinance, *updated.AdminRole)
+	assert.True(t, updated.IsBlocked)
+
+	// Ordinary users are not part of the team
+	referrer, err := authService.Register(ctx, &models.RegisterRequest{
+		Email:    "referrer@example.com",
+		Password: "password123",
+		Name:     "Referrer",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, update(super.ID, referrer.User.ID, `{"is_blocked": true}`).Code)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// recentActivityLimit is how many of each kind of recent activity the user
+// detail view includes
+const recentActivityLimit = 5
+
+// UserDirectoryHandler lets admins find and look into user accounts
+type UserDirectoryHandler struct {
+	userRepo         *repository.UserRepository
+	referralRepo     *repository.ReferralRepository
+	clickRepo        *repository.ClickRepository
+	payoutRepo       *repository.PayoutRepository
+	ledgerRepo       *repository.LedgerRepository
+	loginHistoryRepo *repository.LoginHistoryRepository
+	bankChangeRepo   *repository.BankChangeRepository
+}
+
+func NewUserDirectoryHandler(
+	userRepo *repository.UserRepository,
+	referralRepo *repository.ReferralRepository,
+	clickRepo *repository.ClickRepository,
+	payoutRepo *repository.PayoutRepository,
+	ledgerRepo *repository.LedgerRepository,
+	loginHistoryRepo *repository.LoginHistoryRepository,
+	bankChangeRepo *repository.BankChangeRepository,
+) *UserDirectoryHandler {
+	return &UserDirectoryHandler{
+		userRepo:         userRepo,
+		referralRepo:     referralRepo,
+		clickRepo:        clickRepo,
+		payoutRepo:       payoutRepo,
+		ledgerRepo:       ledgerRepo,
+		loginHistoryRepo: loginHistoryRepo,
+		bankChangeRepo:   bankChangeRepo,
+	}
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description Search user accounts, newest first. q matches name, email, phone or referral code.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param q query string false "Search text"
+// @Param role query string false "Role (user or admin)"
+// @Param is_blocked query bool false "Only blocked or only active accounts"
+// @Param has_bank_details query bool false "Only accounts with or without bank details"
+// @Param bank_name_mismatch query bool false "Only accounts whose verified bank account holder does or does not match their name"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse{data=[]models.User}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/admin/users [get]
+func (h *UserDirectoryHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+
+	filter := models.UserFilter{Search: query.Get("q")}
+
+	if role := query.Get("role"); role != "" {
+		if role != string(models.RoleUser) && role != string(models.RoleAdmin) {
+			respondError(w, http.StatusBadRequest, "role must be user or admin")
+			return
+		}
+		roleFilter := models.Role(role)
+		filter.Role = &roleFilter
+	}
+
+	if blocked := query.Get("is_blocked"); blocked != "" {
+		b, err := strconv.ParseBool(blocked)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "is_blocked must be true or false")
+			return
+		}
+		filter.IsBlocked = &b
+	}
+
+	if bank := query.Get("has_bank_details"); bank != "" {
+		b, err := strconv.ParseBool(bank)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "has_bank_details must be true or false")
+			return
+		}
+		filter.HasBankDetails = &b
+	}
+	if mismatch := query.Get("bank_name_mismatch"); mismatch != "" {
+		b, err := strconv.ParseBool(mismatch)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "bank_name_mismatch must be true or false")
+			return
+		}
+		filter.BankNameMismatch = &b
+	}
+
+	users, total, err := h.userRepo.Search(r.Context(), filter, page, perPage)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get users: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	respondJSON(w, http.StatusOK, models.PaginatedResponse{
+		Data:       users,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+// GetUser godoc
+// @Summary Get user details
+// @Description Get a user's profile with their referral stats, link clicks, balance, and most recent referrals, payouts and sign-ins
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.AdminUserDetail
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{id} [get]
+func (h *UserDirectoryHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := h.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if err == repository.ErrUserNotFound {
+			respondError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to get user: "+err.Error())
+		return
+	}
+
+	detail := models.AdminUserDetail{
+		User:            *user,
+		RecentReferrals: []models.Referral{},
+		RecentPayouts:   []models.Payout{},
+		RecentLogins:    []models.LoginEvent{},
+	}
+
+	detail.Referrals.Total, detail.Referrals.PaidEarnings, detail.Referrals.PendingEarnings, err = h.referralRepo.GetStatsByReferrer(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get referral stats: "+err.Error())
+		return
+	}
+
+	detail.Clicks, err = h.clickRepo.GetClickCountByReferralCode(ctx, user.ReferralCode)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get clicks: "+err.Error())
+		return
+	}
+
+	summary, err := h.ledgerRepo.UserSummary(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get balance: "+err.Error())
+		return
+	}
+	detail.Balance = *summary
+
+	referrals, _, err := h.referralRepo.ListByReferrer(ctx, userID, 1, recentActivityLimit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get referrals: "+err.Error())
+		return
+	}
+	detail.RecentReferrals = append(detail.RecentReferrals, referrals...)
+
+	payouts, _, err := h.payoutRepo.ListByUser(ctx, userID, 1, recentActivityLimit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get payouts: "+err.Error())
+		return
+	}
+	detail.RecentPayouts = append(detail.RecentPayouts, payouts...)
+
+	logins, _, err := h.loginHistoryRepo.ListByUser(ctx, userID, 1, recentActivityLimit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get login history: "+err.Error())
+		return
+	}
+	detail.RecentLogins = append(detail.RecentLogins, logins...)
+
+	respondJSON(w, http.StatusOK, detail)
+}
+
+// GetBankChanges godoc
+// @Summary Get bank detail change history
+// @Description List every bank detail change requested for a user, newest first, including unconfirmed, cancelled and expired requests and the details each confirmed change replaced
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {array} models.BankDetailChange
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{id}/bank-changes [get]
+func (h *UserDirectoryHandler) GetBankChanges(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := h.userRepo.GetByID(ctx, userID); err != nil {
+		if err == repository.ErrUserNotFound {
+			respondError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to get user: "+err.Error())
+		return
+	}
+
+	changes, err := h.bankChangeRepo.ListByUser(ctx, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get bank detail changes: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, changes)
+}
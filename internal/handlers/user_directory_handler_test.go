@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserDirectoryHandler_InvalidParams(t *testing.T) {
+	handler := NewUserDirectoryHandler(nil, nil, nil, nil, nil, nil, nil)
+
+	for _, query := range []string{"role=owner", "is_blocked=maybe", "has_bank_details=sometimes", "bank_name_mismatch=perhaps"} {
+		req := httptest.NewRequest("GET", "/api/v1/admin/users?"+query, nil)
+		rr := httptest.NewRecorder()
+
+		handler.ListUsers(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	req := withURLParam(httptest.NewRequest("GET", "/api/v1/admin/users/nope", nil), "id", "nope")
+	rr := httptest.NewRecorder()
+	handler.GetUser(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUserDirectoryHandler_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	userRepo := repository.NewUserRepository(db)
+	referralRepo := repository.NewReferralRepository(db, nil)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(db)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, utils.NewJWTManager("test-secret", "test-refresh", time.Minute, time.Hour))
+	handler := NewUserDirectoryHandler(userRepo, referralRepo, repository.NewClickRepository(db), repository.NewPayoutRepository(db), repository.NewLedgerRepository(db), loginHistoryRepo, repository.NewBankChangeRepository(db))
+
+	ada, err := authService.Register(ctx, &models.RegisterRequest{
+		Email: "ada@example.com", Password: "password123", Name: "Ada Lovelace", Phone: "08011111111",
+		BankName: "Test Bank", AccountNumber: "0123456789", AccountName: "Ada Lovelace",
+	})
+	require.NoError(t, err)
+	_, err = authService.Register(ctx, &models.RegisterRequest{
+		Email: "grace@example.com", Password: "password123", Name: "Grace 100%", Phone: "08022222222",
+	})
+	require.NoError(t, err)
+	_, err = authService.CreateAdmin(ctx, "admin@example.com", "password123", "Admin")
+	require.NoError(t, err)
+
+	list := func(query string) []models.User {
+		req := httptest.NewRequest("GET", "/api/v1/admin/users?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.ListUsers(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var response struct {
+			Data  []models.User `json:"data"`
+			Total int64         `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, int64(len(response.Data)), response.Total)
+		return response.Data
+	}
+
+	assert.Len(t, list(""), 3)
+	assert.Len(t, list("role=user"), 2)
+	assert.Len(t, list("role=admin"), 1)
+	assert.Len(t, list("q=LOVELACE"), 1)
+	assert.Len(t, list("q=0802"), 1)
+	assert.Len(t, list("q="+ada.User.ReferralCode), 1)
+	assert.Len(t, list("role=user&has_bank_details=true"), 1)
+	assert.Len(t, list("role=user&has_bank_details=false"), 1)
+	assert.Len(t, list("is_blocked=true"), 0)
+
+	_, err = db.Pool.Exec(ctx, "UPDATE users SET bank_verified_at = NOW(), bank_name_mismatch = TRUE WHERE id = $1", ada.User.ID)
+	require.NoError(t, err)
+	flagged := list("bank_name_mismatch=true")
+	require.Len(t, flagged, 1)
+	assert.Equal(t, ada.User.ID, flagged[0].ID)
+	assert.True(t, flagged[0].BankNameMismatch)
+	assert.NotNil(t, flagged[0].BankVerifiedAt)
+
+	// Wildcards are matched literally
+	assert.Len(t, list("q=%25"), 1)
+	assert.Len(t, list("q=_"), 0)
+
+	referrerID := ada.User.ID
+	require.NoError(t, referralRepo.Create(ctx, &models.Referral{
+		ID:            uuid.New(),
+		ReferrerID:    &referrerID,
+		ReferredName:  "Student",
+		ReferredEmail: "student@example.com",
+		ReferredPhone: "08033333333",
+		Course:        "Go",
+		CoursePrice:   100000,
+		Earnings:      10000,
+		Status:        "pending",
+	}))
+	require.NoError(t, loginHistoryRepo.Create(ctx, &models.LoginEvent{UserID: ada.User.ID, IPAddress: "10.0.0.1", UserAgent: "test", Success: true}))
+
+	req := withURLParam(httptest.NewRequest("GET", "/api/v1/admin/users/"+ada.User.ID.String(), nil), "id", ada.User.ID.String())
+	rr := httptest.NewRecorder()
+	handler.GetUser(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var detail models.AdminUserDetail
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &detail))
+	assert.Equal(t, "ada@example.com", detail.User.Email)
+	assert.Equal(t, 1, detail.Referrals.Total)
+	assert.Equal(t, int64(10000), detail.Referrals.PendingEarnings)
+	assert.Len(t, detail.RecentReferrals, 1)
+	assert.Len(t, detail.RecentLogins, 1)
+	assert.NotNil(t, detail.RecentPayouts)
+	assert.NotContains(t, rr.Body.String(), "password")
+
+	missing := uuid.New().String()
+	req = withURLParam(httptest.NewRequest("GET", "/api/v1/admin/users/"+missing, nil), "id", missing)
+	rr = httptest.NewRecorder()
+	handler.GetUser(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
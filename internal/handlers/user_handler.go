@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
 	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -15,14 +18,18 @@ type UserHandler struct {
 	userRepo     *repository.UserRepository
 	referralRepo *repository.ReferralRepository
 	clickRepo    *repository.ClickRepository
+	ledgerRepo   *repository.LedgerRepository
+	bankAccounts *services.BankAccountService
 	validate     *validator.Validate
 }
 
-func NewUserHandler(userRepo *repository.UserRepository, referralRepo *repository.ReferralRepository, clickRepo *repository.ClickRepository) *UserHandler {
+func NewUserHandler(userRepo *repository.UserRepository, referralRepo *repository.ReferralRepository, clickRepo *repository.ClickRepository, ledgerRepo *repository.LedgerRepository, bankAccounts *services.BankAccountService) *UserHandler {
 	return &UserHandler{
 		userRepo:     userRepo,
 		referralRepo: referralRepo,
 		clickRepo:    clickRepo,
+		ledgerRepo:   ledgerRepo,
+		bankAccounts: bankAccounts,
 		validate:     validator.New(),
 	}
 }
@@ -43,20 +50,27 @@ func (h *UserHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	totalCount, totalEarnings, pendingEarnings, err := h.referralRepo.GetStatsByReferrer(r.Context(), claims.UserID)
+	totalCount, _, _, err := h.referralRepo.GetStatsByReferrer(r.Context(), claims.UserID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to get stats: "+err.Error())
 		return
 	}
 
+	summary, err := h.ledgerRepo.UserSummary(r.Context(), claims.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get balance: "+err.Error())
+		return
+	}
+
 	// Get click count for user's referral code
 	clickCount, _ := h.clickRepo.GetClickCountByUserID(r.Context(), claims.UserID)
 
 	stats := models.DashboardStats{
-		TotalEarnings:  totalEarnings,
-		PendingBalance: pendingEarnings,
-		TotalReferrals: totalCount,
-		TotalClicks:    clickCount,
+		TotalEarnings:     summary.PaidOut,
+		PendingBalance:    summary.Balance,
+		TotalPaidEarnings: summary.PaidOut,
+		TotalReferrals:    totalCount,
+		TotalClicks:       clickCount,
 	}
 
 	respondJSON(w, http.StatusOK, stats)
@@ -109,13 +123,60 @@ func (h *UserHandler) GetMyReferrals(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetLedger godoc
+// @Summary Get my earnings statement
+// @Description Get paginated ledger entries affecting the user's balance, newest first. Positive amounts increased the balance.
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/user/ledger [get]
+func (h *UserHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 10
+	}
+
+	lines, total, err := h.ledgerRepo.ListByUser(r.Context(), claims.UserID, page, perPage)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get ledger: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / perPage
+	if int(total)%perPage > 0 {
+		totalPages++
+	}
+
+	respondJSON(w, http.StatusOK, models.PaginatedResponse{
+		Data:       lines,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
 // GetProfile godoc
 // @Summary Get user profile
-// @Description Get current user's profile
+// @Description Get current user's profile, with any bank detail change still awaiting email confirmation
 // @Tags User
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} models.User
+// @Success 200 {object} models.ProfileResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/user/profile [get]
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
@@ -131,20 +192,29 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusOK, user)
+	pending, err := h.bankAccounts.Pending(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get pending bank change: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.ProfileResponse{User: user, PendingBankChange: pending})
 }
 
 // UpdateProfile godoc
 // @Summary Update user profile
-// @Description Update current user's profile
+// @Description Update current user's profile. Bank details are given as a bank code (from GET /banks) and account number; the account is resolved with the payout provider and the bank and account names are taken from the result. An account whose holder name does not match the user's name is flagged with bank_name_mismatch, or refused if the server is configured to. New bank details only take effect once confirmed from the link emailed to the user, and are returned as pending_bank_change until then.
 // @Tags User
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param request body models.UpdateProfileRequest true "Profile update"
-// @Success 200 {object} models.User
+// @Success 200 {object} models.ProfileResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/user/profile [patch]
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	claims, ok := middleware.GetUserFromContext(r.Context())
@@ -177,22 +247,82 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	if req.Phone != "" {
 		user.Phone = req.Phone
 	}
-	if req.BankName != "" {
-		user.BankName = req.BankName
+
+	h.bankAccounts.Recheck(user)
+
+	// New bank details are checked against the updated name
+	var pending *models.BankDetailChange
+	if req.BankCode != "" {
+		pending, err = h.bankAccounts.RequestChange(r.Context(), user, req.BankCode, req.AccountNumber, middleware.ClientIP(r))
+		if err != nil {
+			respondBankAccountError(w, err)
+			return
+		}
 	}
-	if req.AccountNumber != "" {
-		user.AccountNumber = req.AccountNumber
+
+	if err := h.userRepo.UpdateProfile(r.Context(), user); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to update profile: "+err.Error())
+		return
 	}
-	if req.AccountName != "" {
-		user.AccountName = req.AccountName
+
+	if pending == nil {
+		pending, err = h.bankAccounts.Pending(r.Context(), user.ID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to get pending bank change: "+err.Error())
+			return
+		}
 	}
 
-	if err := h.userRepo.Update(r.Context(), user); err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to update profile: "+err.Error())
+	respondJSON(w, http.StatusOK, models.ProfileResponse{User: user, PendingBankChange: pending})
+}
+
+// ConfirmBankChange godoc
+// @Summary Confirm bank detail change
+// @Description Apply a pending bank detail change using the token from the confirmation email. If it replaces existing details, payouts are held for a cooldown period and the change is reported as hold_until.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.ConfirmBankChangeRequest true "Confirmation token"
+// @Success 200 {object} models.BankDetailChange
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/confirm-bank-change [post]
+func (h *UserHandler) ConfirmBankChange(w http.ResponseWriter, r *http.Request) {
+	var req models.ConfirmBankChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondError(w, http.StatusBadRequest, formatValidationError(err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, user)
+	change, err := h.bankAccounts.ConfirmChange(r.Context(), req.Token)
+	if err != nil {
+		if err == repository.ErrTokenNotFound || err == repository.ErrTokenExpired {
+			respondError(w, http.StatusBadRequest, "invalid or expired confirmation token")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to confirm bank change: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, change)
+}
+
+func respondBankAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownBank), errors.Is(err, services.ErrAccountNotResolved):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrAccountNameMismatch):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, services.ErrBankVerificationUnavailable):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		log.Printf("failed to verify bank account: %v", err)
+		respondError(w, http.StatusBadGateway, services.ErrBankVerificationFailed.Error())
+	}
 }
 
 func decodeJSON(r *http.Request, v interface{}) error {
@@ -9,9 +9,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cirvee/referral-backend/internal/banks"
+	"github.com/cirvee/referral-backend/internal/config"
 	"github.com/cirvee/referral-backend/internal/database"
 	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/paystack"
 	"github.com/cirvee/referral-backend/internal/repository"
 	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/cirvee/referral-backend/internal/utils"
@@ -27,7 +31,7 @@ func setupUserHandler(t *testing.T) (*UserHandler, *database.DB, uuid.UUID, func
 	userRepo := repository.NewUserRepository(db)
 	referralRepo := repository.NewReferralRepository(db, nil)
 	clickRepo := repository.NewClickRepository(db)
-	authService := services.NewAuthService(userRepo, jwtManager)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager)
 
 	// Create a test user
 	ctx := context.Background()
@@ -41,11 +45,66 @@ func setupUserHandler(t *testing.T) (*UserHandler, *database.DB, uuid.UUID, func
 		t.Fatalf("Failed to create test user: %v", err)
 	}
 
-	handler := NewUserHandler(userRepo, referralRepo, clickRepo)
+	handler := NewUserHandler(userRepo, referralRepo, clickRepo, repository.NewLedgerRepository(db), fakeBankAccountService(t, db, false))
 
 	return handler, db, response.User.ID, cleanup
 }
 
+// fakeBankAccountService verifies accounts against a fake Paystack that
+// knows one bank (058) and resolves 0123456789 to UPDATED NAME and
+// 1111111111 to GRACE HOPPER. Replaced accounts are held for an hour.
+func fakeBankAccountService(t *testing.T, db *database.DB, rejectMismatch bool) *services.BankAccountService {
+	respond := func(w http.ResponseWriter, status int, data interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": status == http.StatusOK, "message": http.StatusText(status), "data": data,
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bank", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK, []paystack.Bank{{Name: "Guaranty Trust Bank", Code: "058", Active: true}})
+	})
+	mux.HandleFunc("/bank/resolve", func(w http.ResponseWriter, r *http.Request) {
+		names := map[string]string{"0123456789": "UPDATED NAME", "1111111111": "GRACE HOPPER"}
+		number := r.URL.Query().Get("account_number")
+		if name, ok := names[number]; ok {
+			respond(w, http.StatusOK, paystack.ResolvedAccount{AccountNumber: number, AccountName: name})
+			return
+		}
+		respond(w, http.StatusUnprocessableEntity, nil)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := paystack.NewClient(&config.PaystackConfig{SecretKey: "sk_test", BaseURL: server.URL})
+	return newBankAccountService(db, payouts.NewPaystack(client), rejectMismatch)
+}
+
+func newBankAccountService(db *database.DB, provider payouts.PayoutProvider, rejectMismatch bool) *services.BankAccountService {
+	return services.NewBankAccountService(repository.NewBankChangeRepository(db), repository.NewUserRepository(db), provider, banks.NewDirectory(provider, nil), services.NewEmailService(&config.SMTPConfig{}), rejectMismatch, time.Hour)
+}
+
+// confirmBankChange confirms the user's pending bank detail change with the
+// token that was emailed to them
+func confirmBankChange(t *testing.T, handler *UserHandler, db *database.DB, userID uuid.UUID) *httptest.ResponseRecorder {
+	var token string
+	err := db.Pool.QueryRow(context.Background(), "SELECT token FROM bank_detail_changes WHERE user_id = $1 AND status = 'pending'", userID).Scan(&token)
+	require.NoError(t, err)
+
+	return confirmBankChangeToken(handler, token)
+}
+
+func confirmBankChangeToken(handler *UserHandler, token string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(models.ConfirmBankChangeRequest{Token: token})
+	req := httptest.NewRequest("POST", "/api/v1/auth/confirm-bank-change", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ConfirmBankChange(rr, req)
+	return rr
+}
+
 func createUserContext(userID uuid.UUID, role string) context.Context {
 	claims := &utils.Claims{
 		UserID: userID,
@@ -172,7 +231,7 @@ func TestUserHandler_GetProfile_Unauthorized(t *testing.T) {
 }
 
 func TestUserHandler_UpdateProfile(t *testing.T) {
-	handler, _, userID, cleanup := setupUserHandler(t)
+	handler, db, userID, cleanup := setupUserHandler(t)
 	defer cleanup()
 
 	ctx := createUserContext(userID, "user")
@@ -180,9 +239,8 @@ func TestUserHandler_UpdateProfile(t *testing.T) {
 	payload := models.UpdateProfileRequest{
 		Name:          "Updated Name",
 		Phone:         "08087654321",
-		BankName:      "GTBank",
+		BankCode:      "058",
 		AccountNumber: "0123456789",
-		AccountName:   "Updated Account Name",
 	}
 	body, _ := json.Marshal(payload)
 
@@ -195,13 +253,187 @@ func TestUserHandler_UpdateProfile(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, rr.Code)
 
-	var user models.User
-	err := json.Unmarshal(rr.Body.Bytes(), &user)
+	var profile models.ProfileResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &profile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Updated Name", profile.Name)
+	assert.Equal(t, "08087654321", profile.Phone)
+
+	// Bank details wait for the emailed confirmation
+	assert.Empty(t, profile.AccountNumber)
+	require.NotNil(t, profile.PendingBankChange)
+	assert.Equal(t, "Guaranty Trust Bank", profile.PendingBankChange.BankName)
+	assert.Equal(t, "UPDATED NAME", profile.PendingBankChange.AccountName)
+	assert.NotContains(t, rr.Body.String(), "token")
+
+	rr = confirmBankChange(t, handler, db, userID)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var change models.BankDetailChange
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &change))
+	assert.Equal(t, models.BankChangeConfirmed, change.Status)
+	assert.Nil(t, change.HoldUntil, "a first account is not held")
+
+	user, err := handler.userRepo.GetByID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, "058", user.BankCode)
+	assert.Equal(t, "Guaranty Trust Bank", user.BankName)
+	assert.Equal(t, "0123456789", user.AccountNumber)
+	assert.Equal(t, "UPDATED NAME", user.AccountName)
+	assert.NotNil(t, user.BankVerifiedAt)
+	assert.False(t, user.BankNameMismatch)
+	assert.Nil(t, user.BankHoldUntil)
+
+	// Links only work once
+	rr = confirmBankChangeToken(handler, "used-or-unknown")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUserHandler_UpdateProfile_BankAccount(t *testing.T) {
+	handler, db, userID, cleanup := setupUserHandler(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	update := func(handler *UserHandler, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/v1/user/profile", bytes.NewReader([]byte(payload)))
+		req = req.WithContext(createUserContext(userID, "user"))
+		rr := httptest.NewRecorder()
+		handler.UpdateProfile(rr, req)
+		return rr
+	}
+
+	// Someone else's account is stored but flagged
+	rr := update(handler, `{"bank_code": "058", "account_number": "1111111111", "account_name": "Test User"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, http.StatusOK, confirmBankChange(t, handler, db, userID).Code)
+
+	user, err := handler.userRepo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "GRACE HOPPER", user.AccountName, "client-sent account name must be ignored")
+	assert.True(t, user.BankNameMismatch)
+
+	// Renaming to match the account holder clears the flag
+	rr = update(handler, `{"name": "Grace Hopper"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var profile models.ProfileResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &profile))
+	assert.False(t, profile.BankNameMismatch)
+	assert.Nil(t, profile.PendingBankChange)
+
+	stored, err := handler.userRepo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "1111111111", stored.AccountNumber)
+	assert.False(t, stored.BankNameMismatch)
+	assert.NotNil(t, stored.BankVerifiedAt)
+
+	// A newer request invalidates the earlier link
+	require.Equal(t, http.StatusOK, update(handler, `{"bank_code": "058", "account_number": "0123456789"}`).Code)
+	var staleToken string
+	require.NoError(t, db.Pool.QueryRow(ctx, "SELECT token FROM bank_detail_changes WHERE user_id = $1 AND status = 'pending'", userID).Scan(&staleToken))
+	require.Equal(t, http.StatusOK, update(handler, `{"bank_code": "058", "account_number": "0123456789"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, confirmBankChangeToken(handler, staleToken).Code)
+
+	req := httptest.NewRequest("GET", "/api/v1/user/profile", nil)
+	req = req.WithContext(createUserContext(userID, "user"))
+	rr = httptest.NewRecorder()
+	handler.GetProfile(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &profile))
+	require.NotNil(t, profile.PendingBankChange)
+	assert.Equal(t, "0123456789", profile.PendingBankChange.AccountNumber)
+	assert.Equal(t, "1111111111", profile.AccountNumber, "unconfirmed details must not be applied")
+
+	// Replacing an account holds payouts
+	rr = confirmBankChange(t, handler, db, userID)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var change models.BankDetailChange
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &change))
+	require.NotNil(t, change.PreviousAccountNumber)
+	assert.Equal(t, "1111111111", *change.PreviousAccountNumber)
+	require.NotNil(t, change.HoldUntil)
+
+	stored, err = handler.userRepo.GetByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", stored.AccountNumber)
+	assert.True(t, stored.BankNameMismatch)
+	require.NotNil(t, stored.BankHoldUntil)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *stored.BankHoldUntil, time.Minute)
+
+	var payoutID uuid.UUID
+	require.NoError(t, db.Pool.QueryRow(ctx, "INSERT INTO payouts (user_id, amount) VALUES ($1, 10000) RETURNING id", userID).Scan(&payoutID))
+	payoutService := services.NewPayoutService(repository.NewPayoutRepository(db), repository.NewReferralRepository(db, nil), handler.userRepo, nil, nil)
+	err = payoutService.UpdateStatus(ctx, payoutID, models.PayoutStatusApproved, userID)
+	assert.ErrorIs(t, err, services.ErrBankChangeHold)
+
+	// Admins see every request, newest first
+	directory := NewUserDirectoryHandler(handler.userRepo, nil, nil, nil, nil, nil, repository.NewBankChangeRepository(db))
+	req = withURLParam(httptest.NewRequest("GET", "/api/v1/admin/users/"+userID.String()+"/bank-changes", nil), "id", userID.String())
+	rr = httptest.NewRecorder()
+	directory.GetBankChanges(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var changes []models.BankDetailChange
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &changes))
+	require.Len(t, changes, 3)
+	assert.Equal(t, models.BankChangeConfirmed, changes[0].Status)
+	assert.Equal(t, models.BankChangeCancelled, changes[1].Status)
+	assert.Equal(t, models.BankChangeConfirmed, changes[2].Status)
+
+	tests := []struct {
+		name    string
+		handler *UserHandler
+		payload string
+		code    int
+	}{
+		{"unknown bank", handler, `{"bank_code": "999", "account_number": "0123456789"}`, http.StatusBadRequest},
+		{"unresolvable account", handler, `{"bank_code": "058", "account_number": "0000000000"}`, http.StatusBadRequest},
+		{"mismatch rejected", NewUserHandler(handler.userRepo, nil, nil, nil, fakeBankAccountService(t, db, true)), `{"name": "Test User", "bank_code": "058", "account_number": "1111111111"}`, http.StatusUnprocessableEntity},
+		{"paystack not configured", NewUserHandler(handler.userRepo, nil, nil, nil, newBankAccountService(db, payouts.NewPaystack(paystack.NewClient(&config.PaystackConfig{})), false)), `{"bank_code": "058", "account_number": "0123456789"}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := update(tt.handler, tt.payload)
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+		})
+	}
+
+	// Failed verifications leave the stored account and name alone
+	stored, err = handler.userRepo.GetByID(ctx, userID)
 	require.NoError(t, err)
+	assert.Equal(t, "0123456789", stored.AccountNumber)
+	assert.Equal(t, "Grace Hopper", stored.Name)
+}
+
+func TestUserHandler_UpdateProfile_InvalidBankDetails(t *testing.T) {
+	handler := NewUserHandler(nil, nil, nil, nil, nil)
+
+	for _, payload := range []string{
+		`{"bank_code": "058"}`,
+		`{"account_number": "0123456789"}`,
+		`{"bank_code": "058", "account_number": "12345"}`,
+		`{"bank_code": "058", "account_number": "01234abcde"}`,
+	} {
+		req := httptest.NewRequest("PATCH", "/api/v1/user/profile", bytes.NewReader([]byte(payload)))
+		req = req.WithContext(createUserContext(uuid.New(), "user"))
+		rr := httptest.NewRecorder()
 
-	assert.Equal(t, "Updated Name", user.Name)
-	assert.Equal(t, "08087654321", user.Phone)
-	assert.Equal(t, "GTBank", user.BankName)
+		handler.UpdateProfile(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, payload)
+	}
+}
+
+func TestUserHandler_ConfirmBankChange_InvalidRequest(t *testing.T) {
+	handler := NewUserHandler(nil, nil, nil, nil, nil)
+
+	for _, payload := range []string{`{}`, `{"token": ""}`, `not json`} {
+		req := httptest.NewRequest("POST", "/api/v1/auth/confirm-bank-change", bytes.NewReader([]byte(payload)))
+		rr := httptest.NewRecorder()
+
+		handler.ConfirmBankChange(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, payload)
+	}
 }
 
 func TestUserHandler_UpdateProfile_InvalidJSON(t *testing.T) {
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/flutterwave"
+	"github.com/cirvee/referral-backend/internal/paystack"
+	"github.com/cirvee/referral-backend/internal/services"
+)
+
+type WebhookHandler struct {
+	cfg            *config.PaystackConfig
+	flutterwaveCfg *config.FlutterwaveConfig
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(cfg *config.PaystackConfig, flutterwaveCfg *config.FlutterwaveConfig, webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		cfg:            cfg,
+		flutterwaveCfg: flutterwaveCfg,
+		webhookService: webhookService,
+	}
+}
+
+// Paystack godoc
+// @Summary Receive Paystack webhook
+// @Description Receives Paystack events. The x-paystack-signature header must be the HMAC-SHA512 of the body keyed with the secret key. Transfer events update the matching payout; repeated deliveries are acknowledged without being applied twice.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param x-paystack-signature header string true "HMAC-SHA512 signature"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/webhooks/paystack [post]
+func (h *WebhookHandler) Paystack(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	if !paystack.VerifySignature(h.cfg.SecretKey, body, r.Header.Get("x-paystack-signature")) {
+		respondError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	if err := h.webhookService.HandlePaystackEvent(r.Context(), body); err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookPayload) {
+			respondError(w, http.StatusBadRequest, "invalid webhook payload")
+			return
+		}
+		// A non-2xx response makes Paystack retry the delivery
+		respondError(w, http.StatusInternalServerError, "failed to process webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "event received"})
+}
+
+// Flutterwave godoc
+// @Summary Receive Flutterwave webhook
+// @Description Receives Flutterwave events. The verif-hash header must be the secret hash set on the Flutterwave dashboard. Completed transfers update the matching payout; repeated deliveries are acknowledged without being applied twice.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param verif-hash header string true "Secret hash"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/webhooks/flutterwave [post]
+func (h *WebhookHandler) Flutterwave(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	if !flutterwave.VerifyHash(h.flutterwaveCfg.WebhookHash, r.Header.Get("verif-hash")) {
+		respondError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	if err := h.webhookService.HandleFlutterwaveEvent(r.Context(), body); err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookPayload) {
+			respondError(w, http.StatusBadRequest, "invalid webhook payload")
+			return
+		}
+		// A non-2xx response makes Flutterwave retry the delivery
+		respondError(w, http.StatusInternalServerError, "failed to process webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "event received"})
+}
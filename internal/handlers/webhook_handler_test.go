@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPaystackSecret  = "sk_test_webhook"
+	testFlutterwaveHash = "flw_test_webhook_hash"
+)
+
+func postWebhook(handler *WebhookHandler, body []byte, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/webhooks/paystack", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-paystack-signature", signature)
+	rr := httptest.NewRecorder()
+	handler.Paystack(rr, req)
+	return rr
+}
+
+func postFlutterwaveWebhook(handler *WebhookHandler, body []byte, hash string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/webhooks/flutterwave", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("verif-hash", hash)
+	rr := httptest.NewRecorder()
+	handler.Flutterwave(rr, req)
+	return rr
+}
+
+func signWebhook(body []byte) string {
+	mac := hmac.New(sha512.New, []byte(testPaystackSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler_RejectsInvalidSignature(t *testing.T) {
+	handler := NewWebhookHandler(&config.PaystackConfig{SecretKey: testPaystackSecret}, &config.FlutterwaveConfig{WebhookHash: testFlutterwaveHash}, nil)
+	body := []byte(`{"event":"transfer.success","data":{"id":1,"reference":"ref"}}`)
+
+	rr := postWebhook(handler, body, "deadbeef")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postWebhook(handler, body, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postFlutterwaveWebhook(handler, body, "wrong-hash")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postFlutterwaveWebhook(handler, body, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestWebhookHandler_InvalidPayload(t *testing.T) {
+	handler := NewWebhookHandler(
+		&config.PaystackConfig{SecretKey: testPaystackSecret},
+		&config.FlutterwaveConfig{WebhookHash: testFlutterwaveHash},
+		services.NewWebhookService(nil, nil, nil),
+	)
+
+	for _, body := range [][]byte{[]byte(`not json`), []byte(`{"event":"transfer.success","data":{}}`)} {
+		rr := postWebhook(handler, body, signWebhook(body))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+
+	for _, body := range [][]byte{[]byte(`not json`), []byte(`{"event":"transfer.completed","data":{}}`)} {
+		rr := postFlutterwaveWebhook(handler, body, testFlutterwaveHash)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
+
+// webhookFixture is a referrer with a pending payout covering one referral
+type webhookFixture struct {
+	handler       *WebhookHandler
+	payoutRepo    *repository.PayoutRepository
+	referralRepo  *repository.ReferralRepository
+	ledgerRepo    *repository.LedgerRepository
+	ledgerService *services.LedgerService
+	payoutService *services.PayoutService
+	user          models.User
+	referral      *models.Referral
+	payout        *models.Payout
+}
+
+// setupWebhookPayout creates the fixture. provider may be nil, in which case
+// approvals move no money.
+func setupWebhookPayout(t *testing.T, db *database.DB, provider payouts.PayoutProvider) *webhookFixture {
+	ctx := context.Background()
+
+	jwtManager := utils.NewJWTManager("test-secret", "test-refresh", 15*time.Minute, 168*time.Hour)
+	userRepo := repository.NewUserRepository(db)
+	f := &webhookFixture{
+		referralRepo: repository.NewReferralRepository(db, nil),
+		payoutRepo:   repository.NewPayoutRepository(db),
+		ledgerRepo:   repository.NewLedgerRepository(db),
+	}
+	f.ledgerService = services.NewLedgerService(f.ledgerRepo, userRepo)
+	f.payoutService = services.NewPayoutService(f.payoutRepo, f.referralRepo, userRepo, f.ledgerService, provider)
+	webhookService := services.NewWebhookService(repository.NewWebhookRepository(db), f.payoutRepo, f.ledgerService)
+	f.handler = NewWebhookHandler(&config.PaystackConfig{SecretKey: testPaystackSecret}, &config.FlutterwaveConfig{WebhookHash: testFlutterwaveHash}, webhookService)
+
+	response, err := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), services.NewAccessService(userRepo, nil), nil, nil, jwtManager).Register(ctx, &models.RegisterRequest{
+		Email:    "webhook@example.com",
+		Password: "password123",
+		Name:     "Webhook User",
+		Phone:    "08012345678",
+	})
+	require.NoError(t, err)
+	f.user = response.User
+	f.user.BankName = "Guaranty Trust Bank"
+	f.user.BankCode = "058"
+	f.user.AccountNumber = "0123456789"
+	f.user.AccountName = "Webhook User"
+	require.NoError(t, userRepo.Update(ctx, &f.user))
+
+	f.referral = &models.Referral{
+		ID:            uuid.New(),
+		ReferrerID:    &f.user.ID,
+		ReferredName:  "Student",
+		ReferredEmail: "student@example.com",
+		ReferredPhone: "08000000000",
+		Course:        "Web Development",
+		CoursePrice:   750000,
+		Earnings:      10000,
+		Status:        "pending",
+	}
+	require.NoError(t, f.referralRepo.CreateWithHook(ctx, f.referral, f.ledgerService.AccrueCommission(f.referral)))
+
+	f.payout, err = f.payoutService.RequestPayout(ctx, f.user.ID, 10000)
+	require.NoError(t, err)
+
+	return f
+}
+
+// assertReleased checks that the payout failed and its referral and earnings
+// can be withdrawn again
+func (f *webhookFixture) assertReleased(t *testing.T) {
+	ctx := context.Background()
+
+	updated, err := f.payoutRepo.GetByID(ctx, f.payout.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PayoutStatusFailed, updated.Status)
+	require.NotNil(t, updated.TransferStatus)
+	assert.Equal(t, "failed", *updated.TransferStatus)
+
+	ref, err := f.referralRepo.GetByID(ctx, f.referral.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", ref.Status)
+
+	summary, err := f.ledgerRepo.UserSummary(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), summary.PaidOut)
+	assert.Equal(t, int64(10000), summary.Balance)
+}
+
+func transferFailedEvent(reference string) []byte {
+	return []byte(fmt.Sprintf(`{"event":"transfer.failed","data":{"id":%d,"reference":"%s","transfer_code":"TRF_1","status":"failed","amount":1000000}}`, time.Now().UnixNano(), reference))
+}
+
+func TestWebhookHandler_TransferFailedReleasesPayout_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	f := setupWebhookPayout(t, db, nil)
+
+	require.NoError(t, f.payoutService.UpdateStatus(ctx, f.payout.ID, models.PayoutStatusApproved, f.user.ID))
+
+	// Simulate the transfer the approval would have initiated
+	reference := f.payout.ID.String()
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE payouts SET transfer_provider = 'paystack', transfer_reference = $2, transfer_status = 'pending'
+		WHERE id = $1
+	`, f.payout.ID, reference)
+	require.NoError(t, err)
+
+	body := transferFailedEvent(reference)
+	rr := postWebhook(f.handler, body, signWebhook(body))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Redelivery is acknowledged but not applied twice
+	rr = postWebhook(f.handler, body, signWebhook(body))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	f.assertReleased(t)
+}
+
+func TestWebhookHandler_EventDuringApproval_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	f := setupWebhookPayout(t, db, nil)
+
+	// The transfer fails while the approval that records it still holds the
+	// payout row
+	reference := f.payout.ID.String()
+	locked := make(chan struct{})
+	delivered := make(chan int, 1)
+	go func() {
+		<-locked
+		body := transferFailedEvent(reference)
+		delivered <- postWebhook(f.handler, body, signWebhook(body)).Code
+	}()
+
+	settle := f.ledgerService.SettlePayout(f.user.ID)
+	err := f.payoutRepo.Approve(ctx, f.payout.ID, f.user.ID, func(ctx context.Context, tx pgx.Tx, payout *models.Payout) error {
+		if err := settle(ctx, tx, payout); err != nil {
+			return err
+		}
+		close(locked)
+
+		// The webhook waits on the payout row instead of missing the payout
+		require.Eventually(t, func() bool {
+			var waiting int
+			err := db.Pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM pg_stat_activity
+				WHERE datname = current_database() AND wait_event_type = 'Lock'
+			`).Scan(&waiting)
+			return err == nil && waiting > 0
+		}, 5*time.Second, 10*time.Millisecond)
+		select {
+		case code := <-delivered:
+			t.Fatalf("webhook answered %d before the approval committed", code)
+		default:
+		}
+
+		provider, status := payouts.ProviderPaystack, "pending"
+		payout.TransferProvider = &provider
+		payout.TransferReference = &reference
+		payout.TransferStatus = &status
+		return f.payoutRepo.RecordTransferTx(ctx, tx, payout)
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, <-delivered)
+	f.assertReleased(t)
+}
+
+func TestWebhookHandler_FlutterwaveTransferFailed_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	f := setupWebhookPayout(t, db, nil)
+
+	require.NoError(t, f.payoutService.UpdateStatus(ctx, f.payout.ID, models.PayoutStatusApproved, f.user.ID))
+
+	// Simulate the Flutterwave transfer the approval would have initiated
+	reference := f.payout.ID.String()
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE payouts SET transfer_provider = 'flutterwave', transfer_reference = $2, transfer_code = '396456', transfer_status = 'pending'
+		WHERE id = $1
+	`, f.payout.ID, reference)
+	require.NoError(t, err)
+
+	// Paystack events cannot touch a Flutterwave transfer
+	body := transferFailedEvent(reference)
+	require.Equal(t, http.StatusOK, postWebhook(f.handler, body, signWebhook(body)).Code)
+	updated, err := f.payoutRepo.GetByID(ctx, f.payout.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PayoutStatusApproved, updated.Status)
+
+	body = []byte(fmt.Sprintf(`{"event":"transfer.completed","event.type":"Transfer","data":{"id":396456,"reference":"%s","status":"FAILED","amount":10000}}`, reference))
+	rr := postFlutterwaveWebhook(f.handler, body, testFlutterwaveHash)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// Redelivery is acknowledged but not applied twice
+	rr = postFlutterwaveWebhook(f.handler, body, testFlutterwaveHash)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	f.assertReleased(t)
+}
+
+func TestPayoutTransfer_RefusedTransferUnwindsApproval_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	provider := payouts.NewFake()
+	f := setupWebhookPayout(t, db, provider)
+
+	provider.RefuseNextTransfer("insufficient balance")
+	err := f.payoutService.UpdateStatus(ctx, f.payout.ID, models.PayoutStatusApproved, f.user.ID)
+	require.ErrorIs(t, err, services.ErrTransferRejected)
+
+	f.assertReleased(t)
+}
+
+func TestPayoutTransfer_RejectRequiresFailedTransfer_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	f := setupWebhookPayout(t, db, nil)
+
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE payouts SET transfer_provider = 'paystack', transfer_reference = $2, transfer_status = 'pending'
+		WHERE id = $1
+	`, f.payout.ID, f.payout.ID.String())
+	require.NoError(t, err)
+
+	// The money may have been sent, so the referrals cannot be released
+	err = f.payoutService.UpdateStatus(ctx, f.payout.ID, models.PayoutStatusRejected, f.user.ID)
+	require.ErrorIs(t, err, repository.ErrPayoutHasTransfer)
+
+	_, err = db.Pool.Exec(ctx, `UPDATE payouts SET transfer_status = 'failed' WHERE id = $1`, f.payout.ID)
+	require.NoError(t, err)
+	require.NoError(t, f.payoutService.UpdateStatus(ctx, f.payout.ID, models.PayoutStatusRejected, f.user.ID))
+
+	ref, err := f.referralRepo.GetByID(ctx, f.referral.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", ref.Status)
+}
+
+// holdingProvider confirms a bank change for the referrer while the payout
+// recipient is created, between the approval's first bank hold check and
+// its transaction
+type holdingProvider struct {
+	*payouts.Fake
+	hold func()
+}
+
+func (p holdingProvider) CreateRecipient(ctx context.Context, name, accountNumber, bankCode string) (string, error) {
+	p.hold()
+	return p.Fake.CreateRecipient(ctx, name, accountNumber, bankCode)
+}
+
+func TestPayoutTransfer_BankChangeDuringApproval_Integration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	provider := &holdingProvider{Fake: payouts.NewFake()}
+	f := setupWebhookPayout(t, db, provider)
+	provider.hold = func() {
+		_, err := db.Pool.Exec(ctx, `UPDATE users SET bank_hold_until = NOW() + INTERVAL '1 day' WHERE id = $1`, f.user.ID)
+		require.NoError(t, err)
+	}
+
+	err := f.payoutService.UpdateStatus(ctx, f.payout.ID, models.PayoutStatusApproved, f.user.ID)
+	require.ErrorIs(t, err, services.ErrBankChangeHold)
+
+	updated, err := f.payoutRepo.GetByID(ctx, f.payout.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PayoutStatusPending, updated.Status)
+	assert.Nil(t, updated.TransferReference)
+	assert.Empty(t, provider.Transfers(), "no money is sent")
+}
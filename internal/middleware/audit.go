@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+const auditContextKey contextKey = "audit"
+
+// auditEntry collects what a handler knows about the change it made. The
+// middleware fills in the actor, request and outcome.
+type auditEntry struct {
+	action     string
+	targetType string
+	targetID   string
+	before     interface{}
+	after      interface{}
+}
+
+type AuditMiddleware struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditMiddleware(auditRepo *repository.AuditRepository) *AuditMiddleware {
+	return &AuditMiddleware{auditRepo: auditRepo}
+}
+
+// Record writes an audit event for every successful mutating request. It must
+// run after Authenticate so the actor is known. Handlers describe the change
+// with Audit; otherwise the route pattern and {id} parameter are used.
+func (m *AuditMiddleware) Record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := &auditEntry{}
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), auditContextKey, entry)))
+
+		// Failed requests change nothing worth auditing
+		if wrapped.statusCode >= http.StatusBadRequest {
+			return
+		}
+
+		event := &models.AuditEvent{
+			Action:     entry.action,
+			TargetType: entry.targetType,
+			TargetID:   entry.targetID,
+			Before:     marshalAuditState(entry.before),
+			After:      marshalAuditState(entry.after),
+			StatusCode: wrapped.statusCode,
+			RequestID:  chiMiddleware.GetReqID(r.Context()),
+			IPAddress:  ClientIP(r),
+		}
+
+		if claims, ok := GetUserFromContext(r.Context()); ok {
+			event.ActorID = &claims.UserID
+			event.ActorEmail = claims.Email
+		}
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if event.Action == "" {
+				event.Action = r.Method + " " + rctx.RoutePattern()
+			}
+			if event.TargetID == "" {
+				event.TargetID = rctx.URLParam("id")
+			}
+		}
+
+		// The response has been sent; record even if the client went away
+		if err := m.auditRepo.Create(context.WithoutCancel(r.Context()), event); err != nil {
+			log.Printf("failed to record audit event %q: %v", event.Action, err)
+		}
+	})
+}
+
+// Audit describes the change the current request made for the audit log.
+// before and after are marshalled to JSON and may be nil. It is a no-op when
+// the request is not being audited.
+func Audit(ctx context.Context, action, targetType, targetID string, before, after interface{}) {
+	entry, ok := ctx.Value(auditContextKey).(*auditEntry)
+	if !ok {
+		return
+	}
+
+	entry.action = action
+	entry.targetType = targetType
+	entry.targetID = targetID
+	entry.before = before
+	entry.after = after
+}
+
+func marshalAuditState(state interface{}) json.RawMessage {
+	if state == nil {
+		return nil
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// ClientIP returns the address a request came from: the peer address without
+// its port, which chi's RealIP middleware replaces with the forwarded address
+// when the server trusts its proxy. An address that does not parse as an IP
+// is returned as "".
+func ClientIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
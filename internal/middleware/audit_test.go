@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"203.0.113.7:51234", "203.0.113.7"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"203.0.113.7", "203.0.113.7"},
+		{"not-an-ip:80", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		assert.Equal(t, tt.want, ClientIP(req), tt.remoteAddr)
+	}
+}
+
+func TestClientIP_TrustedProxy(t *testing.T) {
+	var got string
+	handler := chiMiddleware.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientIP(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:51234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.2")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "198.51.100.1", got)
+}
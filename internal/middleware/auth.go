@@ -2,11 +2,16 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
 )
 
 type contextKey string
@@ -15,39 +20,71 @@ const (
 	UserContextKey contextKey = "user"
 )
 
+// AccessChecker decides whether a validly signed access token may still be
+// used. CheckAccess returns services.ErrUserBlocked or services.ErrTokenRevoked
+// to refuse the token.
+type AccessChecker interface {
+	CheckAccess(ctx context.Context, userID uuid.UUID, tokenVersion int) error
+	EmailVerified(ctx context.Context, userID uuid.UUID) (bool, error)
+	HasPermission(ctx context.Context, userID uuid.UUID, permission models.Permission) (bool, error)
+}
+
 type AuthMiddleware struct {
-	jwtManager *utils.JWTManager
+	jwtManager    *utils.JWTManager
+	accessChecker AccessChecker
 }
 
-func NewAuthMiddleware(jwtManager *utils.JWTManager) *AuthMiddleware {
-	return &AuthMiddleware{jwtManager: jwtManager}
+// NewAuthMiddleware creates the auth middleware. A nil accessChecker accepts
+// every validly signed token.
+func NewAuthMiddleware(jwtManager *utils.JWTManager, accessChecker AccessChecker) *AuthMiddleware {
+	return &AuthMiddleware{
+		jwtManager:    jwtManager,
+		accessChecker: accessChecker,
+	}
 }
 
-// Authenticate validates JWT token and adds claims to context
+// Authenticate validates JWT token and adds claims to context. Each error
+// carries a code so clients can tell an expired token from a blocked account.
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, `{"error": "missing authorization header"}`, http.StatusUnauthorized)
+			http.Error(w, `{"error": "missing authorization header", "code": "missing_token"}`, http.StatusUnauthorized)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, `{"error": "invalid authorization format"}`, http.StatusUnauthorized)
+			http.Error(w, `{"error": "invalid authorization format", "code": "invalid_token"}`, http.StatusUnauthorized)
 			return
 		}
 
 		claims, err := m.jwtManager.ValidateAccessToken(parts[1])
 		if err != nil {
 			if err == utils.ErrExpiredToken {
-				http.Error(w, `{"error": "token expired"}`, http.StatusUnauthorized)
+				http.Error(w, `{"error": "token expired", "code": "token_expired"}`, http.StatusUnauthorized)
 				return
 			}
-			http.Error(w, `{"error": "invalid token"}`, http.StatusUnauthorized)
+			http.Error(w, `{"error": "invalid token", "code": "invalid_token"}`, http.StatusUnauthorized)
 			return
 		}
 
+		if m.accessChecker != nil {
+			if err := m.accessChecker.CheckAccess(r.Context(), claims.UserID, claims.TokenVersion); err != nil {
+				switch {
+				case errors.Is(err, services.ErrUserBlocked):
+					http.Error(w, `{"error": "user account is blocked", "code": "account_blocked"}`, http.StatusForbidden)
+				case errors.Is(err, services.ErrTokenRevoked):
+					http.Error(w, `{"error": "token has been revoked", "code": "token_revoked"}`, http.StatusUnauthorized)
+				default:
+					// Fail closed: an unverified token is not let through
+					log.Printf("failed to check access for user %s: %v", claims.UserID, err)
+					http.Error(w, `{"error": "unable to verify session", "code": "session_check_failed"}`, http.StatusServiceUnavailable)
+				}
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), UserContextKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -73,6 +110,81 @@ func (m *AuthMiddleware) RequireRole(role models.Role) func(http.Handler) http.H
 	}
 }
 
+// RequirePermission rejects admins whose role does not grant permission. It
+// must run after Authenticate.
+func (m *AuthMiddleware) RequirePermission(permission models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetUserFromContext(r.Context())
+			if !ok {
+				http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+
+			if m.accessChecker != nil {
+				allowed, err := m.accessChecker.HasPermission(r.Context(), claims.UserID, permission)
+				if err != nil {
+					log.Printf("failed to check permission %s for user %s: %v", permission, claims.UserID, err)
+					http.Error(w, `{"error": "unable to verify session", "code": "session_check_failed"}`, http.StatusServiceUnavailable)
+					return
+				}
+				if !allowed {
+					http.Error(w, fmt.Sprintf(`{"error": "missing permission %s", "code": "permission_denied"}`, permission), http.StatusForbidden)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireVerifiedEmail rejects users who have not confirmed their email
+// address. It must run after Authenticate.
+func (m *AuthMiddleware) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		if m.accessChecker != nil {
+			verified, err := m.accessChecker.EmailVerified(r.Context(), claims.UserID)
+			if err != nil {
+				log.Printf("failed to check email verification for user %s: %v", claims.UserID, err)
+				http.Error(w, `{"error": "unable to verify session", "code": "session_check_failed"}`, http.StatusServiceUnavailable)
+				return
+			}
+			if !verified {
+				http.Error(w, `{"error": "email address is not verified", "code": "email_not_verified"}`, http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMFA rejects sessions that were not opened with a second factor. It
+// must run after Authenticate.
+func (m *AuthMiddleware) RequireMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		if !claims.MFA {
+			http.Error(w, `{"error": "two-factor authentication is required; enrol and log in again", "code": "mfa_required"}`, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // GetUserFromContext extracts user claims from context
 func GetUserFromContext(ctx context.Context) (*utils.Claims, bool) {
 	claims, ok := ctx.Value(UserContextKey).(*utils.Claims)
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/services"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAccessChecker struct {
+	err         error
+	unverified  bool
+	permissions []models.Permission
+}
+
+func (f *fakeAccessChecker) CheckAccess(ctx context.Context, userID uuid.UUID, tokenVersion int) error {
+	return f.err
+}
+
+func (f *fakeAccessChecker) EmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return !f.unverified, nil
+}
+
+func (f *fakeAccessChecker) HasPermission(ctx context.Context, userID uuid.UUID, permission models.Permission) (bool, error) {
+	for _, p := range f.permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestAuthenticate_AccessChecks(t *testing.T) {
+	jwtManager := utils.NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0, false)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		checker    AccessChecker
+		wantStatus int
+		wantCode   string
+	}{
+		{"no checker", nil, http.StatusOK, ""},
+		{"allowed", &fakeAccessChecker{}, http.StatusOK, ""},
+		{"blocked", &fakeAccessChecker{err: services.ErrUserBlocked}, http.StatusForbidden, "account_blocked"},
+		{"revoked", &fakeAccessChecker{err: services.ErrTokenRevoked}, http.StatusUnauthorized, "token_revoked"},
+		{"check unavailable", &fakeAccessChecker{err: errors.New("connection refused")}, http.StatusServiceUnavailable, "session_check_failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/user/profile", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+
+			NewAuthMiddleware(jwtManager, tt.checker).Authenticate(ok).ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantCode != "" {
+				var body map[string]string
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantCode, body["code"])
+			}
+		})
+	}
+}
+
+func TestAuthenticate_ErrorCodes(t *testing.T) {
+	jwtManager := utils.NewJWTManager("test-access-secret", "test-refresh-secret", time.Millisecond, time.Hour)
+	expired, err := jwtManager.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0, false)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode string
+	}{
+		{"missing header", "", "missing_token"},
+		{"bad format", "Token abc", "invalid_token"},
+		{"bad token", "Bearer not-a-jwt", "invalid_token"},
+		{"expired", "Bearer " + expired, "token_expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/user/profile", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			NewAuthMiddleware(jwtManager, nil).Authenticate(http.NotFoundHandler()).ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusUnauthorized, rr.Code)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantCode, body["code"])
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	jwtManager := utils.NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0, false)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, unverified := range []bool{false, true} {
+		m := NewAuthMiddleware(jwtManager, &fakeAccessChecker{unverified: unverified})
+
+		req := httptest.NewRequest("GET", "/api/v1/user/dashboard", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+
+		m.Authenticate(m.RequireVerifiedEmail(ok)).ServeHTTP(rr, req)
+
+		if !unverified {
+			assert.Equal(t, http.StatusOK, rr.Code)
+			continue
+		}
+		require.Equal(t, http.StatusForbidden, rr.Code)
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, "email_not_verified", body["code"])
+	}
+}
+
+func TestRequireMFA(t *testing.T) {
+	jwtManager := utils.NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, time.Hour)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	m := NewAuthMiddleware(jwtManager, nil)
+
+	for _, mfa := range []bool{false, true} {
+		token, err := jwtManager.GenerateAccessToken(uuid.New(), "admin@example.com", "admin", 0, mfa)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/api/v1/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+
+		m.Authenticate(m.RequireMFA(ok)).ServeHTTP(rr, req)
+
+		if mfa {
+			assert.Equal(t, http.StatusOK, rr.Code)
+			continue
+		}
+		require.Equal(t, http.StatusForbidden, rr.Code)
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, "mfa_required", body["code"])
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	jwtManager := utils.NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(uuid.New(), "support@example.com", "admin", 0, true)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	support := &fakeAccessChecker{permissions: []models.Permission{models.PermReportsRead, models.PermUsersBlock}}
+	m := NewAuthMiddleware(jwtManager, support)
+
+	tests := []struct {
+		permission models.Permission
+		wantStatus int
+	}{
+		{models.PermReportsRead, http.StatusOK},
+		{models.PermUsersBlock, http.StatusOK},
+		{models.PermPayoutsApprove, http.StatusForbidden},
+		{models.PermReferralsWrite, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.permission), func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/admin/payouts", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+
+			m.Authenticate(m.RequirePermission(tt.permission)(ok)).ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				var body map[string]string
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "permission_denied", body["code"])
+			}
+		})
+	}
+}
@@ -35,6 +35,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush or extend the write deadline of a streamed response
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func SecureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -59,6 +65,12 @@ func Recoverer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				// Handlers abort a response they have already started
+				// with http.ErrAbortHandler; let net/http drop the
+				// connection instead of appending an error body
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				log.Printf("panic recovered: %v", err)
 				http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
 			}
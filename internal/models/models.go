@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,38 +14,107 @@ const (
 	RoleUser  Role = "user"
 )
 
+// AdminRole decides what an admin may do. Roles and the permissions they
+// grant are stored in the database; these are the ones migrations create.
+type AdminRole string
+
+const (
+	AdminRoleSuperAdmin AdminRole = "super_admin"
+	AdminRoleFinance    AdminRole = "finance"
+	AdminRoleSupport    AdminRole = "support"
+)
+
+// Permission is a capability granted to admin roles
+type Permission string
+
+const (
+	PermReportsRead    Permission = "reports:read"
+	PermReferralsWrite Permission = "referrals:write"
+	PermPayoutsRead    Permission = "payouts:read"
+	PermPayoutsApprove Permission = "payouts:approve"
+	PermUsersBlock     Permission = "users:block"
+	PermCatalogWrite   Permission = "catalog:write"
+	PermLedgerAdjust   Permission = "ledger:adjust"
+	PermAuditRead      Permission = "audit:read"
+	PermAdminsManage   Permission = "admins:manage"
+)
+
+// AdminRoleInfo describes an admin role and what it grants
+type AdminRoleInfo struct {
+	Name        AdminRole    `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// AdminInvite is a pending or accepted invitation to join as an admin
+type AdminInvite struct {
+	ID         uuid.UUID  `json:"id"`
+	Email      string     `json:"email"`
+	AdminRole  AdminRole  `json:"admin_role"`
+	Token      string     `json:"-"`
+	InvitedBy  *uuid.UUID `json:"invited_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
 type User struct {
-	ID            uuid.UUID `json:"id"`
-	Email         string    `json:"email"`
-	PasswordHash  string    `json:"-"`
-	Name          string    `json:"name"`
-	Phone         string    `json:"phone"`
-	Role          Role      `json:"role"`
-	BankName      string    `json:"bank_name,omitempty"`
-	AccountNumber string    `json:"account_number,omitempty"`
-	AccountName   string    `json:"account_name,omitempty"`
-	ReferralCode  string    `json:"referral_code"`
-	IsBlocked     bool      `json:"is_blocked"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID               uuid.UUID  `json:"id"`
+	Email            string     `json:"email"`
+	PasswordHash     string     `json:"-"`
+	Name             string     `json:"name"`
+	Phone            string     `json:"phone"`
+	Role             Role       `json:"role"`
+	AdminRole        *AdminRole `json:"admin_role,omitempty"`
+	BankCode         string     `json:"bank_code,omitempty"`
+	BankName         string     `json:"bank_name,omitempty"`
+	AccountNumber    string     `json:"account_number,omitempty"`
+	AccountName      string     `json:"account_name,omitempty"`
+	BankVerifiedAt   *time.Time `json:"bank_verified_at,omitempty"`
+	BankNameMismatch bool       `json:"bank_name_mismatch"` // verified account holder's name differs from Name
+	BankHoldUntil    *time.Time `json:"bank_hold_until,omitempty"`
+	ReferralCode     string     `json:"referral_code"`
+	IsBlocked        bool       `json:"is_blocked"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// UserAccessState is what the auth middleware checks on every request
+type UserAccessState struct {
+	IsBlocked     bool         `json:"is_blocked"`
+	EmailVerified bool         `json:"email_verified"`
+	TokenVersion  int          `json:"token_version"`
+	Permissions   []Permission `json:"permissions,omitempty"`
 }
 
+// Referral statuses. Transitions are enforced by services.ReferralService.
+const (
+	ReferralStatusPending    = "pending"
+	ReferralStatusApproved   = "approved"
+	ReferralStatusProcessing = "processing"
+	ReferralStatusPaid       = "paid"
+	ReferralStatusRejected   = "rejected"
+	ReferralStatusReversed   = "reversed"
+)
+
 type Referral struct {
-	ID              uuid.UUID  `json:"id"`
-	ReferrerID      *uuid.UUID `json:"referrer_id"`
-	ReferrerName    string     `json:"referrer_name,omitempty"`
-	ReferredName    string     `json:"referred_name"`
-	ReferredEmail   string     `json:"referred_email"`
-	ReferredPhone   string     `json:"referred_phone"`
-	Course          string     `json:"course"`
-	CoursePrice     int64      `json:"course_price"`
-	Earnings        int64      `json:"earnings"`
-	Status          string     `json:"status"` // pending, paid
-	CreatedAt       time.Time  `json:"created_at"`
-	ReferrerBank    string     `json:"referrer_bank,omitempty"`
-	ReferrerAccNo   string     `json:"referrer_account_number,omitempty"`
-	ReferrerAccName string     `json:"referrer_account_name,omitempty"`
-	ReferralCode    string     `json:"referral_code,omitempty"`
+	ID               uuid.UUID  `json:"id"`
+	ReferrerID       *uuid.UUID `json:"referrer_id"`
+	ReferrerName     string     `json:"referrer_name,omitempty"`
+	ReferredName     string     `json:"referred_name"`
+	ReferredEmail    string     `json:"referred_email"`
+	ReferredPhone    string     `json:"referred_phone"`
+	Course           string     `json:"course"`
+	CoursePrice      int64      `json:"course_price"`
+	Earnings         int64      `json:"earnings"`
+	Status           string     `json:"status"` // pending, paid
+	CommissionRuleID *uuid.UUID `json:"commission_rule_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ReferrerBank     string     `json:"referrer_bank,omitempty"`
+	ReferrerAccNo    string     `json:"referrer_account_number,omitempty"`
+	ReferrerAccName  string     `json:"referrer_account_name,omitempty"`
+	ReferralCode     string     `json:"referral_code,omitempty"`
 }
 
 type ReferrerStats struct {
@@ -55,6 +125,41 @@ type ReferrerStats struct {
 	TotalEarnings int64     `json:"total_earnings"`
 	Status        string    `json:"status"` // active (usage > 0), inactive
 	IsBlocked     bool      `json:"is_blocked"`
+	BankName      string    `json:"bank_name,omitempty"`
+	AccountNumber string    `json:"account_number,omitempty"`
+	AccountName   string    `json:"account_name,omitempty"`
+}
+
+type Course struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Price      int64      `json:"price"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CommissionType string
+
+const (
+	CommissionTypeFlat       CommissionType = "flat"
+	CommissionTypePercentage CommissionType = "percentage"
+)
+
+// CommissionRule defines how referral earnings are computed for a course.
+// Value is in naira for flat rules and in basis points for percentage rules.
+type CommissionRule struct {
+	ID            uuid.UUID      `json:"id"`
+	Name          string         `json:"name"`
+	CourseID      *uuid.UUID     `json:"course_id,omitempty"` // nil applies to all courses
+	CourseName    string         `json:"course_name,omitempty"`
+	Type          CommissionType `json:"type"`
+	Value         int64          `json:"value"`
+	Cap           *int64         `json:"cap,omitempty"`
+	EffectiveFrom time.Time      `json:"effective_from"`
+	EffectiveTo   *time.Time     `json:"effective_to,omitempty"`
+	CreatedBy     *uuid.UUID     `json:"created_by,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
 }
 
 type PayoutStatus string
@@ -63,17 +168,241 @@ const (
 	PayoutStatusPending  PayoutStatus = "pending"
 	PayoutStatusApproved PayoutStatus = "approved"
 	PayoutStatusRejected PayoutStatus = "rejected"
+	PayoutStatusFailed   PayoutStatus = "failed"
+	PayoutStatusReversed PayoutStatus = "reversed"
 )
 
 type Payout struct {
-	ID         uuid.UUID    `json:"id"`
-	UserID     uuid.UUID    `json:"user_id"`
-	Amount     int64        `json:"amount"`
-	Status     PayoutStatus `json:"status"`
-	ApprovedBy *uuid.UUID   `json:"approved_by,omitempty"`
-	CreatedAt  time.Time    `json:"created_at"`
-	PaidAt     *time.Time   `json:"paid_at,omitempty"`
-	User       *User        `json:"user,omitempty"`
+	ID                uuid.UUID    `json:"id"`
+	UserID            uuid.UUID    `json:"user_id"`
+	Amount            int64        `json:"amount"`
+	Status            PayoutStatus `json:"status"`
+	ApprovedBy        *uuid.UUID   `json:"approved_by,omitempty"`
+	TransferProvider  *string      `json:"transfer_provider,omitempty"`
+	TransferReference *string      `json:"transfer_reference,omitempty"`
+	TransferCode      *string      `json:"transfer_code,omitempty"`
+	TransferStatus    *string      `json:"transfer_status,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	PaidAt            *time.Time   `json:"paid_at,omitempty"`
+	User              *User        `json:"user,omitempty"`
+	Items             []PayoutItem `json:"items,omitempty"`
+}
+
+// PayoutItem ties a payout to one of the referrals it settles
+type PayoutItem struct {
+	ID           uuid.UUID `json:"id"`
+	PayoutID     uuid.UUID `json:"payout_id"`
+	ReferralID   uuid.UUID `json:"referral_id"`
+	Amount       int64     `json:"amount"`
+	ReferredName string    `json:"referred_name"`
+	Course       string    `json:"course"`
+	Status       string    `json:"status"`
+	// PreviousStatus is the referral's status before the payout reserved it
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type LedgerEntryKind string
+
+const (
+	LedgerEntryCommission     LedgerEntryKind = "commission_accrual"
+	LedgerEntryPayout         LedgerEntryKind = "payout"
+	LedgerEntryPayoutReversal LedgerEntryKind = "payout_reversal"
+	LedgerEntryClawback       LedgerEntryKind = "clawback"
+	LedgerEntryAdjustment     LedgerEntryKind = "adjustment"
+)
+
+// Platform ledger accounts. Referrer accounts are created on demand.
+const (
+	LedgerAccountCommissionExpense = "platform:commission_expense"
+	LedgerAccountCash              = "platform:cash"
+	LedgerAccountAdjustments       = "platform:adjustments"
+)
+
+// LedgerEntry is an immutable journal entry whose postings sum to zero
+type LedgerEntry struct {
+	ID            uuid.UUID       `json:"id"`
+	Kind          LedgerEntryKind `json:"kind"`
+	Description   string          `json:"description"`
+	ReferenceType string          `json:"reference_type,omitempty"`
+	ReferenceID   *uuid.UUID      `json:"reference_id,omitempty"`
+	CreatedBy     *uuid.UUID      `json:"created_by,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Postings      []LedgerPosting `json:"postings,omitempty"`
+}
+
+// LedgerPosting moves Amount into (positive, debit) or out of (negative,
+// credit) an account. Referrer postings set UserID instead of AccountCode.
+type LedgerPosting struct {
+	AccountCode string     `json:"account_code"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Amount      int64      `json:"amount"`
+}
+
+// LedgerStatementLine is one entry as seen from a referrer's account.
+// Amount is positive when the referrer's balance increased.
+type LedgerStatementLine struct {
+	EntryID     uuid.UUID       `json:"entry_id"`
+	Kind        LedgerEntryKind `json:"kind"`
+	Description string          `json:"description"`
+	Amount      int64           `json:"amount"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// LedgerSummary aggregates referrer balances. Earned is net commission after
+// clawbacks and adjustments; Balance is what is still owed.
+type LedgerSummary struct {
+	Earned  int64 `json:"earned"`
+	PaidOut int64 `json:"paid_out"`
+	Balance int64 `json:"balance"`
+}
+
+// ReferralStatusChange is one entry in a referral's status history.
+// ChangedBy is nil for changes made by the system.
+type ReferralStatusChange struct {
+	ID            uuid.UUID  `json:"id"`
+	ReferralID    uuid.UUID  `json:"referral_id"`
+	FromStatus    string     `json:"from_status"`
+	ToStatus      string     `json:"to_status"`
+	ChangedBy     *uuid.UUID `json:"changed_by,omitempty"`
+	ChangedByName *string    `json:"changed_by_name,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type BankChangeStatus string
+
+const (
+	BankChangePending   BankChangeStatus = "pending"
+	BankChangeConfirmed BankChangeStatus = "confirmed"
+	BankChangeCancelled BankChangeStatus = "cancelled" // replaced by a later request
+	BankChangeExpired   BankChangeStatus = "expired"   // never stored; a pending change past ExpiresAt
+)
+
+// BankDetailChange is a request to change a user's bank details. It takes
+// effect only once confirmed from the link emailed to the user; payouts to
+// the new account are then held until HoldUntil.
+type BankDetailChange struct {
+	ID                    uuid.UUID        `json:"id"`
+	UserID                uuid.UUID        `json:"user_id"`
+	BankCode              string           `json:"bank_code"`
+	BankName              string           `json:"bank_name"`
+	AccountNumber         string           `json:"account_number"`
+	AccountName           string           `json:"account_name"`
+	BankNameMismatch      bool             `json:"bank_name_mismatch"`
+	PreviousBankName      *string          `json:"previous_bank_name,omitempty"`
+	PreviousAccountNumber *string          `json:"previous_account_number,omitempty"`
+	PreviousAccountName   *string          `json:"previous_account_name,omitempty"`
+	Status                BankChangeStatus `json:"status"`
+	Token                 string           `json:"-"`
+	IPAddress             *string          `json:"ip_address,omitempty"`
+	ExpiresAt             time.Time        `json:"expires_at"`
+	ConfirmedAt           *time.Time       `json:"confirmed_at,omitempty"`
+	HoldUntil             *time.Time       `json:"hold_until,omitempty"`
+	CreatedAt             time.Time        `json:"created_at"`
+}
+
+// AuditEvent records a mutating admin action. Before and After hold the
+// relevant state of the target around the change, when the handler provides it.
+type AuditEvent struct {
+	ID         uuid.UUID       `json:"id"`
+	ActorID    *uuid.UUID      `json:"actor_id,omitempty"`
+	ActorEmail string          `json:"actor_email"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id,omitempty"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Login failure reasons recorded in the login history
+const (
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureInvalidMFACode  = "invalid_mfa_code"
+)
+
+// LoginEvent is one sign-in attempt on an account
+type LoginEvent struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+	Success       bool      `json:"success"`
+	FailureReason *string   `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ClientInfo identifies the device a request came from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// AuditFilter narrows an audit log listing. Zero values are ignored.
+type AuditFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+}
+
+// ListFilter is the filtering and sorting shared by the admin list
+// endpoints. Empty fields are not filtered on; which fields apply depends on
+// the list.
+type ListFilter struct {
+	Status     string
+	Course     string
+	ReferrerID *uuid.UUID
+	From       *time.Time
+	To         *time.Time
+	Search     string
+	Sort       string
+	Order      string
+}
+
+// UserFilter narrows the admin user directory. Search matches name, email,
+// phone or referral code; nil fields are not filtered on.
+type UserFilter struct {
+	Search         string
+	Role           *Role
+	IsBlocked      *bool
+	HasBankDetails *bool
+	// BankNameMismatch selects accounts whose verified bank account holder
+	// does or does not match their name
+	BankNameMismatch *bool
+}
+
+// UserReferralStats summarises the referrals a user has made
+type UserReferralStats struct {
+	Total           int   `json:"total"`
+	PaidEarnings    int64 `json:"paid_earnings"`
+	PendingEarnings int64 `json:"pending_earnings"`
+}
+
+// AdminUserDetail is an account as seen from the admin user directory
+type AdminUserDetail struct {
+	User            User              `json:"user"`
+	Referrals       UserReferralStats `json:"referrals"`
+	Clicks          int               `json:"clicks"`
+	Balance         LedgerSummary     `json:"balance"`
+	RecentReferrals []Referral        `json:"recent_referrals"`
+	RecentPayouts   []Payout          `json:"recent_payouts"`
+	RecentLogins    []LoginEvent      `json:"recent_logins"`
+}
+
+// WebhookEvent is an inbound provider event, stored once per EventID
+type WebhookEvent struct {
+	ID         uuid.UUID       `json:"id"`
+	Provider   string          `json:"provider"`
+	EventID    string          `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	Payload    json.RawMessage `json:"payload"`
+	ReceivedAt time.Time       `json:"received_at"`
 }
 
 // Request/Response DTOs
@@ -98,16 +427,68 @@ type AuthResponse struct {
 	User         User   `json:"user"`
 }
 
+// MFAChallengeResponse is returned by login instead of tokens when the user
+// has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is a 6-digit authenticator code or a recovery code
+	Code string `json:"code" validate:"required"`
+}
+
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFAEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// UpdateProfileRequest changes a user's profile. Bank details are given as a
+// bank code from the bank list and an account number; the bank and account
+// names are looked up, not taken from the client.
 type UpdateProfileRequest struct {
 	Name          string `json:"name" validate:"omitempty,min=2"`
 	Phone         string `json:"phone"`
-	BankName      string `json:"bank_name"`
-	AccountNumber string `json:"account_number"`
-	AccountName   string `json:"account_name"`
+	BankCode      string `json:"bank_code" validate:"required_with=AccountNumber"`
+	AccountNumber string `json:"account_number" validate:"required_with=BankCode,omitempty,numeric,len=10"`
+}
+
+type ConfirmBankChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ProfileResponse is a user's profile with any bank detail change awaiting
+// confirmation
+type ProfileResponse struct {
+	*User
+	PendingBankChange *BankDetailChange `json:"pending_bank_change,omitempty"`
+}
+
+type RequestPayoutRequest struct {
+	Amount int64 `json:"amount" validate:"required,gt=0"`
 }
 
 type UpdatePayoutStatusRequest struct {
@@ -126,6 +507,31 @@ type StudentRegistrationRequest struct {
 	ReferralCode string `json:"referral_code"`
 }
 
+type CreateCourseRequest struct {
+	Name  string `json:"name" validate:"required,min=2"`
+	Price int64  `json:"price" validate:"required,gt=0"`
+}
+
+type UpdateCoursePriceRequest struct {
+	Price int64 `json:"price" validate:"required,gt=0"`
+}
+
+type CreateCommissionRuleRequest struct {
+	Name          string         `json:"name" validate:"required,min=2"`
+	CourseID      *uuid.UUID     `json:"course_id"`
+	Type          CommissionType `json:"type" validate:"required,oneof=flat percentage"`
+	Value         int64          `json:"value" validate:"required,gt=0"`
+	Cap           *int64         `json:"cap" validate:"omitempty,gt=0"`
+	EffectiveFrom *time.Time     `json:"effective_from"`
+	EffectiveTo   *time.Time     `json:"effective_to"`
+}
+
+type LedgerAdjustmentRequest struct {
+	UserID uuid.UUID `json:"user_id" validate:"required"`
+	Amount int64     `json:"amount" validate:"required"`
+	Reason string    `json:"reason" validate:"required,min=3"`
+}
+
 type DashboardStats struct {
 	TotalEarnings      int64 `json:"total_earnings"`
 	PendingBalance     int64 `json:"pending_balance"`
@@ -153,11 +559,33 @@ type ForgotPasswordRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
 type ResetPasswordRequest struct {
 	Token       string `json:"token" validate:"required"`
 	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
+type InviteAdminRequest struct {
+	Email     string    `json:"email" validate:"required,email"`
+	AdminRole AdminRole `json:"admin_role" validate:"required"`
+}
+
+type AcceptInviteRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Name     string `json:"name" validate:"required,min=2"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateAdminRequest changes an admin's role, access or both
+type UpdateAdminRequest struct {
+	AdminRole *AdminRole `json:"admin_role"`
+	IsBlocked *bool      `json:"is_blocked"`
+}
+
 type BlockUserRequest struct {
 	IsBlocked bool `json:"is_blocked"`
 }
@@ -170,5 +598,6 @@ type StudentResponse struct {
 
 type ErrorResponse struct {
 	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
 	Message string `json:"message,omitempty"`
 }
@@ -0,0 +1,194 @@
+package payouts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeBanks are the banks a Fake starts with
+var FakeBanks = []Bank{
+	{Name: "Access Bank", Code: "044"},
+	{Name: "Guaranty Trust Bank", Code: "058"},
+	{Name: "Zenith Bank", Code: "057"},
+}
+
+// Fake is an in-memory PayoutProvider for tests. It knows the accounts added
+// with AddAccount, accepts every transfer to them as pending, and reports
+// whatever status SetTransferStatus last gave a transfer.
+type Fake struct {
+	mu         sync.Mutex
+	banks      []Bank
+	accounts   map[string]string
+	recipients int
+	transfers  map[string]*fakeTransfer
+	order      []string
+	lost       error
+	refuse     string
+}
+
+// fakeRefusal is the fake's definite refusal of a call, like a provider's 4xx
+// response
+type fakeRefusal string
+
+func (e fakeRefusal) Error() string {
+	return "fake: " + string(e)
+}
+
+func (fakeRefusal) Temporary() bool {
+	return false
+}
+
+type fakeTransfer struct {
+	request  TransferRequest
+	transfer Transfer
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		banks:     append([]Bank(nil), FakeBanks...),
+		accounts:  make(map[string]string),
+		transfers: make(map[string]*fakeTransfer),
+	}
+}
+
+func (f *Fake) Name() string {
+	return ProviderFake
+}
+
+func (f *Fake) Enabled() bool {
+	return true
+}
+
+// AddAccount makes the account resolvable under name
+func (f *Fake) AddAccount(bankCode, accountNumber, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[bankCode+":"+accountNumber] = name
+}
+
+// SetTransferStatus changes the status of the transfer with reference
+func (f *Fake) SetTransferStatus(reference, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.transfers[reference]
+	if !ok {
+		return fmt.Errorf("fake: no transfer %q", reference)
+	}
+	t.transfer.Status = status
+	return nil
+}
+
+// LoseNextResponse makes the next transfer be accepted but reported as
+// failing with err, as when the provider's response times out
+func (f *Fake) LoseNextResponse(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lost = err
+}
+
+// RefuseNextTransfer makes the next transfer be refused for reason without
+// being sent, as when the provider's balance is too low
+func (f *Fake) RefuseNextTransfer(reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refuse = reason
+}
+
+// Transfers returns the transfers requested so far, oldest first
+func (f *Fake) Transfers() []TransferRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := make([]TransferRequest, 0, len(f.order))
+	for _, reference := range f.order {
+		requests = append(requests, f.transfers[reference].request)
+	}
+	return requests
+}
+
+func (f *Fake) ListBanks(ctx context.Context) ([]Bank, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Bank(nil), f.banks...), nil
+}
+
+func (f *Fake) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name, ok := f.accounts[bankCode+":"+accountNumber]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	return &Account{AccountNumber: accountNumber, AccountName: name}, nil
+}
+
+func (f *Fake) CreateRecipient(ctx context.Context, name, accountNumber, bankCode string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.hasBank(bankCode) {
+		return "", ErrBankNotFound
+	}
+	f.recipients++
+	return fmt.Sprintf("RCP_fake%d", f.recipients), nil
+}
+
+func (f *Fake) Transfer(ctx context.Context, req *TransferRequest) (*Transfer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Reference == "" || req.Amount <= 0 {
+		return nil, fakeRefusal("reference and a positive amount are required")
+	}
+	if _, ok := f.transfers[req.Reference]; ok {
+		return nil, fakeRefusal(fmt.Sprintf("duplicate transfer reference %q", req.Reference))
+	}
+	if reason := f.refuse; reason != "" {
+		f.refuse = ""
+		return nil, fakeRefusal(reason)
+	}
+
+	t := &fakeTransfer{
+		request: *req,
+		transfer: Transfer{
+			Reference: req.Reference,
+			Code:      fmt.Sprintf("TRF_fake%d", len(f.order)+1),
+			Status:    TransferPending,
+		},
+	}
+	f.transfers[req.Reference] = t
+	f.order = append(f.order, req.Reference)
+
+	if err := f.lost; err != nil {
+		f.lost = nil
+		return nil, err
+	}
+
+	transfer := t.transfer
+	return &transfer, nil
+}
+
+func (f *Fake) TransferStatus(ctx context.Context, reference, _ string) (*Transfer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.transfers[reference]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTransferNotFound, reference)
+	}
+	transfer := t.transfer
+	return &transfer, nil
+}
+
+func (f *Fake) hasBank(code string) bool {
+	for _, b := range f.banks {
+		if b.Code == strings.TrimSpace(code) {
+			return true
+		}
+	}
+	return false
+}
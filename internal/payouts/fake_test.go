@@ -0,0 +1,67 @@
+package payouts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The fake stands in for the real providers, so they must all satisfy the
+// same interface
+var (
+	_ PayoutProvider = (*Fake)(nil)
+	_ PayoutProvider = (*Paystack)(nil)
+	_ PayoutProvider = (*Flutterwave)(nil)
+)
+
+func TestFake(t *testing.T) {
+	fake := NewFake()
+	ctx := context.Background()
+	fake.AddAccount("058", "0123456789", "ADA LOVELACE")
+
+	account, err := fake.ResolveAccount(ctx, "0123456789", "058")
+	require.NoError(t, err)
+	assert.Equal(t, "ADA LOVELACE", account.AccountName)
+	_, err = fake.ResolveAccount(ctx, "0123456789", "044")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	recipient, err := fake.CreateRecipient(ctx, "Ada Lovelace", "0123456789", "058")
+	require.NoError(t, err)
+	assert.NotEmpty(t, recipient)
+	_, err = fake.CreateRecipient(ctx, "Ada Lovelace", "0123456789", "000")
+	assert.ErrorIs(t, err, ErrBankNotFound)
+
+	req := &TransferRequest{Amount: 1000000, RecipientCode: recipient, Reference: "payout-1"}
+	transfer, err := fake.Transfer(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, TransferPending, transfer.Status)
+
+	_, err = fake.Transfer(ctx, req)
+	assert.True(t, Refused(err), "a repeated reference is rejected")
+	assert.Equal(t, []TransferRequest{*req}, fake.Transfers())
+
+	require.NoError(t, fake.SetTransferStatus("payout-1", TransferSuccess))
+	transfer, err = fake.TransferStatus(ctx, "payout-1", transfer.Code)
+	require.NoError(t, err)
+	assert.Equal(t, TransferSuccess, transfer.Status)
+
+	assert.Error(t, fake.SetTransferStatus("payout-2", TransferFailed))
+	_, err = fake.TransferStatus(ctx, "payout-2", "")
+	assert.ErrorIs(t, err, ErrTransferNotFound)
+
+	fake.LoseNextResponse(context.DeadlineExceeded)
+	_, err = fake.Transfer(ctx, &TransferRequest{Amount: 1000000, RecipientCode: recipient, Reference: "payout-3"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, Refused(err))
+	transfer, err = fake.TransferStatus(ctx, "payout-3", "")
+	require.NoError(t, err, "the transfer was accepted despite the error")
+	assert.Equal(t, TransferPending, transfer.Status)
+
+	fake.RefuseNextTransfer("insufficient balance")
+	_, err = fake.Transfer(ctx, &TransferRequest{Amount: 1000000, RecipientCode: recipient, Reference: "payout-4"})
+	assert.True(t, Refused(err))
+	_, err = fake.TransferStatus(ctx, "payout-4", "")
+	assert.ErrorIs(t, err, ErrTransferNotFound, "a refused transfer is not sent")
+}
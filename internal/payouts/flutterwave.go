@@ -0,0 +1,128 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cirvee/referral-backend/internal/flutterwave"
+)
+
+// Flutterwave pays out through Flutterwave Transfers. Transfers are made to
+// the account details themselves; the saved beneficiary only records the
+// destination on the Flutterwave dashboard.
+type Flutterwave struct {
+	client *flutterwave.Client
+}
+
+func NewFlutterwave(client *flutterwave.Client) *Flutterwave {
+	return &Flutterwave{client: client}
+}
+
+func (f *Flutterwave) Name() string {
+	return ProviderFlutterwave
+}
+
+func (f *Flutterwave) Enabled() bool {
+	return f.client.Enabled()
+}
+
+// ListBanks returns every bank Flutterwave lists, since it only lists the
+// ones it can pay into
+func (f *Flutterwave) ListBanks(ctx context.Context) ([]Bank, error) {
+	banks, err := f.client.ListBanks(ctx)
+	if err != nil {
+		return nil, flutterwaveError(err)
+	}
+
+	list := make([]Bank, 0, len(banks))
+	for _, b := range banks {
+		list = append(list, Bank{Name: b.Name, Code: b.Code})
+	}
+	return list, nil
+}
+
+func (f *Flutterwave) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*Account, error) {
+	account, err := f.client.ResolveAccount(ctx, accountNumber, bankCode)
+	if err != nil {
+		// Flutterwave answers 400 for an account that does not exist
+		return nil, resolveError(flutterwaveError(err))
+	}
+	return &Account{AccountNumber: account.AccountNumber, AccountName: account.AccountName}, nil
+}
+
+func (f *Flutterwave) CreateRecipient(ctx context.Context, name, accountNumber, bankCode string) (string, error) {
+	beneficiary, err := f.client.CreateBeneficiary(ctx, name, accountNumber, bankCode)
+	if err != nil {
+		return "", flutterwaveError(err)
+	}
+	return strconv.FormatInt(beneficiary.ID, 10), nil
+}
+
+func (f *Flutterwave) Transfer(ctx context.Context, req *TransferRequest) (*Transfer, error) {
+	transfer, err := f.client.InitiateTransfer(ctx, &flutterwave.TransferRequest{
+		AccountBank:     req.BankCode,
+		AccountNumber:   req.AccountNumber,
+		Amount:          float64(req.Amount) / 100, // kobo to naira
+		Narration:       req.Reason,
+		Reference:       req.Reference,
+		BeneficiaryName: req.AccountName,
+	})
+	if err != nil {
+		return nil, flutterwaveError(err)
+	}
+	return flutterwaveTransfer(transfer), nil
+}
+
+// TransferStatus looks the transfer up by the ID Flutterwave gave it, which
+// Transfer returns as its Code, or by reference if the ID is not known
+func (f *Flutterwave) TransferStatus(ctx context.Context, reference, code string) (*Transfer, error) {
+	transfer, err := f.lookup(ctx, reference, code)
+	if err != nil {
+		return nil, lookupError(flutterwaveError(err))
+	}
+	return flutterwaveTransfer(transfer), nil
+}
+
+func (f *Flutterwave) lookup(ctx context.Context, reference, code string) (*flutterwave.Transfer, error) {
+	if code == "" {
+		return f.client.TransferByReference(ctx, reference)
+	}
+
+	id, err := strconv.ParseInt(code, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("flutterwave: invalid transfer id %q", code)
+	}
+	return f.client.GetTransfer(ctx, id)
+}
+
+func flutterwaveTransfer(t *flutterwave.Transfer) *Transfer {
+	return &Transfer{Reference: t.Reference, Code: strconv.FormatInt(t.ID, 10), Status: FlutterwaveStatus(t.Status)}
+}
+
+// FlutterwaveStatus normalizes a Flutterwave transfer status. NEW and
+// PENDING transfers are still being processed.
+func FlutterwaveStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case "SUCCESSFUL":
+		return TransferSuccess
+	case "FAILED":
+		return TransferFailed
+	case "REVERSED":
+		return TransferReversed
+	default:
+		return TransferPending
+	}
+}
+
+func flutterwaveError(err error) error {
+	switch {
+	case errors.Is(err, flutterwave.ErrNotConfigured):
+		return fmt.Errorf("%w: %w", ErrNotConfigured, err)
+	case errors.Is(err, flutterwave.ErrTransferNotFound):
+		return fmt.Errorf("%w: %w", ErrTransferNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cirvee/referral-backend/internal/paystack"
+)
+
+// Paystack pays out through Paystack Transfers to saved recipients
+type Paystack struct {
+	client *paystack.Client
+}
+
+func NewPaystack(client *paystack.Client) *Paystack {
+	return &Paystack{client: client}
+}
+
+func (p *Paystack) Name() string {
+	return ProviderPaystack
+}
+
+func (p *Paystack) Enabled() bool {
+	return p.client.Enabled()
+}
+
+func (p *Paystack) ListBanks(ctx context.Context) ([]Bank, error) {
+	banks, err := p.client.ListBanks(ctx)
+	if err != nil {
+		return nil, paystackError(err)
+	}
+
+	active := make([]Bank, 0, len(banks))
+	for _, b := range banks {
+		if b.Active && !b.IsDeleted {
+			active = append(active, Bank{Name: b.Name, Code: b.Code})
+		}
+	}
+	return active, nil
+}
+
+func (p *Paystack) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*Account, error) {
+	account, err := p.client.ResolveAccount(ctx, accountNumber, bankCode)
+	if err != nil {
+		// Paystack answers 422 or 400 for an account that does not exist
+		return nil, resolveError(paystackError(err))
+	}
+	return &Account{AccountNumber: account.AccountNumber, AccountName: account.AccountName}, nil
+}
+
+func (p *Paystack) CreateRecipient(ctx context.Context, name, accountNumber, bankCode string) (string, error) {
+	recipient, err := p.client.CreateTransferRecipient(ctx, name, accountNumber, bankCode)
+	if err != nil {
+		return "", paystackError(err)
+	}
+	return recipient.RecipientCode, nil
+}
+
+func (p *Paystack) Transfer(ctx context.Context, req *TransferRequest) (*Transfer, error) {
+	transfer, err := p.client.InitiateTransfer(ctx, &paystack.TransferRequest{
+		Amount:    req.Amount,
+		Recipient: req.RecipientCode,
+		Reference: req.Reference,
+		Reason:    req.Reason,
+	})
+	if err != nil {
+		return nil, paystackError(err)
+	}
+	return paystackTransfer(transfer), nil
+}
+
+func (p *Paystack) TransferStatus(ctx context.Context, reference, _ string) (*Transfer, error) {
+	transfer, err := p.client.VerifyTransfer(ctx, reference)
+	if err != nil {
+		// Paystack answers 4xx for a reference it does not know
+		return nil, lookupError(paystackError(err))
+	}
+	return paystackTransfer(transfer), nil
+}
+
+func paystackTransfer(t *paystack.Transfer) *Transfer {
+	return &Transfer{Reference: t.Reference, Code: t.TransferCode, Status: PaystackStatus(t.Status)}
+}
+
+// PaystackStatus normalizes a Paystack transfer status. Transfers awaiting
+// an OTP or still queued are pending; ones Paystack gave up on are failed.
+func PaystackStatus(status string) string {
+	switch status {
+	case "success":
+		return TransferSuccess
+	case "failed", "abandoned", "blocked", "rejected":
+		return TransferFailed
+	case "reversed":
+		return TransferReversed
+	default:
+		return TransferPending
+	}
+}
+
+func paystackError(err error) error {
+	if errors.Is(err, paystack.ErrNotConfigured) {
+		return fmt.Errorf("%w: %w", ErrNotConfigured, err)
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+// Package payouts hides the bank transfer provider behind PayoutProvider, so
+// bank lookups and payouts work the same whether they go through Paystack,
+// Flutterwave or, in tests, an in-memory fake.
+package payouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/flutterwave"
+	"github.com/cirvee/referral-backend/internal/paystack"
+)
+
+var (
+	ErrNotConfigured    = errors.New("payout provider is not configured")
+	ErrBankNotFound     = errors.New("bank not supported by the payout provider")
+	ErrAccountNotFound  = errors.New("bank account could not be resolved")
+	ErrUnknownProvider  = errors.New("unknown payout provider")
+	ErrTransferNotFound = errors.New("transfer not found by the payout provider")
+)
+
+// Provider names
+const (
+	ProviderPaystack    = "paystack"
+	ProviderFlutterwave = "flutterwave"
+	ProviderFake        = "fake"
+)
+
+// Transfer statuses, as normalized from each provider's own statuses
+const (
+	TransferPending  = "pending"
+	TransferSuccess  = "success"
+	TransferFailed   = "failed"
+	TransferReversed = "reversed"
+)
+
+// Bank is a bank the provider can pay into
+type Bank struct {
+	Name string
+	Code string
+}
+
+// Account is the holder of a resolved bank account
+type Account struct {
+	AccountNumber string
+	AccountName   string
+}
+
+// TransferRequest describes a payout to a bank account. Amount is in kobo.
+// Providers that pay saved recipients use RecipientCode; the others pay the
+// account details directly.
+type TransferRequest struct {
+	Amount        int64
+	RecipientCode string
+	AccountNumber string
+	AccountName   string
+	BankCode      string
+	Reference     string
+	Reason        string
+}
+
+// Transfer is a provider's view of a transfer. Code is the provider's own
+// identifier for it and Status is one of the Transfer* statuses.
+type Transfer struct {
+	Reference string
+	Code      string
+	Status    string
+}
+
+// PayoutProvider looks up banks and accounts and sends money to them
+type PayoutProvider interface {
+	// Name identifies the provider in stored recipients and transfers
+	Name() string
+	// Enabled reports whether the provider has credentials. Without them,
+	// payouts are approved without moving money.
+	Enabled() bool
+	// ListBanks returns the active banks the provider can pay into
+	ListBanks(ctx context.Context) ([]Bank, error)
+	// ResolveAccount looks up the holder of an account number at a bank,
+	// returning ErrAccountNotFound if the bank does not know it
+	ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*Account, error)
+	// CreateRecipient saves a bank account as a transfer destination and
+	// returns its code
+	CreateRecipient(ctx context.Context, name, accountNumber, bankCode string) (string, error)
+	// Transfer sends money. A repeated reference is rejected, so a retried
+	// transfer cannot pay twice.
+	Transfer(ctx context.Context, req *TransferRequest) (*Transfer, error)
+	// TransferStatus returns the current state of the transfer with
+	// reference and the code the provider gave it, or ErrTransferNotFound if
+	// the provider has no such transfer
+	TransferStatus(ctx context.Context, reference, code string) (*Transfer, error)
+}
+
+// New returns the provider named by cfg.Payout.Provider
+func New(cfg *config.Config) (PayoutProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Payout.Provider)) {
+	case "", ProviderPaystack:
+		return NewPaystack(paystack.NewClient(&cfg.Paystack)), nil
+	case ProviderFlutterwave:
+		return NewFlutterwave(flutterwave.NewClient(&cfg.Flutterwave)), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, cfg.Payout.Provider)
+	}
+}
+
+// BankCodeByName returns the code p uses for the bank whose name matches
+// name, ignoring case and surrounding whitespace
+func BankCodeByName(ctx context.Context, p PayoutProvider, name string) (string, error) {
+	banks, err := p.ListBanks(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	name = strings.TrimSpace(name)
+	for _, b := range banks {
+		if strings.EqualFold(b.Name, name) {
+			return b.Code, nil
+		}
+	}
+
+	return "", ErrBankNotFound
+}
+
+// temporary is implemented by provider API errors
+type temporary interface {
+	Temporary() bool
+}
+
+// resolveError maps a provider's refusal to resolve an account to
+// ErrAccountNotFound. Errors that may pass on retry are returned as they are.
+func resolveError(err error) error {
+	var t temporary
+	if errors.As(err, &t) && !t.Temporary() {
+		return fmt.Errorf("%w: %w", ErrAccountNotFound, err)
+	}
+	return err
+}
+
+// Refused reports whether err is the provider's definite refusal of a call,
+// which therefore had no effect. Errors that may pass on retry, such as
+// timeouts and 5xx responses, leave the outcome of the call unknown.
+func Refused(err error) bool {
+	if errors.Is(err, ErrNotConfigured) {
+		return true
+	}
+	var t temporary
+	return errors.As(err, &t) && !t.Temporary()
+}
+
+// lookupError maps a provider's refusal to look up a transfer to
+// ErrTransferNotFound. Errors that may pass on retry are returned as they
+// are.
+func lookupError(err error) error {
+	var t temporary
+	if errors.As(err, &t) && !t.Temporary() {
+		return fmt.Errorf("%w: %w", ErrTransferNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package payouts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/flutterwave"
+	"github.com/cirvee/referral-backend/internal/paystack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{"", ProviderPaystack},
+		{"paystack", ProviderPaystack},
+		{" Flutterwave ", ProviderFlutterwave},
+	}
+	for _, tt := range tests {
+		provider, err := New(&config.Config{Payout: config.PayoutConfig{Provider: tt.provider}})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, provider.Name())
+		assert.False(t, provider.Enabled())
+	}
+
+	_, err := New(&config.Config{Payout: config.PayoutConfig{Provider: "stripe"}})
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func TestNotConfigured(t *testing.T) {
+	for _, provider := range []PayoutProvider{
+		NewPaystack(paystack.NewClient(&config.PaystackConfig{})),
+		NewFlutterwave(flutterwave.NewClient(&config.FlutterwaveConfig{})),
+	} {
+		_, err := provider.ListBanks(context.Background())
+		assert.ErrorIs(t, err, ErrNotConfigured, provider.Name())
+
+		_, err = provider.ResolveAccount(context.Background(), "0123456789", "058")
+		assert.ErrorIs(t, err, ErrNotConfigured, provider.Name())
+		assert.NotErrorIs(t, err, ErrAccountNotFound, provider.Name())
+	}
+}
+
+func TestStatus(t *testing.T) {
+	paystackStatuses := map[string]string{
+		"success": TransferSuccess, "pending": TransferPending, "otp": TransferPending, "queued": TransferPending,
+		"failed": TransferFailed, "abandoned": TransferFailed, "rejected": TransferFailed, "reversed": TransferReversed,
+	}
+	for status, want := range paystackStatuses {
+		assert.Equal(t, want, PaystackStatus(status), status)
+	}
+
+	flutterwaveStatuses := map[string]string{
+		"NEW": TransferPending, "PENDING": TransferPending, "SUCCESSFUL": TransferSuccess, "FAILED": TransferFailed,
+	}
+	for status, want := range flutterwaveStatuses {
+		assert.Equal(t, want, FlutterwaveStatus(status), status)
+	}
+}
+
+// servePaystack answers each request with the JSON body routes holds for
+// its "METHOD /path", or with Paystack's 400 response if the body is nil
+func servePaystack(t *testing.T, routes map[string]interface{}) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if body == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			body = map[string]interface{}{"status": false, "message": "Could not resolve account name"}
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestPaystack(t *testing.T) {
+	url := servePaystack(t, map[string]interface{}{
+		"GET /bank": map[string]interface{}{"status": true, "data": []paystack.Bank{
+			{Name: "Guaranty Trust Bank", Code: "058", Active: true},
+			{Name: "Old Bank", Code: "999"},
+			{Name: "Deleted Bank", Code: "998", Active: true, IsDeleted: true},
+		}},
+		"GET /bank/resolve":               nil,
+		"GET /transfer/verify/payout-ref": map[string]interface{}{"status": true, "data": paystack.Transfer{Reference: "payout-ref", TransferCode: "TRF_1", Status: "reversed"}},
+		"GET /transfer/verify/payout-new": nil,
+	})
+	provider := NewPaystack(paystack.NewClient(&config.PaystackConfig{SecretKey: "sk_test", BaseURL: url}))
+
+	banks, err := provider.ListBanks(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Bank{{Name: "Guaranty Trust Bank", Code: "058"}}, banks)
+
+	code, err := BankCodeByName(context.Background(), provider, " guaranty trust BANK ")
+	require.NoError(t, err)
+	assert.Equal(t, "058", code)
+	_, err = BankCodeByName(context.Background(), provider, "Old Bank")
+	assert.ErrorIs(t, err, ErrBankNotFound)
+
+	_, err = provider.ResolveAccount(context.Background(), "0000000000", "058")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	var apiErr *paystack.APIError
+	assert.True(t, errors.As(err, &apiErr), "the provider's error is kept")
+
+	transfer, err := provider.TransferStatus(context.Background(), "payout-ref", "TRF_1")
+	require.NoError(t, err)
+	assert.Equal(t, &Transfer{Reference: "payout-ref", Code: "TRF_1", Status: TransferReversed}, transfer)
+
+	_, err = provider.TransferStatus(context.Background(), "payout-new", "")
+	assert.ErrorIs(t, err, ErrTransferNotFound)
+}
+
+func TestFlutterwave(t *testing.T) {
+	var sent flutterwave.TransferRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v3/transfers":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": flutterwave.Transfer{ID: 396456, Reference: sent.Reference, Status: "NEW"}})
+		case "GET /v3/transfers/396456":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": flutterwave.Transfer{ID: 396456, Reference: "payout-ref", Status: "SUCCESSFUL"}})
+		case "GET /v3/transfers":
+			transfers := []flutterwave.Transfer{}
+			if r.URL.Query().Get("reference") == "payout-ref" {
+				transfers = append(transfers, flutterwave.Transfer{ID: 396456, Reference: "payout-ref", Status: "SUCCESSFUL"})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": transfers})
+		case "POST /v3/accounts/resolve":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "error", "message": "Sorry, that account number is invalid"})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	provider := NewFlutterwave(flutterwave.NewClient(&config.FlutterwaveConfig{SecretKey: "FLWSECK_TEST-secret", BaseURL: server.URL}))
+
+	transfer, err := provider.Transfer(context.Background(), &TransferRequest{
+		Amount: 1050050, RecipientCode: "7369", AccountNumber: "0690000032", AccountName: "Pastor Bright",
+		BankCode: "044", Reference: "payout-ref", Reason: "Referral payout",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Transfer{Reference: "payout-ref", Code: "396456", Status: TransferPending}, transfer)
+	assert.Equal(t, flutterwave.TransferRequest{
+		AccountBank: "044", AccountNumber: "0690000032", Amount: 10500.50, Narration: "Referral payout",
+		Currency: "NGN", Reference: "payout-ref", BeneficiaryName: "Pastor Bright", DebitCurrency: "NGN",
+	}, sent, "amounts are sent in naira")
+
+	transfer, err = provider.TransferStatus(context.Background(), "payout-ref", transfer.Code)
+	require.NoError(t, err)
+	assert.Equal(t, TransferSuccess, transfer.Status)
+
+	// Without the ID, as after a lost response, the reference is looked up
+	transfer, err = provider.TransferStatus(context.Background(), "payout-ref", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Transfer{Reference: "payout-ref", Code: "396456", Status: TransferSuccess}, transfer)
+	_, err = provider.TransferStatus(context.Background(), "payout-other", "")
+	assert.ErrorIs(t, err, ErrTransferNotFound)
+
+	_, err = provider.ResolveAccount(context.Background(), "0000000000", "044")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
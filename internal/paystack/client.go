@@ -0,0 +1,241 @@
+// Package paystack is a minimal client for the Paystack API endpoints used to
+// look up banks and disburse payouts through Paystack Transfers. Calls go
+// through apiclient, so they are time limited, reads are retried, and a
+// circuit breaker stops calls for a while when Paystack keeps failing.
+package paystack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cirvee/referral-backend/internal/apiclient"
+	"github.com/cirvee/referral-backend/internal/config"
+)
+
+var (
+	ErrNotConfigured = errors.New("paystack secret key is not configured")
+	ErrBankNotFound  = errors.New("bank not supported by paystack")
+	ErrCircuitOpen   = apiclient.ErrCircuitOpen
+)
+
+// APIError is returned when Paystack responds with a non-2xx status or
+// status=false in the response envelope.
+type APIError = apiclient.APIError
+
+// RequestError is returned when Paystack could not be reached or did not
+// answer in time.
+type RequestError = apiclient.RequestError
+
+type Client struct {
+	api *apiclient.Client
+}
+
+func NewClient(cfg *config.PaystackConfig) *Client {
+	return &Client{api: apiclient.NewClient(apiclient.Config{
+		Provider:  "paystack",
+		SecretKey: cfg.SecretKey,
+		BaseURL:   cfg.BaseURL,
+		Timeout:   cfg.Timeout,
+		Decode:    decode,
+	})}
+}
+
+// Enabled reports whether a secret key is configured. Without one, payouts
+// are approved without moving money.
+func (c *Client) Enabled() bool {
+	return c != nil && c.api.Enabled()
+}
+
+// Bank is a bank as listed by Paystack
+type Bank struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Code      string `json:"code"`
+	Longcode  string `json:"longcode"`
+	Gateway   string `json:"gateway"`
+	Active    bool   `json:"active"`
+	IsDeleted bool   `json:"is_deleted"`
+	Country   string `json:"country"`
+	Currency  string `json:"currency"`
+	Type      string `json:"type"`
+}
+
+// ResolvedAccount is the account holder returned by account resolution
+type ResolvedAccount struct {
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+	BankID        int    `json:"bank_id"`
+}
+
+// RecipientRequest describes a transfer destination to save
+type RecipientRequest struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	Currency      string `json:"currency"`
+}
+
+// Recipient is a saved transfer destination
+type Recipient struct {
+	RecipientCode string `json:"recipient_code"`
+	Name          string `json:"name"`
+	Details       struct {
+		AccountNumber string `json:"account_number"`
+		AccountName   string `json:"account_name"`
+		BankCode      string `json:"bank_code"`
+		BankName      string `json:"bank_name"`
+	} `json:"details"`
+}
+
+// TransferRequest describes a transfer from the Paystack balance. Amount is in
+// kobo.
+type TransferRequest struct {
+	Amount    int64  `json:"amount"`
+	Recipient string `json:"recipient"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason,omitempty"`
+	Source    string `json:"source"`
+	Currency  string `json:"currency"`
+}
+
+// Transfer is Paystack's view of an initiated transfer
+type Transfer struct {
+	ID           int64  `json:"id"`
+	Reference    string `json:"reference"`
+	TransferCode string `json:"transfer_code"`
+	Status       string `json:"status"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+}
+
+type envelope struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ListBanks returns the Nigerian banks Paystack supports
+func (c *Client) ListBanks(ctx context.Context) ([]Bank, error) {
+	var banks []Bank
+	if err := c.do(ctx, http.MethodGet, "/bank?country=nigeria&perPage=100", nil, &banks); err != nil {
+		return nil, err
+	}
+	return banks, nil
+}
+
+// BankCodeByName returns the code of the active bank whose name matches name,
+// ignoring case and surrounding whitespace.
+func (c *Client) BankCodeByName(ctx context.Context, name string) (string, error) {
+	banks, err := c.ListBanks(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	name = strings.TrimSpace(name)
+	for _, b := range banks {
+		if b.Active && !b.IsDeleted && strings.EqualFold(b.Name, name) {
+			return b.Code, nil
+		}
+	}
+
+	return "", ErrBankNotFound
+}
+
+// BankByCode returns the active bank with the given code
+func (c *Client) BankByCode(ctx context.Context, code string) (*Bank, error) {
+	banks, err := c.ListBanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code = strings.TrimSpace(code)
+	for i, b := range banks {
+		if b.Active && !b.IsDeleted && b.Code == code {
+			return &banks[i], nil
+		}
+	}
+
+	return nil, ErrBankNotFound
+}
+
+// ResolveAccount looks up the holder of an account number at a bank
+func (c *Client) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*ResolvedAccount, error) {
+	query := url.Values{}
+	query.Set("account_number", accountNumber)
+	query.Set("bank_code", bankCode)
+
+	account := &ResolvedAccount{}
+	if err := c.do(ctx, http.MethodGet, "/bank/resolve?"+query.Encode(), nil, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// CreateTransferRecipient registers a Nigerian bank account as a transfer
+// destination
+func (c *Client) CreateTransferRecipient(ctx context.Context, name, accountNumber, bankCode string) (*Recipient, error) {
+	body := &RecipientRequest{
+		Type:          "nuban",
+		Name:          name,
+		AccountNumber: accountNumber,
+		BankCode:      bankCode,
+		Currency:      "NGN",
+	}
+
+	recipient := &Recipient{}
+	if err := c.do(ctx, http.MethodPost, "/transferrecipient", body, recipient); err != nil {
+		return nil, err
+	}
+	return recipient, nil
+}
+
+// InitiateTransfer sends money to a recipient. Paystack rejects a repeated
+// reference, so retrying with the same reference cannot pay twice.
+func (c *Client) InitiateTransfer(ctx context.Context, req *TransferRequest) (*Transfer, error) {
+	if req.Source == "" {
+		req.Source = "balance"
+	}
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+
+	transfer := &Transfer{}
+	if err := c.do(ctx, http.MethodPost, "/transfer", req, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// VerifyTransfer returns the current state of the transfer with reference
+func (c *Client) VerifyTransfer(ctx context.Context, reference string) (*Transfer, error) {
+	transfer := &Transfer{}
+	if err := c.do(ctx, http.MethodGet, "/transfer/verify/"+url.PathEscape(reference), nil, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// do calls Paystack and decodes the response data into out. GET requests
+// are retried; other requests are tried once, since Paystack may have acted
+// on one that failed.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	if !c.Enabled() {
+		return ErrNotConfigured
+	}
+	return c.api.Do(ctx, method, path, body, out)
+}
+
+// decode unwraps Paystack's {status, message, data} response envelope
+func decode(raw []byte) (*apiclient.Envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	return &apiclient.Envelope{OK: env.Status, Message: env.Message, Data: env.Data}, nil
+}
@@ -0,0 +1,300 @@
+package paystack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/apiclient"
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePaystack serves the subset of the Paystack API used by Client
+func fakePaystack(t *testing.T) (*httptest.Server, *[]TransferRequest) {
+	transfers := &[]TransferRequest{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bank", func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, http.StatusOK, true, "Banks retrieved", []Bank{
+			{Name: "Guaranty Trust Bank", Code: "058", Active: true},
+			{Name: "Access Bank", Code: "044", Active: true},
+			{Name: "Old Bank", Code: "999", Active: false},
+		})
+	})
+	mux.HandleFunc("/transferrecipient", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "nuban", body["type"])
+		assert.Equal(t, "NGN", body["currency"])
+
+		if body["account_number"] == "0000000000" {
+			writeEnvelope(w, http.StatusBadRequest, false, "Could not resolve account name", nil)
+			return
+		}
+
+		recipient := Recipient{RecipientCode: "RCP_test123", Name: body["name"]}
+		recipient.Details.AccountNumber = body["account_number"]
+		recipient.Details.BankCode = body["bank_code"]
+		writeEnvelope(w, http.StatusCreated, true, "Transfer recipient created", recipient)
+	})
+	mux.HandleFunc("/transfer", func(w http.ResponseWriter, r *http.Request) {
+		var req TransferRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		for _, prev := range *transfers {
+			if prev.Reference == req.Reference {
+				writeEnvelope(w, http.StatusBadRequest, false, "Duplicate Transfer Reference", nil)
+				return
+			}
+		}
+		*transfers = append(*transfers, req)
+
+		writeEnvelope(w, http.StatusOK, true, "Transfer has been queued", Transfer{
+			ID:           1,
+			Reference:    req.Reference,
+			TransferCode: "TRF_test123",
+			Status:       "pending",
+			Amount:       req.Amount,
+			Currency:     req.Currency,
+		})
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test_secret" {
+			writeEnvelope(w, http.StatusUnauthorized, false, "Invalid key", nil)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, transfers
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, ok bool, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  ok,
+		"message": message,
+		"data":    data,
+	})
+}
+
+func newTestClient(serverURL string) *Client {
+	return NewClient(&config.PaystackConfig{SecretKey: "sk_test_secret", BaseURL: serverURL})
+}
+
+func TestClient_Enabled(t *testing.T) {
+	var nilClient *Client
+	assert.False(t, nilClient.Enabled())
+	assert.False(t, NewClient(&config.PaystackConfig{}).Enabled())
+	assert.True(t, newTestClient("http://localhost").Enabled())
+
+	_, err := NewClient(&config.PaystackConfig{}).ListBanks(context.Background())
+	assert.ErrorIs(t, err, ErrNotConfigured)
+}
+
+func TestClient_BankCodeByName(t *testing.T) {
+	server, _ := fakePaystack(t)
+	client := newTestClient(server.URL)
+
+	code, err := client.BankCodeByName(context.Background(), "  guaranty trust bank ")
+	require.NoError(t, err)
+	assert.Equal(t, "058", code)
+
+	_, err = client.BankCodeByName(context.Background(), "Old Bank")
+	assert.ErrorIs(t, err, ErrBankNotFound)
+}
+
+func TestClient_BankByCode(t *testing.T) {
+	server, _ := fakePaystack(t)
+	client := newTestClient(server.URL)
+
+	bank, err := client.BankByCode(context.Background(), "044")
+	require.NoError(t, err)
+	assert.Equal(t, "Access Bank", bank.Name)
+
+	_, err = client.BankByCode(context.Background(), "999")
+	assert.ErrorIs(t, err, ErrBankNotFound)
+}
+
+func TestClient_CreateTransferRecipient(t *testing.T) {
+	server, _ := fakePaystack(t)
+	client := newTestClient(server.URL)
+
+	recipient, err := client.CreateTransferRecipient(context.Background(), "Ada Obi", "0123456789", "058")
+	require.NoError(t, err)
+	assert.Equal(t, "RCP_test123", recipient.RecipientCode)
+	assert.Equal(t, "0123456789", recipient.Details.AccountNumber)
+
+	_, err = client.CreateTransferRecipient(context.Background(), "Ada Obi", "0000000000", "058")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "Could not resolve account name", apiErr.Message)
+}
+
+func TestClient_InitiateTransfer(t *testing.T) {
+	server, transfers := fakePaystack(t)
+	client := newTestClient(server.URL)
+
+	req := &TransferRequest{Amount: 1000000, Recipient: "RCP_test123", Reference: "payout-ref-0001"}
+	transfer, err := client.InitiateTransfer(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "TRF_test123", transfer.TransferCode)
+	assert.Equal(t, "pending", transfer.Status)
+	assert.Equal(t, "payout-ref-0001", transfer.Reference)
+
+	require.Len(t, *transfers, 1)
+	assert.Equal(t, "balance", (*transfers)[0].Source)
+	assert.Equal(t, "NGN", (*transfers)[0].Currency)
+	assert.Equal(t, int64(1000000), (*transfers)[0].Amount)
+
+	// Paystack rejects a reused reference, so a retry cannot pay twice
+	_, err = client.InitiateTransfer(context.Background(), req)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Len(t, *transfers, 1)
+}
+
+func TestClient_InvalidKey(t *testing.T) {
+	server, _ := fakePaystack(t)
+	client := NewClient(&config.PaystackConfig{SecretKey: "sk_wrong", BaseURL: server.URL})
+
+	_, err := client.ListBanks(context.Background())
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+// flakyPaystack answers with the given statuses in turn, then with the bank
+// list, and counts the requests it gets
+func flakyPaystack(t *testing.T, statuses ...int) (*Client, *atomic.Int32) {
+	requests := &atomic.Int32{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			writeEnvelope(w, statuses[n-1], false, http.StatusText(statuses[n-1]), nil)
+			return
+		}
+		writeEnvelope(w, http.StatusOK, true, "ok", []Bank{{Name: "Access Bank", Code: "044", Active: true}})
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(server.URL)
+	client.api.Backoff = time.Millisecond
+	return client, requests
+}
+
+func TestClient_RetriesTemporaryFailures(t *testing.T) {
+	client, requests := flakyPaystack(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	banks, err := client.ListBanks(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, banks, 1)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClient_GivesUpAfterMaxAttempts(t *testing.T) {
+	client, requests := flakyPaystack(t, 500, 502, 503, 504)
+
+	_, err := client.ListBanks(context.Background())
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.True(t, apiErr.Temporary())
+	assert.Equal(t, int32(apiclient.MaxAttempts), requests.Load())
+}
+
+func TestClient_DoesNotRetryWrites(t *testing.T) {
+	client, requests := flakyPaystack(t, http.StatusBadGateway)
+
+	_, err := client.InitiateTransfer(context.Background(), &TransferRequest{Amount: 1000, Recipient: "RCP_test123", Reference: "payout-ref-0002"})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "a transfer Paystack may have queued must not be sent again")
+}
+
+func TestClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(server.URL)
+	client.api.Timeout = 20 * time.Millisecond
+	client.api.Backoff = time.Millisecond
+
+	_, err := client.ResolveAccount(context.Background(), "0123456789", "058")
+	var reqErr *RequestError
+	require.True(t, errors.As(err, &reqErr))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "/bank/resolve", reqErr.Path)
+	assert.NotContains(t, err.Error(), "0123456789", "account numbers must not leak into errors")
+}
+
+func TestClient_CallerCancellation(t *testing.T) {
+	client, requests := flakyPaystack(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	client.api.Backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.ListBanks(ctx)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "no retry once the caller has given up")
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	client, requests := flakyPaystack(t, 500, 500, 500, 500)
+	client.api.Breaker = apiclient.NewBreaker(3, 50*time.Millisecond)
+
+	_, err := client.ListBanks(context.Background())
+	require.Error(t, err)
+	require.Equal(t, int32(3), requests.Load())
+
+	// Open: calls fail without reaching Paystack
+	_, err = client.ListBanks(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// After the cooldown one probe is let through; its failure reopens
+	time.Sleep(60 * time.Millisecond)
+	_, err = client.ListBanks(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(4), requests.Load())
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	banks, err := client.ListBanks(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, banks, 1)
+	_, err = client.ListBanks(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestClient_EscapesQuery(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		writeEnvelope(w, http.StatusOK, true, "Account number resolved", ResolvedAccount{AccountName: "ADA OBI"})
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := newTestClient(server.URL).ResolveAccount(context.Background(), "0123&bank_code=999", "058")
+	require.NoError(t, err)
+	assert.Equal(t, "0123&bank_code=999", query.Get("account_number"))
+	assert.Equal(t, []string{"058"}, query["bank_code"])
+}
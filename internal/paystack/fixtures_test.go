@@ -0,0 +1,138 @@
+package paystack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixture is a recorded Paystack response in testdata
+type fixture struct {
+	status int
+	file   string
+}
+
+// replay serves the recorded response for each "METHOD /path" in routes,
+// with queries ignored, and counts the requests it gets
+func replay(t *testing.T, routes map[string]fixture) (*Client, *atomic.Int32) {
+	requests := &atomic.Int32{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		f, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", f.file))
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(server.URL)
+	client.api.Backoff = time.Millisecond
+	return client, requests
+}
+
+func TestFixture_ListBanks(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"GET /bank": {http.StatusOK, "bank_list.json"}})
+
+	banks, err := client.ListBanks(context.Background())
+	require.NoError(t, err)
+	require.Len(t, banks, 3)
+	assert.Equal(t, Bank{
+		ID: 9, Name: "Guaranty Trust Bank", Slug: "guaranty-trust-bank", Code: "058", Longcode: "058152036",
+		Gateway: "ibank", Active: true, Country: "Nigeria", Currency: "NGN", Type: "nuban",
+	}, banks[1])
+	assert.True(t, banks[2].IsDeleted)
+
+	bank, err := client.BankByCode(context.Background(), "058")
+	require.NoError(t, err)
+	assert.Equal(t, "Guaranty Trust Bank", bank.Name)
+	_, err = client.BankByCode(context.Background(), "076")
+	assert.ErrorIs(t, err, ErrBankNotFound)
+}
+
+func TestFixture_ResolveAccount(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"GET /bank/resolve": {http.StatusOK, "resolve_account.json"}})
+
+	account, err := client.ResolveAccount(context.Background(), "0001234567", "058")
+	require.NoError(t, err)
+	assert.Equal(t, &ResolvedAccount{AccountNumber: "0001234567", AccountName: "DOE JOHN", BankID: 9}, account)
+}
+
+func TestFixture_ResolveAccountInvalid(t *testing.T) {
+	client, requests := replay(t, map[string]fixture{"GET /bank/resolve": {http.StatusUnprocessableEntity, "resolve_account_invalid.json"}})
+
+	_, err := client.ResolveAccount(context.Background(), "0000000000", "058")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	assert.Equal(t, "Could not resolve account name. Check parameters or try again.", apiErr.Message)
+	assert.False(t, apiErr.Temporary())
+	assert.Equal(t, int32(1), requests.Load(), "client errors must not be retried")
+}
+
+func TestFixture_CreateTransferRecipient(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"POST /transferrecipient": {http.StatusCreated, "transfer_recipient.json"}})
+
+	recipient, err := client.CreateTransferRecipient(context.Background(), "John Doe", "0001234567", "058")
+	require.NoError(t, err)
+	assert.Equal(t, "RCP_m7ljkv8leesep7p", recipient.RecipientCode)
+	assert.Equal(t, "DOE JOHN", recipient.Details.AccountName)
+	assert.Equal(t, "Guaranty Trust Bank", recipient.Details.BankName)
+}
+
+func TestFixture_InitiateTransfer(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"POST /transfer": {http.StatusOK, "transfer.json"}})
+
+	transfer, err := client.InitiateTransfer(context.Background(), &TransferRequest{Amount: 1000000, Recipient: "RCP_m7ljkv8leesep7p", Reference: "payout-3f2c1a"})
+	require.NoError(t, err)
+	assert.Equal(t, &Transfer{
+		ID: 476948, Reference: "payout-3f2c1a", TransferCode: "TRF_v5tip3zx8nna9o78",
+		Status: "pending", Amount: 1000000, Currency: "NGN",
+	}, transfer)
+}
+
+func TestFixture_VerifyTransfer(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"GET /transfer/verify/payout-3f2c1a": {http.StatusOK, "transfer_verify.json"}})
+
+	transfer, err := client.VerifyTransfer(context.Background(), "payout-3f2c1a")
+	require.NoError(t, err)
+	assert.Equal(t, "success", transfer.Status)
+	assert.Equal(t, "TRF_v5tip3zx8nna9o78", transfer.TransferCode)
+}
+
+func TestFixture_DuplicateTransfer(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"POST /transfer": {http.StatusBadRequest, "transfer_duplicate.json"}})
+
+	_, err := client.InitiateTransfer(context.Background(), &TransferRequest{Amount: 1000000, Recipient: "RCP_m7ljkv8leesep7p", Reference: "payout-3f2c1a"})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "Duplicate Transfer Reference", apiErr.Message)
+}
+
+func TestFixture_InvalidKey(t *testing.T) {
+	client, _ := replay(t, map[string]fixture{"GET /bank": {http.StatusUnauthorized, "invalid_key.json"}})
+
+	_, err := client.ListBanks(context.Background())
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "Invalid key", apiErr.Message)
+}
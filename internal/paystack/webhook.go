@@ -0,0 +1,66 @@
+package paystack
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Webhook event types handled by the payout flow
+const (
+	EventTransferSuccess  = "transfer.success"
+	EventTransferFailed   = "transfer.failed"
+	EventTransferReversed = "transfer.reversed"
+	EventChargeSuccess    = "charge.success"
+)
+
+// Event is the envelope Paystack posts to the webhook URL
+type Event struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// TransferEventData is the data payload of transfer.* events
+type TransferEventData struct {
+	ID           int64  `json:"id"`
+	Reference    string `json:"reference"`
+	TransferCode string `json:"transfer_code"`
+	Status       string `json:"status"`
+	Amount       int64  `json:"amount"`
+	Reason       string `json:"reason"`
+}
+
+// ID returns a stable identifier for deduplicating deliveries of the same
+// event. Paystack ids are only unique per object type, so the event name is
+// included.
+func (e *Event) ID() (string, error) {
+	var data struct {
+		ID json.Number `json:"id"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return "", err
+	}
+	if data.ID == "" {
+		return "", fmt.Errorf("paystack: event %q has no data.id", e.Event)
+	}
+	return e.Event + ":" + data.ID.String(), nil
+}
+
+// VerifySignature reports whether signature is the hex HMAC-SHA512 of body
+// keyed with the secret key, as sent in the x-paystack-signature header.
+func VerifySignature(secretKey string, body []byte, signature string) bool {
+	if secretKey == "" || signature == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha512.New, []byte(secretKey))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
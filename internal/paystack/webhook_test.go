@@ -0,0 +1,43 @@
+package paystack
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"event":"transfer.success","data":{"id":1}}`)
+	signature := sign("sk_test_secret", body)
+
+	assert.True(t, VerifySignature("sk_test_secret", body, signature))
+	assert.False(t, VerifySignature("sk_other", body, signature), "wrong key")
+	assert.False(t, VerifySignature("sk_test_secret", []byte(`{"event":"transfer.failed","data":{"id":1}}`), signature), "tampered body")
+	assert.False(t, VerifySignature("sk_test_secret", body, "not-hex"))
+	assert.False(t, VerifySignature("sk_test_secret", body, ""))
+	assert.False(t, VerifySignature("", body, sign("", body)), "unconfigured key never verifies")
+}
+
+func TestEvent_ID(t *testing.T) {
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(`{"event":"transfer.failed","data":{"id":123456789,"reference":"ref"}}`), &event))
+
+	id, err := event.ID()
+	require.NoError(t, err)
+	assert.Equal(t, "transfer.failed:123456789", id)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"event":"charge.success","data":{"reference":"ref"}}`), &event))
+	_, err = event.ID()
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type AdminInviteRepository struct {
+	db *database.DB
+}
+
+func NewAdminInviteRepository(db *database.DB) *AdminInviteRepository {
+	return &AdminInviteRepository{db: db}
+}
+
+// Create records a new invite with a fresh token, replacing any earlier
+// invite to the same address that has not been accepted
+func (r *AdminInviteRepository) Create(ctx context.Context, invite *models.AdminInvite, expiry time.Duration) error {
+	token, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+	invite.Token = token
+	invite.ExpiresAt = time.Now().Add(expiry)
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM admin_invites WHERE email = $1 AND accepted_at IS NULL", invite.Email); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO admin_invites (email, admin_role, token, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, invite.Email, invite.AdminRole, invite.Token, invite.InvitedBy, invite.ExpiresAt).Scan(&invite.ID, &invite.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetByToken returns an invite that can still be accepted
+func (r *AdminInviteRepository) GetByToken(ctx context.Context, token string) (*models.AdminInvite, error) {
+	query := `
+		SELECT id, email, admin_role, token, invited_by, expires_at, accepted_at, created_at
+		FROM admin_invites WHERE token = $1
+	`
+	var invite models.AdminInvite
+	err := r.db.Pool.QueryRow(ctx, query, token).Scan(
+		&invite.ID, &invite.Email, &invite.AdminRole, &invite.Token, &invite.InvitedBy,
+		&invite.ExpiresAt, &invite.AcceptedAt, &invite.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	if invite.AcceptedAt != nil {
+		return nil, ErrTokenNotFound
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return &invite, nil
+}
+
+// Accept uses up an invite and creates the admin account it was for, in one
+// transaction so an invite can only ever create one account
+func (r *AdminInviteRepository) Accept(ctx context.Context, inviteID uuid.UUID, user *models.User) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE admin_invites SET accepted_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+	`, inviteID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTokenNotFound
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (id, email, password_hash, name, phone, role, admin_role, referral_code, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at
+	`, user.ID, user.Email, user.PasswordHash, user.Name, user.Phone, user.Role, user.AdminRole,
+		user.ReferralCode, user.EmailVerifiedAt,
+	).Scan(&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrUserExists
+		}
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
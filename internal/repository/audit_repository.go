@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+)
+
+type AuditRepository struct {
+	db *database.DB
+}
+
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (actor_id, actor_email, action, target_type, target_id, before, after, status_code, request_id, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+
+	return r.db.Pool.QueryRow(ctx, query,
+		event.ActorID, event.ActorEmail, event.Action, event.TargetType, event.TargetID,
+		nullJSON(event.Before), nullJSON(event.After), event.StatusCode, event.RequestID, event.IPAddress,
+	).Scan(&event.ID, &event.CreatedAt)
+}
+
+// List returns audit events matching filter, newest first
+func (r *AuditRepository) List(ctx context.Context, filter models.AuditFilter, page, perPage int) ([]models.AuditEvent, int64, error) {
+	var c conditions
+	if filter.ActorID != nil {
+		c.add("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		c.add("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		c.add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		c.add("target_id = $%d", filter.TargetID)
+	}
+	if filter.From != nil {
+		c.add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		c.add("created_at < $%d", *filter.To)
+	}
+
+	var total int64
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_events `+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, args := c.page(page, perPage)
+	query := fmt.Sprintf(`
+		SELECT id, actor_id, actor_email, action, target_type, target_id, before, after, status_code, request_id, ip_address, created_at
+		FROM audit_events
+		%s
+		ORDER BY created_at DESC
+		%s
+	`, c.where(), limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var e models.AuditEvent
+		if err := rows.Scan(
+			&e.ID, &e.ActorID, &e.ActorEmail, &e.Action, &e.TargetType, &e.TargetID,
+			&e.Before, &e.After, &e.StatusCode, &e.RequestID, &e.IPAddress, &e.CreatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+
+	return events, total, rows.Err()
+}
+
+// nullJSON stores empty payloads as SQL NULL rather than invalid JSON
+func nullJSON(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
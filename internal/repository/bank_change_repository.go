@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrBankChangeNotFound = errors.New("no pending bank detail change")
+
+type BankChangeRepository struct {
+	db *database.DB
+}
+
+func NewBankChangeRepository(db *database.DB) *BankChangeRepository {
+	return &BankChangeRepository{db: db}
+}
+
+// bankChangeColumns are selected by every query here. A pending change past
+// its expiry reads as expired.
+const bankChangeColumns = `
+	id, user_id, bank_code, bank_name, account_number, account_name, bank_name_mismatch,
+	previous_bank_name, previous_account_number, previous_account_name,
+	CASE WHEN status = 'pending' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+	COALESCE(token, ''), ip_address, expires_at, confirmed_at, hold_until, created_at
+`
+
+func scanBankChange(row pgx.Row) (*models.BankDetailChange, error) {
+	var c models.BankDetailChange
+	err := row.Scan(
+		&c.ID, &c.UserID, &c.BankCode, &c.BankName, &c.AccountNumber, &c.AccountName, &c.BankNameMismatch,
+		&c.PreviousBankName, &c.PreviousAccountNumber, &c.PreviousAccountName,
+		&c.Status, &c.Token, &c.IPAddress, &c.ExpiresAt, &c.ConfirmedAt, &c.HoldUntil, &c.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Create stores a pending change with a new confirmation token valid for
+// expiry. Any change the user still has pending is cancelled, so only the
+// latest link works.
+func (r *BankChangeRepository) Create(ctx context.Context, change *models.BankDetailChange, expiry time.Duration) error {
+	token, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	change.ID = uuid.New()
+	change.Token = token
+	change.Status = models.BankChangePending
+	change.ExpiresAt = time.Now().Add(expiry)
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE bank_detail_changes SET status = 'cancelled', token = NULL
+		WHERE user_id = $1 AND status = 'pending'
+	`, change.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO bank_detail_changes (id, user_id, bank_code, bank_name, account_number, account_name, bank_name_mismatch, token, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at
+	`
+	err = tx.QueryRow(ctx, query,
+		change.ID, change.UserID, change.BankCode, change.BankName, change.AccountNumber, change.AccountName,
+		change.BankNameMismatch, change.Token, change.IPAddress, change.ExpiresAt,
+	).Scan(&change.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetPending returns the user's unexpired pending change
+func (r *BankChangeRepository) GetPending(ctx context.Context, userID uuid.UUID) (*models.BankDetailChange, error) {
+	query := `SELECT ` + bankChangeColumns + `
+		FROM bank_detail_changes
+		WHERE user_id = $1 AND status = 'pending' AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	change, err := scanBankChange(r.db.Pool.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrBankChangeNotFound
+		}
+		return nil, err
+	}
+	return change, nil
+}
+
+// Confirm applies the pending change with token to the user's bank details
+// and records the details it replaced. If the user already had an account,
+// payouts are held for cooldown. Returns ErrTokenNotFound for an unknown or
+// used token and ErrTokenExpired for an expired one.
+func (r *BankChangeRepository) Confirm(ctx context.Context, token string, cooldown time.Duration) (*models.BankDetailChange, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	change, err := scanBankChange(tx.QueryRow(ctx, `SELECT `+bankChangeColumns+`
+		FROM bank_detail_changes WHERE token = $1 AND status = 'pending'
+		FOR UPDATE
+	`, token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	if change.Status == models.BankChangeExpired {
+		return nil, ErrTokenExpired
+	}
+
+	var previousBank, previousNumber, previousName string
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(bank_name, ''), COALESCE(account_number, ''), COALESCE(account_name, '')
+		FROM users WHERE id = $1
+		FOR UPDATE
+	`, change.UserID).Scan(&previousBank, &previousNumber, &previousName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	// A first account has no earlier one a hijacker could be diverting from,
+	// so only replacements are held
+	if previousNumber != "" {
+		change.PreviousBankName = &previousBank
+		change.PreviousAccountNumber = &previousNumber
+		change.PreviousAccountName = &previousName
+
+		if cooldown > 0 {
+			holdUntil := time.Now().Add(cooldown)
+			change.HoldUntil = &holdUntil
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET
+			bank_code = $2, bank_name = $3, account_number = $4, account_name = $5,
+			bank_verified_at = $6, bank_name_mismatch = $7, bank_hold_until = $8,
+			payout_recipient_code = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, change.UserID, change.BankCode, change.BankName, change.AccountNumber, change.AccountName,
+		change.CreatedAt, change.BankNameMismatch, change.HoldUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE bank_detail_changes SET
+			status = 'confirmed', token = NULL, confirmed_at = NOW(), hold_until = $2,
+			previous_bank_name = $3, previous_account_number = $4, previous_account_name = $5
+		WHERE id = $1
+		RETURNING confirmed_at
+	`, change.ID, change.HoldUntil, change.PreviousBankName, change.PreviousAccountNumber, change.PreviousAccountName).Scan(&change.ConfirmedAt)
+	if err != nil {
+		return nil, err
+	}
+	change.Status = models.BankChangeConfirmed
+	change.Token = ""
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// ListByUser returns a user's bank detail changes, newest first
+func (r *BankChangeRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.BankDetailChange, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+bankChangeColumns+`
+		FROM bank_detail_changes WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.BankDetailChange{}
+	for rows.Next() {
+		change, err := scanBankChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, *change)
+	}
+
+	return changes, rows.Err()
+}
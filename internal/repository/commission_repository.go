@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrCommissionRuleNotFound = errors.New("commission rule not found")
+)
+
+type CommissionRepository struct {
+	db *database.DB
+}
+
+func NewCommissionRepository(db *database.DB) *CommissionRepository {
+	return &CommissionRepository{db: db}
+}
+
+func (r *CommissionRepository) Create(ctx context.Context, rule *models.CommissionRule) error {
+	query := `
+		INSERT INTO commission_rules (id, name, course_id, type, value, cap, effective_from, effective_to, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+
+	return r.db.Pool.QueryRow(ctx, query,
+		rule.ID, rule.Name, rule.CourseID, rule.Type, rule.Value, rule.Cap,
+		rule.EffectiveFrom, rule.EffectiveTo, rule.CreatedBy,
+	).Scan(&rule.CreatedAt)
+}
+
+func (r *CommissionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.CommissionRule, error) {
+	query := `
+		SELECT cr.id, cr.name, cr.course_id, COALESCE(c.name, ''), cr.type, cr.value, cr.cap,
+		       cr.effective_from, cr.effective_to, cr.created_by, cr.created_at
+		FROM commission_rules cr
+		LEFT JOIN courses c ON cr.course_id = c.id
+		WHERE cr.id = $1
+	`
+
+	rule := &models.CommissionRule{}
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
+		&rule.ID, &rule.Name, &rule.CourseID, &rule.CourseName, &rule.Type, &rule.Value, &rule.Cap,
+		&rule.EffectiveFrom, &rule.EffectiveTo, &rule.CreatedBy, &rule.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCommissionRuleNotFound
+		}
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// List returns all commission rules, newest first
+func (r *CommissionRepository) List(ctx context.Context) ([]models.CommissionRule, error) {
+	query := `
+		SELECT cr.id, cr.name, cr.course_id, COALESCE(c.name, ''), cr.type, cr.value, cr.cap,
+		       cr.effective_from, cr.effective_to, cr.created_by, cr.created_at
+		FROM commission_rules cr
+		LEFT JOIN courses c ON cr.course_id = c.id
+		ORDER BY cr.effective_from DESC, cr.created_at DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.CommissionRule{}
+	for rows.Next() {
+		var rule models.CommissionRule
+		if err := rows.Scan(
+			&rule.ID, &rule.Name, &rule.CourseID, &rule.CourseName, &rule.Type, &rule.Value, &rule.Cap,
+			&rule.EffectiveFrom, &rule.EffectiveTo, &rule.CreatedBy, &rule.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// GetActiveForCourse returns the rule in effect for a course at the given time.
+// Course-specific rules take precedence over global rules; among equals the most
+// recently started rule wins.
+func (r *CommissionRepository) GetActiveForCourse(ctx context.Context, courseID uuid.UUID, at time.Time) (*models.CommissionRule, error) {
+	query := `
+		SELECT id, name, course_id, type, value, cap, effective_from, effective_to, created_by, created_at
+		FROM commission_rules
+		WHERE (course_id = $1 OR course_id IS NULL)
+		  AND effective_from <= $2
+		  AND (effective_to IS NULL OR effective_to > $2)
+		ORDER BY (course_id IS NULL) ASC, effective_from DESC, created_at DESC
+		LIMIT 1
+	`
+
+	rule := &models.CommissionRule{}
+	err := r.db.Pool.QueryRow(ctx, query, courseID, at).Scan(
+		&rule.ID, &rule.Name, &rule.CourseID, &rule.Type, &rule.Value, &rule.Cap,
+		&rule.EffectiveFrom, &rule.EffectiveTo, &rule.CreatedBy, &rule.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCommissionRuleNotFound
+		}
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// End closes a rule's effective range at the given time. Rules are never edited
+// in place so that referrals stay traceable to the terms that produced them.
+func (r *CommissionRepository) End(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE commission_rules
+		SET effective_to = GREATEST($2, effective_from + INTERVAL '1 second')
+		WHERE id = $1 AND (effective_to IS NULL OR effective_to > $2)
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, id, at)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrCommissionRuleNotFound
+	}
+
+	return nil
+}
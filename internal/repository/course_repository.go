@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrCourseNotFound = errors.New("course not found")
+	ErrCourseExists   = errors.New("course already exists")
+)
+
+type CourseRepository struct {
+	db *database.DB
+}
+
+func NewCourseRepository(db *database.DB) *CourseRepository {
+	return &CourseRepository{db: db}
+}
+
+func (r *CourseRepository) Create(ctx context.Context, course *models.Course) error {
+	query := `
+		INSERT INTO courses (id, name, price)
+		VALUES ($1, $2, $3)
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query, course.ID, course.Name, course.Price).
+		Scan(&course.CreatedAt, &course.UpdatedAt)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrCourseExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *CourseRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Course, error) {
+	query := `
+		SELECT id, name, price, archived_at, created_at, updated_at
+		FROM courses WHERE id = $1
+	`
+
+	course := &models.Course{}
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
+		&course.ID, &course.Name, &course.Price, &course.ArchivedAt, &course.CreatedAt, &course.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+
+	return course, nil
+}
+
+// GetActiveByName returns a course from the active catalog by its exact name
+func (r *CourseRepository) GetActiveByName(ctx context.Context, name string) (*models.Course, error) {
+	query := `
+		SELECT id, name, price, archived_at, created_at, updated_at
+		FROM courses WHERE name = $1 AND archived_at IS NULL
+	`
+
+	course := &models.Course{}
+	err := r.db.Pool.QueryRow(ctx, query, name).Scan(
+		&course.ID, &course.Name, &course.Price, &course.ArchivedAt, &course.CreatedAt, &course.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+
+	return course, nil
+}
+
+// List returns the course catalog ordered by name, optionally including archived courses
+func (r *CourseRepository) List(ctx context.Context, includeArchived bool) ([]models.Course, error) {
+	query := `
+		SELECT id, name, price, archived_at, created_at, updated_at
+		FROM courses
+		WHERE $1 OR archived_at IS NULL
+		ORDER BY name ASC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	courses := []models.Course{}
+	for rows.Next() {
+		var c models.Course
+		if err := rows.Scan(&c.ID, &c.Name, &c.Price, &c.ArchivedAt, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		courses = append(courses, c)
+	}
+
+	return courses, rows.Err()
+}
+
+// UpdatePrice changes the price of a course
+func (r *CourseRepository) UpdatePrice(ctx context.Context, course *models.Course) error {
+	query := `
+		UPDATE courses SET price = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING name, archived_at, created_at, updated_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query, course.ID, course.Price).
+		Scan(&course.Name, &course.ArchivedAt, &course.CreatedAt, &course.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCourseNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Archive removes a course from the active catalog without deleting it
+func (r *CourseRepository) Archive(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE courses SET archived_at = COALESCE(archived_at, NOW()), updated_at = NOW() WHERE id = $1`
+
+	result, err := r.db.Pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrCourseNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrUnbalancedEntry   = errors.New("ledger entry postings must sum to zero")
+	ErrLedgerEntryExists = errors.New("ledger entry already recorded")
+)
+
+type LedgerRepository struct {
+	db *database.DB
+}
+
+func NewLedgerRepository(db *database.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// Post records a journal entry in its own transaction
+func (r *LedgerRepository) Post(ctx context.Context, entry *models.LedgerEntry) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := r.PostTx(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PostTx records a journal entry inside an existing transaction. Entries that
+// were already recorded for the same kind and reference return
+// ErrLedgerEntryExists without writing anything.
+func (r *LedgerRepository) PostTx(ctx context.Context, tx pgx.Tx, entry *models.LedgerEntry) error {
+	if len(entry.Postings) < 2 {
+		return ErrUnbalancedEntry
+	}
+	var sum int64
+	for _, p := range entry.Postings {
+		if p.Amount == 0 {
+			return ErrUnbalancedEntry
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalancedEntry
+	}
+
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+
+	var referenceType *string
+	if entry.ReferenceType != "" {
+		referenceType = &entry.ReferenceType
+	}
+
+	query := `
+		INSERT INTO ledger_entries (id, kind, description, reference_type, reference_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (kind, reference_type, reference_id) DO NOTHING
+		RETURNING created_at
+	`
+	err := tx.QueryRow(ctx, query,
+		entry.ID, entry.Kind, entry.Description, referenceType, entry.ReferenceID, entry.CreatedBy,
+	).Scan(&entry.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrLedgerEntryExists
+		}
+		return err
+	}
+
+	for _, p := range entry.Postings {
+		accountID, err := r.resolveAccount(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES ($1, $2, $3)`,
+			entry.ID, accountID, p.Amount,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveAccount returns the account a posting targets, opening it on first
+// use.
+func (r *LedgerRepository) resolveAccount(ctx context.Context, tx pgx.Tx, p models.LedgerPosting) (uuid.UUID, error) {
+	var accountID uuid.UUID
+
+	if p.UserID != nil {
+		query := `
+			INSERT INTO ledger_accounts (code, type, user_id)
+			VALUES ('referrer:' || $1::text, 'referrer_payable', $1)
+			ON CONFLICT (user_id) DO UPDATE SET code = ledger_accounts.code
+			RETURNING id
+		`
+		err := tx.QueryRow(ctx, query, *p.UserID).Scan(&accountID)
+		return accountID, err
+	}
+
+	query := `
+		INSERT INTO ledger_accounts (code, type)
+		VALUES ($1, 'platform')
+		ON CONFLICT (code) DO UPDATE SET code = ledger_accounts.code
+		RETURNING id
+	`
+	err := tx.QueryRow(ctx, query, p.AccountCode).Scan(&accountID)
+	return accountID, err
+}
+
+// UserSummary returns the ledger position of a single referrer
+func (r *LedgerRepository) UserSummary(ctx context.Context, userID uuid.UUID) (*models.LedgerSummary, error) {
+	query := `
+		SELECT
+			COALESCE(-SUM(CASE WHEN e.kind NOT IN ('payout', 'payout_reversal') THEN p.amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN e.kind IN ('payout', 'payout_reversal') THEN p.amount ELSE 0 END), 0),
+			COALESCE(-SUM(p.amount), 0)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON p.entry_id = e.id
+		JOIN ledger_accounts a ON p.account_id = a.id
+		WHERE a.user_id = $1
+	`
+
+	summary := &models.LedgerSummary{}
+	err := r.db.Pool.QueryRow(ctx, query, userID).Scan(&summary.Earned, &summary.PaidOut, &summary.Balance)
+	return summary, err
+}
+
+// PlatformSummary aggregates all referrer accounts, excluding admins
+func (r *LedgerRepository) PlatformSummary(ctx context.Context) (*models.LedgerSummary, error) {
+	query := `
+		SELECT
+			COALESCE(-SUM(CASE WHEN e.kind NOT IN ('payout', 'payout_reversal') THEN p.amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN e.kind IN ('payout', 'payout_reversal') THEN p.amount ELSE 0 END), 0),
+			COALESCE(-SUM(p.amount), 0)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON p.entry_id = e.id
+		JOIN ledger_accounts a ON p.account_id = a.id
+		JOIN users u ON a.user_id = u.id
+		WHERE a.type = 'referrer_payable' AND u.role != 'admin'
+	`
+
+	summary := &models.LedgerSummary{}
+	err := r.db.Pool.QueryRow(ctx, query).Scan(&summary.Earned, &summary.PaidOut, &summary.Balance)
+	return summary, err
+}
+
+// ListByUser returns a referrer's statement, newest first
+func (r *LedgerRepository) ListByUser(ctx context.Context, userID uuid.UUID, page, perPage int) ([]models.LedgerStatementLine, int64, error) {
+	offset := (page - 1) * perPage
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM ledger_postings p
+		JOIN ledger_accounts a ON p.account_id = a.id
+		WHERE a.user_id = $1
+	`
+	var total int64
+	if err := r.db.Pool.QueryRow(ctx, countQuery, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT e.id, e.kind, e.description, -p.amount, e.created_at
+		FROM ledger_postings p
+		JOIN ledger_entries e ON p.entry_id = e.id
+		JOIN ledger_accounts a ON p.account_id = a.id
+		WHERE a.user_id = $1
+		ORDER BY e.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lines := []models.LedgerStatementLine{}
+	for rows.Next() {
+		var line models.LedgerStatementLine
+		if err := rows.Scan(&line.EntryID, &line.Kind, &line.Description, &line.Amount, &line.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, total, rows.Err()
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportBatchSize is how many rows an export fetches from its cursor at a time
+const exportBatchSize = 1000
+
+// SortColumns maps the sort keys a list endpoint accepts to the SQL they
+// order by. Only these expressions ever reach ORDER BY, so a sort key from a
+// request cannot inject SQL.
+type SortColumns map[string]string
+
+// Keys returns the accepted sort keys in a stable order
+func (c SortColumns) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orderBy builds an ORDER BY clause for key, falling back to fallback for an
+// empty or unknown key. tiebreak is appended as is to keep pages stable when
+// sort values repeat.
+func (c SortColumns) orderBy(key, order, fallback, tiebreak string) string {
+	column, ok := c[key]
+	if !ok {
+		column = c[fallback]
+	}
+	direction := "DESC"
+	if strings.EqualFold(order, "asc") {
+		direction = "ASC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s", column, direction, tiebreak)
+}
+
+// conditions collects the WHERE conditions for a list query along with their
+// arguments. Each condition is a format string whose %d verbs become the
+// placeholder for its value (use %[1]d to refer to it more than once), so
+// values are always bound as parameters rather than written into the SQL.
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+func (c *conditions) add(condition string, value interface{}) {
+	c.args = append(c.args, value)
+	c.clauses = append(c.clauses, fmt.Sprintf(condition, len(c.args)))
+}
+
+// join returns the conditions joined with AND behind keyword, or "" if there
+// are none
+func (c *conditions) join(keyword string) string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return keyword + " " + strings.Join(c.clauses, " AND ")
+}
+
+func (c *conditions) where() string {
+	return c.join("WHERE")
+}
+
+// take returns the conditions added so far joined behind keyword and starts
+// a new set. Arguments are kept, so placeholders keep counting up; this lets
+// one query have, say, both JOIN and WHERE conditions.
+func (c *conditions) take(keyword string) string {
+	clause := c.join(keyword)
+	c.clauses = nil
+	return clause
+}
+
+// page appends LIMIT and OFFSET placeholders for a page and returns the
+// clause with the full argument list
+func (c *conditions) page(page, perPage int) (string, []interface{}) {
+	n := len(c.args)
+	args := append(append([]interface{}{}, c.args...), perPage, (page-1)*perPage)
+	return fmt.Sprintf("LIMIT $%d OFFSET $%d", n+1, n+2), args
+}
+
+// containsPattern turns free text into an ILIKE pattern matching it
+// anywhere, so that % and _ typed by the user match themselves
+func containsPattern(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + escaped + "%"
+}
+
+// stream runs query through a server-side cursor and calls fn for each row,
+// fetching exportBatchSize rows at a time so exports of any size use little
+// memory. The read-only repeatable read transaction gives the whole export
+// one consistent snapshot.
+func stream(ctx context.Context, db *database.DB, query string, args []interface{}, fn func(pgx.Rows) error) error {
+	tx, err := db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM export_cursor", exportBatchSize)
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return err
+		}
+
+		n := 0
+		for rows.Next() {
+			n++
+			if err := fn(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if n < exportBatchSize {
+			break
+		}
+	}
+
+	return tx.Commit(ctx)
+}
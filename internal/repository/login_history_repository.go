@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type LoginHistoryRepository struct {
+	db *database.DB
+}
+
+func NewLoginHistoryRepository(db *database.DB) *LoginHistoryRepository {
+	return &LoginHistoryRepository{db: db}
+}
+
+func (r *LoginHistoryRepository) Create(ctx context.Context, event *models.LoginEvent) error {
+	query := `
+		INSERT INTO login_history (user_id, ip_address, user_agent, success, failure_reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	return r.db.Pool.QueryRow(ctx, query,
+		event.UserID, event.IPAddress, event.UserAgent, event.Success, event.FailureReason,
+	).Scan(&event.ID, &event.CreatedAt)
+}
+
+// HasSucceeded reports whether the user has signed in successfully before,
+// from anywhere
+func (r *LoginHistoryRepository) HasSucceeded(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM login_history WHERE user_id = $1 AND success)`, userID).Scan(&exists)
+	return exists, err
+}
+
+// HasSucceededFrom reports whether the user has signed in successfully from
+// this IP address and user agent before
+func (r *LoginHistoryRepository) HasSucceededFrom(ctx context.Context, userID uuid.UUID, ipAddress, userAgent string) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM login_history
+			WHERE user_id = $1 AND success AND ip_address = $2 AND user_agent = $3
+		)
+	`, userID, ipAddress, userAgent).Scan(&exists)
+	return exists, err
+}
+
+// ListByUser returns a user's sign-in attempts, newest first
+func (r *LoginHistoryRepository) ListByUser(ctx context.Context, userID uuid.UUID, page, perPage int) ([]models.LoginEvent, int64, error) {
+	offset := (page - 1) * perPage
+
+	var total int64
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM login_history WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, user_id, ip_address, user_agent, success, failure_reason, created_at
+		FROM login_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.Pool.Query(ctx, query, userID, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []models.LoginEvent{}
+	for rows.Next() {
+		var e models.LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.IPAddress, &e.UserAgent, &e.Success, &e.FailureReason, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+
+	return events, total, rows.Err()
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MFAState is a user's two-factor enrolment. A secret without EnabledAt is an
+// enrolment that was started but not confirmed.
+type MFAState struct {
+	SecretEncrypted *string
+	EnabledAt       *time.Time
+}
+
+type MFARepository struct {
+	db *database.DB
+}
+
+func NewMFARepository(db *database.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// GetState returns a user's two-factor enrolment
+func (r *MFARepository) GetState(ctx context.Context, userID uuid.UUID) (*MFAState, error) {
+	state := &MFAState{}
+	err := r.db.Pool.QueryRow(ctx, `SELECT totp_secret_encrypted, totp_enabled_at FROM users WHERE id = $1`, userID).
+		Scan(&state.SecretEncrypted, &state.EnabledAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// SetPendingSecret stores a new, not yet confirmed secret. It does nothing
+// for a user who already has two-factor authentication enabled.
+func (r *MFARepository) SetPendingSecret(ctx context.Context, userID uuid.UUID, secretEncrypted string) error {
+	query := `UPDATE users SET totp_secret_encrypted = $2, updated_at = NOW() WHERE id = $1 AND totp_enabled_at IS NULL`
+	result, err := r.db.Pool.Exec(ctx, query, userID, secretEncrypted)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Enable confirms the pending secret and replaces the user's recovery codes.
+// counter is the time step of the code that confirmed it, which cannot be
+// used again.
+func (r *MFARepository) Enable(ctx context.Context, userID uuid.UUID, counter int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE users SET totp_enabled_at = NOW(), totp_last_counter = $2, updated_at = NOW()
+		WHERE id = $1 AND totp_secret_encrypted IS NOT NULL
+	`, userID, counter)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Disable removes the user's secret and recovery codes
+func (r *MFARepository) Disable(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE users SET totp_secret_encrypted = NULL, totp_enabled_at = NULL, totp_last_counter = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPCounter records counter as the time step of the last accepted
+// authenticator code. It reports false if a code for the same or a later
+// step was already accepted, so each code works once.
+func (r *MFARepository) UseTOTPCounter(ctx context.Context, userID uuid.UUID, counter int64) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE users SET totp_last_counter = $2
+		WHERE id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2)
+	`, userID, counter)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false if
+// the user has no unused code with that hash.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/cirvee/referral-backend/internal/database"
 	"github.com/cirvee/referral-backend/internal/models"
@@ -11,7 +12,12 @@ import (
 )
 
 var (
-	ErrPayoutNotFound = errors.New("payout not found")
+	ErrPayoutNotFound      = errors.New("payout not found")
+	ErrPendingPayoutExists = errors.New("a pending payout already exists")
+	ErrPayoutNotPending    = errors.New("payout is not pending")
+	ErrNoEligibleReferrals = errors.New("no pending referrals fit the requested amount")
+	ErrPayoutNotApproved   = errors.New("payout is not approved")
+	ErrPayoutHasTransfer   = errors.New("payout has a bank transfer that has not failed")
 )
 
 type PayoutRepository struct {
@@ -34,16 +40,147 @@ func (r *PayoutRepository) Create(ctx context.Context, payout *models.Payout) er
 	).Scan(&payout.CreatedAt)
 }
 
+// CreateWithReferrals reserves the referrer's oldest pending referrals whose
+// earnings fit within maxAmount and creates a pending payout covering exactly
+// those referrals. payout.Amount is set to the reserved total. The user row is
+// locked so concurrent requests cannot both pass the one-pending-payout check.
+func (r *PayoutRepository) CreateWithReferrals(ctx context.Context, payout *models.Payout, maxAmount int64) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, payout.UserID); err != nil {
+		return err
+	}
+
+	if err := setActor(ctx, tx, payout.UserID); err != nil {
+		return err
+	}
+
+	var exists bool
+	existsQuery := `SELECT EXISTS(SELECT 1 FROM payouts WHERE user_id = $1 AND status = 'pending')`
+	if err := tx.QueryRow(ctx, existsQuery, payout.UserID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrPendingPayoutExists
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, earnings, status FROM referrals
+		WHERE referrer_id = $1 AND status IN ('pending', 'approved') AND earnings > 0
+		ORDER BY created_at ASC
+		FOR UPDATE
+	`, payout.UserID)
+	if err != nil {
+		return err
+	}
+
+	var items []models.PayoutItem
+	var total int64
+	for rows.Next() {
+		var item models.PayoutItem
+		if err := rows.Scan(&item.ReferralID, &item.Amount, &item.PreviousStatus); err != nil {
+			rows.Close()
+			return err
+		}
+		if total+item.Amount > maxAmount {
+			continue
+		}
+		total += item.Amount
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		return ErrNoEligibleReferrals
+	}
+
+	payout.Amount = total
+	query := `
+		INSERT INTO payouts (id, user_id, amount, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+	if err := tx.QueryRow(ctx, query,
+		payout.ID, payout.UserID, payout.Amount, payout.Status,
+	).Scan(&payout.CreatedAt); err != nil {
+		return err
+	}
+
+	referralIDs := make([]uuid.UUID, 0, len(items))
+	for i := range items {
+		items[i].ID = uuid.New()
+		items[i].PayoutID = payout.ID
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO payout_items (id, payout_id, referral_id, amount, referral_status)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING created_at
+		`, items[i].ID, payout.ID, items[i].ReferralID, items[i].Amount, items[i].PreviousStatus).Scan(&items[i].CreatedAt); err != nil {
+			return err
+		}
+		referralIDs = append(referralIDs, items[i].ReferralID)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE referrals SET status = 'processing' WHERE id = ANY($1)`, referralIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	payout.Items = items
+	return nil
+}
+
+// ListItems returns the referrals settled by a payout
+func (r *PayoutRepository) ListItems(ctx context.Context, payoutID uuid.UUID) ([]models.PayoutItem, error) {
+	query := `
+		SELECT pi.id, pi.payout_id, pi.referral_id, pi.amount, r.referred_name, r.course, r.status, pi.created_at
+		FROM payout_items pi
+		JOIN referrals r ON pi.referral_id = r.id
+		WHERE pi.payout_id = $1
+		ORDER BY r.created_at ASC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.PayoutItem{}
+	for rows.Next() {
+		var item models.PayoutItem
+		if err := rows.Scan(
+			&item.ID, &item.PayoutID, &item.ReferralID, &item.Amount,
+			&item.ReferredName, &item.Course, &item.Status, &item.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
 func (r *PayoutRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Payout, error) {
 	query := `
-		SELECT id, user_id, amount, status, approved_by, created_at, paid_at
+		SELECT id, user_id, amount, status, approved_by, transfer_provider, transfer_reference, transfer_code, transfer_status, created_at, paid_at
 		FROM payouts WHERE id = $1
 	`
 
 	payout := &models.Payout{}
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&payout.ID, &payout.UserID, &payout.Amount, &payout.Status,
-		&payout.ApprovedBy, &payout.CreatedAt, &payout.PaidAt,
+		&payout.ApprovedBy, &payout.TransferProvider, &payout.TransferReference, &payout.TransferCode, &payout.TransferStatus,
+		&payout.CreatedAt, &payout.PaidAt,
 	)
 
 	if err != nil {
@@ -66,7 +203,7 @@ func (r *PayoutRepository) ListByUser(ctx context.Context, userID uuid.UUID, pag
 	}
 
 	query := `
-		SELECT id, user_id, amount, status, approved_by, created_at, paid_at
+		SELECT id, user_id, amount, status, approved_by, transfer_provider, transfer_reference, transfer_code, transfer_status, created_at, paid_at
 		FROM payouts WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
@@ -78,12 +215,13 @@ func (r *PayoutRepository) ListByUser(ctx context.Context, userID uuid.UUID, pag
 	}
 	defer rows.Close()
 
-	var payouts []models.Payout
+	payouts := []models.Payout{}
 	for rows.Next() {
 		var p models.Payout
 		if err := rows.Scan(
 			&p.ID, &p.UserID, &p.Amount, &p.Status,
-			&p.ApprovedBy, &p.CreatedAt, &p.PaidAt,
+			&p.ApprovedBy, &p.TransferProvider, &p.TransferReference, &p.TransferCode, &p.TransferStatus,
+			&p.CreatedAt, &p.PaidAt,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -93,88 +231,271 @@ func (r *PayoutRepository) ListByUser(ctx context.Context, userID uuid.UUID, pag
 	return payouts, total, nil
 }
 
-func (r *PayoutRepository) ListAll(ctx context.Context, page, perPage int, status *models.PayoutStatus) ([]models.Payout, int64, error) {
-	offset := (page - 1) * perPage
+// PayoutSorts are the sort keys ListAll accepts
+var PayoutSorts = SortColumns{
+	"created_at": "p.created_at",
+	"amount":     "p.amount",
+	"status":     "p.status",
+	"paid_at":    "p.paid_at",
+}
 
-	var countQuery, query string
-	var args []interface{}
-
-	if status != nil {
-		countQuery = `SELECT COUNT(*) FROM payouts WHERE status = $1`
-		query = `
-			SELECT p.id, p.user_id, p.amount, p.status, p.approved_by, p.created_at, p.paid_at,
-			       u.name, u.email, u.phone, u.referral_code, u.bank_name, u.account_number, u.account_name
-			FROM payouts p
-			LEFT JOIN users u ON p.user_id = u.id
-			WHERE p.status = $1
-			ORDER BY p.created_at DESC
-			LIMIT $2 OFFSET $3
-		`
-		args = []interface{}{*status, perPage, offset}
-	} else {
-		countQuery = `SELECT COUNT(*) FROM payouts`
-		query = `
-			SELECT p.id, p.user_id, p.amount, p.status, p.approved_by, p.created_at, p.paid_at,
-			       u.name, u.email, u.phone, u.referral_code, u.bank_name, u.account_number, u.account_name
-			FROM payouts p
-			LEFT JOIN users u ON p.user_id = u.id
-			ORDER BY p.created_at DESC
-			LIMIT $1 OFFSET $2
-		`
-		args = []interface{}{perPage, offset}
-	}
+// ListAll returns payouts matching filter with their user, newest first
+// unless filter says otherwise. ReferrerID is the user paid; search matches
+// their name, email or code, or the transfer reference.
+func (r *PayoutRepository) ListAll(ctx context.Context, filter models.ListFilter, page, perPage int) ([]models.Payout, int64, error) {
+	c := payoutConditions(filter)
 
+	countQuery := `SELECT COUNT(*) FROM payouts p LEFT JOIN users u ON p.user_id = u.id ` + c.where()
 	var total int64
-	if status != nil {
-		if err := r.db.Pool.QueryRow(ctx, countQuery, *status).Scan(&total); err != nil {
-			return nil, 0, err
-		}
-	} else {
-		if err := r.db.Pool.QueryRow(ctx, countQuery).Scan(&total); err != nil {
-			return nil, 0, err
-		}
+	if err := r.db.Pool.QueryRow(ctx, countQuery, c.args...).Scan(&total); err != nil {
+		return nil, 0, err
 	}
 
-	rows, err := r.db.Pool.Query(ctx, query, args...)
+	limit, args := c.page(page, perPage)
+	rows, err := r.db.Pool.Query(ctx, payoutListQuery(filter, &c)+" "+limit, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var payouts []models.Payout
+	payouts := []models.Payout{}
 	for rows.Next() {
-		var p models.Payout
-		p.User = &models.User{}
-		if err := rows.Scan(
-			&p.ID, &p.UserID, &p.Amount, &p.Status,
-			&p.ApprovedBy, &p.CreatedAt, &p.PaidAt,
-			&p.User.Name, &p.User.Email, &p.User.Phone, &p.User.ReferralCode,
-			&p.User.BankName, &p.User.AccountNumber, &p.User.AccountName,
-		); err != nil {
+		p, err := scanListedPayout(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		payouts = append(payouts, p)
+		payouts = append(payouts, *p)
 	}
 
 	return payouts, total, nil
 }
 
-func (r *PayoutRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.PayoutStatus, approvedBy uuid.UUID) error {
+// ExportAll calls fn for every payout matching filter, in the order ListAll
+// would return them, streaming rows from a cursor
+func (r *PayoutRepository) ExportAll(ctx context.Context, filter models.ListFilter, fn func(*models.Payout) error) error {
+	c := payoutConditions(filter)
+	return stream(ctx, r.db, payoutListQuery(filter, &c), c.args, func(rows pgx.Rows) error {
+		p, err := scanListedPayout(rows)
+		if err != nil {
+			return err
+		}
+		return fn(p)
+	})
+}
+
+func payoutConditions(filter models.ListFilter) conditions {
+	var c conditions
+	if filter.Status != "" {
+		c.add("p.status = $%d", filter.Status)
+	}
+	if filter.ReferrerID != nil {
+		c.add("p.user_id = $%d", *filter.ReferrerID)
+	}
+	if filter.From != nil {
+		c.add("p.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		c.add("p.created_at < $%d", *filter.To)
+	}
+	if filter.Search != "" {
+		c.add(`(u.name ILIKE $%[1]d OR u.email ILIKE $%[1]d OR u.referral_code ILIKE $%[1]d
+			OR p.transfer_reference ILIKE $%[1]d)`, containsPattern(filter.Search))
+	}
+	return c
+}
+
+// payoutListQuery is the ordered, unpaginated query behind ListAll and
+// ExportAll
+func payoutListQuery(filter models.ListFilter, c *conditions) string {
+	return fmt.Sprintf(`
+		SELECT p.id, p.user_id, p.amount, p.status, p.approved_by, p.transfer_provider, p.transfer_reference, p.transfer_code, p.transfer_status, p.created_at, p.paid_at,
+		       u.name, u.email, u.phone, u.referral_code, u.bank_name, u.account_number, u.account_name
+		FROM payouts p
+		LEFT JOIN users u ON p.user_id = u.id
+		%s
+		%s
+	`, c.where(), PayoutSorts.orderBy(filter.Sort, filter.Order, "created_at", "p.id DESC"))
+}
+
+func scanListedPayout(row pgx.Row) (*models.Payout, error) {
+	var p models.Payout
+	p.User = &models.User{}
+	if err := row.Scan(
+		&p.ID, &p.UserID, &p.Amount, &p.Status,
+		&p.ApprovedBy, &p.TransferProvider, &p.TransferReference, &p.TransferCode, &p.TransferStatus,
+		&p.CreatedAt, &p.PaidAt,
+		&p.User.Name, &p.User.Email, &p.User.Phone, &p.User.ReferralCode,
+		&p.User.BankName, &p.User.AccountNumber, &p.User.AccountName,
+	); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Approve marks a pending payout approved and, in the same transaction, marks
+// exactly the referrals it reserved as paid. The hook runs in the same
+// transaction once the payout row has been updated.
+func (r *PayoutRepository) Approve(ctx context.Context, id uuid.UUID, approvedBy uuid.UUID, hook PayoutHook) error {
+	return r.settle(ctx, id, models.PayoutStatusApproved, approvedBy, hook)
+}
+
+// Reject marks a pending payout rejected and releases its reserved referrals
+// back to the status they had before, so they can be withdrawn again. A
+// payout with a bank transfer may only be rejected once the transfer has
+// failed or been reversed, so money that was sent is never released twice.
+func (r *PayoutRepository) Reject(ctx context.Context, id uuid.UUID, rejectedBy uuid.UUID) error {
+	return r.settle(ctx, id, models.PayoutStatusRejected, rejectedBy, nil)
+}
+
+func (r *PayoutRepository) settle(ctx context.Context, id uuid.UUID, status models.PayoutStatus, actor uuid.UUID, hook PayoutHook) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setActor(ctx, tx, actor); err != nil {
+		return err
+	}
+
 	query := `
-		UPDATE payouts 
+		UPDATE payouts
 		SET status = $2, approved_by = $3, paid_at = CASE WHEN $2 = 'approved' THEN NOW() ELSE paid_at END
+		WHERE id = $1 AND status = 'pending'
+			AND ($2 = 'approved' OR transfer_reference IS NULL OR transfer_status IN ('failed', 'reversed'))
+		RETURNING id, user_id, amount, status, approved_by, transfer_provider, transfer_reference, transfer_code, transfer_status, created_at, paid_at
+	`
+	payout := &models.Payout{}
+	err = tx.QueryRow(ctx, query, id, status, actor).Scan(
+		&payout.ID, &payout.UserID, &payout.Amount, &payout.Status,
+		&payout.ApprovedBy, &payout.TransferProvider, &payout.TransferReference, &payout.TransferCode, &payout.TransferStatus,
+		&payout.CreatedAt, &payout.PaidAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var current models.PayoutStatus
+		err := tx.QueryRow(ctx, `SELECT status FROM payouts WHERE id = $1`, id).Scan(&current)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPayoutNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if current == models.PayoutStatusPending {
+			return ErrPayoutHasTransfer
+		}
+		return ErrPayoutNotPending
+	}
+	if err != nil {
+		return err
+	}
+
+	referralQuery := `
+		UPDATE referrals r
+		SET status = CASE WHEN $2 = 'approved' THEN 'paid' ELSE pi.referral_status END
+		FROM payout_items pi
+		WHERE pi.payout_id = $1 AND pi.referral_id = r.id AND r.status = 'processing'
+	`
+	if _, err := tx.Exec(ctx, referralQuery, id, status); err != nil {
+		return err
+	}
+
+	if hook != nil {
+		if err := hook(ctx, tx, payout); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RecordTransferTx stores the transfer initiated for a payout and the
+// provider it was sent with
+func (r *PayoutRepository) RecordTransferTx(ctx context.Context, tx pgx.Tx, payout *models.Payout) error {
+	query := `
+		UPDATE payouts
+		SET transfer_provider = $2, transfer_reference = $3, transfer_code = $4, transfer_status = $5
 		WHERE id = $1
 	`
+	_, err := tx.Exec(ctx, query, payout.ID, payout.TransferProvider, payout.TransferReference, payout.TransferCode, payout.TransferStatus)
+	return err
+}
+
+// GetByIDTx locks and returns the payout with id. Waiting for the lock
+// means changes made by a transaction still in flight, such as an approval
+// recording its transfer, are seen once it commits.
+func (r *PayoutRepository) GetByIDTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*models.Payout, error) {
+	query := `
+		SELECT id, user_id, amount, status, approved_by, transfer_provider, transfer_reference, transfer_code, transfer_status, created_at, paid_at
+		FROM payouts WHERE id = $1
+		FOR UPDATE
+	`
+
+	payout := &models.Payout{}
+	err := tx.QueryRow(ctx, query, id).Scan(
+		&payout.ID, &payout.UserID, &payout.Amount, &payout.Status,
+		&payout.ApprovedBy, &payout.TransferProvider, &payout.TransferReference, &payout.TransferCode, &payout.TransferStatus,
+		&payout.CreatedAt, &payout.PaidAt,
+	)
 
-	result, err := r.db.Pool.Exec(ctx, query, id, status, approvedBy)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPayoutNotFound
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// UpdateTransfer locks the payout with id and passes it to fn, which may
+// record its transfer or unwind it, in one transaction
+func (r *PayoutRepository) UpdateTransfer(ctx context.Context, id uuid.UUID, fn PayoutHook) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	payout, err := r.GetByIDTx(ctx, tx, id)
 	if err != nil {
 		return err
 	}
 
+	if err := fn(ctx, tx, payout); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UnwindTx moves an approved payout whose transfer did not complete to status
+// (failed or reversed) and restores its referrals to the status they had
+// before the payout, so they can be withdrawn again.
+func (r *PayoutRepository) UnwindTx(ctx context.Context, tx pgx.Tx, payout *models.Payout, status models.PayoutStatus) error {
+	query := `
+		UPDATE payouts SET status = $2, transfer_status = $3, transfer_code = COALESCE($4, transfer_code), paid_at = NULL
+		WHERE id = $1 AND status = 'approved'
+	`
+	result, err := tx.Exec(ctx, query, payout.ID, status, payout.TransferStatus, payout.TransferCode)
+	if err != nil {
+		return err
+	}
 	if result.RowsAffected() == 0 {
-		return ErrPayoutNotFound
+		return ErrPayoutNotApproved
+	}
+
+	referralQuery := `
+		UPDATE referrals r
+		SET status = pi.referral_status
+		FROM payout_items pi
+		WHERE pi.payout_id = $1 AND pi.referral_id = r.id AND r.status = 'paid'
+	`
+	if _, err := tx.Exec(ctx, referralQuery, payout.ID); err != nil {
+		return err
 	}
 
+	payout.Status = status
+	payout.PaidAt = nil
 	return nil
 }
 
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"encoding/json"
 	"time"
@@ -31,21 +32,42 @@ func NewReferralRepository(db *database.DB, cache *cache.Cache) *ReferralReposit
 }
 
 func (r *ReferralRepository) Create(ctx context.Context, referral *models.Referral) error {
+	return r.CreateWithHook(ctx, referral, nil)
+}
+
+// CreateWithHook inserts a referral and runs hook in the same transaction
+func (r *ReferralRepository) CreateWithHook(ctx context.Context, referral *models.Referral, hook TxHook) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO referrals (id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO referrals (id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, commission_rule_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING created_at
 	`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		referral.ID, referral.ReferrerID, referral.ReferredName, referral.ReferredEmail,
 		referral.ReferredPhone, referral.Course, referral.CoursePrice, referral.Earnings, referral.Status,
+		referral.CommissionRuleID,
 	).Scan(&referral.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := runHook(ctx, tx, hook); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *ReferralRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Referral, error) {
 	query := `
-		SELECT id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, created_at
+		SELECT id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, commission_rule_id, created_at
 		FROM referrals WHERE id = $1
 	`
 
@@ -53,7 +75,7 @@ func (r *ReferralRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&referral.ID, &referral.ReferrerID, &referral.ReferredName, &referral.ReferredEmail,
 		&referral.ReferredPhone, &referral.Course, &referral.CoursePrice, &referral.Earnings,
-		&referral.Status, &referral.CreatedAt,
+		&referral.Status, &referral.CommissionRuleID, &referral.CreatedAt,
 	)
 
 	if err != nil {
@@ -76,7 +98,7 @@ func (r *ReferralRepository) ListByReferrer(ctx context.Context, referrerID uuid
 	}
 
 	query := `
-		SELECT id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, created_at
+		SELECT id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, commission_rule_id, created_at
 		FROM referrals WHERE referrer_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
@@ -94,7 +116,7 @@ func (r *ReferralRepository) ListByReferrer(ctx context.Context, referrerID uuid
 		if err := rows.Scan(
 			&ref.ID, &ref.ReferrerID, &ref.ReferredName, &ref.ReferredEmail,
 			&ref.ReferredPhone, &ref.Course, &ref.CoursePrice, &ref.Earnings,
-			&ref.Status, &ref.CreatedAt,
+			&ref.Status, &ref.CommissionRuleID, &ref.CreatedAt,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -104,62 +126,158 @@ func (r *ReferralRepository) ListByReferrer(ctx context.Context, referrerID uuid
 	return referrals, total, nil
 }
 
-func (r *ReferralRepository) ListAll(ctx context.Context, page, perPage int) ([]models.Referral, int64, error) {
-	offset := (page - 1) * perPage
+// ReferralSorts are the sort keys ListAll accepts
+var ReferralSorts = SortColumns{
+	"created_at":    "r.created_at",
+	"earnings":      "r.earnings",
+	"course_price":  "r.course_price",
+	"course":        "r.course",
+	"status":        "r.status",
+	"referred_name": "r.referred_name",
+}
 
-	countQuery := `SELECT COUNT(*) FROM referrals`
+// ListAll returns referrals matching filter, newest first unless filter
+// says otherwise. Search matches the student's name, email or phone and the
+// referrer's name or code.
+func (r *ReferralRepository) ListAll(ctx context.Context, filter models.ListFilter, page, perPage int) ([]models.Referral, int64, error) {
+	c := referralConditions(filter)
+
+	countQuery := `SELECT COUNT(*) FROM referrals r LEFT JOIN users u ON r.referrer_id = u.id ` + c.where()
 	var total int64
-	if err := r.db.Pool.QueryRow(ctx, countQuery).Scan(&total); err != nil {
+	if err := r.db.Pool.QueryRow(ctx, countQuery, c.args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	query := `
-		SELECT 
-			r.id, r.referrer_id, COALESCE(u.name, '-') as referrer_name, 
-			r.referred_name, r.referred_email, r.referred_phone, r.course, r.course_price, r.earnings, r.status, r.created_at,
-			COALESCE(u.bank_name, ''), COALESCE(u.account_number, ''), COALESCE(u.account_name, ''), COALESCE(u.referral_code, '')
-		FROM referrals r
-		LEFT JOIN users u ON r.referrer_id = u.id
-		ORDER BY r.created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+	limit, args := c.page(page, perPage)
+	query := referralListQuery(filter, &c) + " " + limit
 
-	rows, err := r.db.Pool.Query(ctx, query, perPage, offset)
+	rows, err := r.db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var referrals []models.Referral
+	referrals := []models.Referral{}
 	for rows.Next() {
-		var ref models.Referral
-		if err := rows.Scan(
-			&ref.ID, &ref.ReferrerID, &ref.ReferrerName, &ref.ReferredName, &ref.ReferredEmail,
-			&ref.ReferredPhone, &ref.Course, &ref.CoursePrice, &ref.Earnings,
-			&ref.Status, &ref.CreatedAt,
-			&ref.ReferrerBank, &ref.ReferrerAccNo, &ref.ReferrerAccName, &ref.ReferralCode,
-		); err != nil {
+		ref, err := scanListedReferral(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		referrals = append(referrals, ref)
+		referrals = append(referrals, *ref)
 	}
 
 	return referrals, total, nil
 }
 
-func (r *ReferralRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
-	query := `UPDATE referrals SET status = $2 WHERE id = $1`
+// ExportAll calls fn for every referral matching filter, in the order
+// ListAll would return them, streaming rows from a cursor
+func (r *ReferralRepository) ExportAll(ctx context.Context, filter models.ListFilter, fn func(*models.Referral) error) error {
+	c := referralConditions(filter)
+	return stream(ctx, r.db, referralListQuery(filter, &c), c.args, func(rows pgx.Rows) error {
+		ref, err := scanListedReferral(rows)
+		if err != nil {
+			return err
+		}
+		return fn(ref)
+	})
+}
 
-	result, err := r.db.Pool.Exec(ctx, query, id, status)
+func referralConditions(filter models.ListFilter) conditions {
+	var c conditions
+	if filter.Status != "" {
+		c.add("r.status = $%d", filter.Status)
+	}
+	if filter.Course != "" {
+		c.add("LOWER(r.course) = LOWER($%d)", filter.Course)
+	}
+	if filter.ReferrerID != nil {
+		c.add("r.referrer_id = $%d", *filter.ReferrerID)
+	}
+	if filter.From != nil {
+		c.add("r.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		c.add("r.created_at < $%d", *filter.To)
+	}
+	if filter.Search != "" {
+		c.add(`(r.referred_name ILIKE $%[1]d OR r.referred_email ILIKE $%[1]d OR r.referred_phone ILIKE $%[1]d
+			OR u.name ILIKE $%[1]d OR u.referral_code ILIKE $%[1]d)`, containsPattern(filter.Search))
+	}
+	return c
+}
+
+// referralListQuery is the ordered, unpaginated query behind ListAll and
+// ExportAll
+func referralListQuery(filter models.ListFilter, c *conditions) string {
+	return fmt.Sprintf(`
+		SELECT 
+			r.id, r.referrer_id, COALESCE(u.name, '-') as referrer_name, 
+			r.referred_name, r.referred_email, r.referred_phone, r.course, r.course_price, r.earnings, r.status, r.commission_rule_id, r.created_at,
+			COALESCE(u.bank_name, ''), COALESCE(u.account_number, ''), COALESCE(u.account_name, ''), COALESCE(u.referral_code, '')
+		FROM referrals r
+		LEFT JOIN users u ON r.referrer_id = u.id
+		%s
+		%s
+	`, c.where(), ReferralSorts.orderBy(filter.Sort, filter.Order, "created_at", "r.id DESC"))
+}
+
+func scanListedReferral(row pgx.Row) (*models.Referral, error) {
+	var ref models.Referral
+	if err := row.Scan(
+		&ref.ID, &ref.ReferrerID, &ref.ReferrerName, &ref.ReferredName, &ref.ReferredEmail,
+		&ref.ReferredPhone, &ref.Course, &ref.CoursePrice, &ref.Earnings,
+		&ref.Status, &ref.CommissionRuleID, &ref.CreatedAt,
+		&ref.ReferrerBank, &ref.ReferrerAccNo, &ref.ReferrerAccName, &ref.ReferralCode,
+	); err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+// UpdateStatus locks the referral, passes its current state to hook and then
+// writes the new status, all in one transaction. The hook is where callers
+// validate the transition; actor is recorded in the status history.
+func (r *ReferralRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string, actor uuid.UUID, hook ReferralHook) error {
+	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	if result.RowsAffected() == 0 {
-		return ErrReferralNotFound
+	if err := setActor(ctx, tx, actor); err != nil {
+		return err
 	}
 
-	return nil
+	query := `
+		SELECT id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, commission_rule_id, created_at
+		FROM referrals WHERE id = $1
+		FOR UPDATE
+	`
+
+	referral := &models.Referral{}
+	err = tx.QueryRow(ctx, query, id).Scan(
+		&referral.ID, &referral.ReferrerID, &referral.ReferredName, &referral.ReferredEmail,
+		&referral.ReferredPhone, &referral.Course, &referral.CoursePrice, &referral.Earnings,
+		&referral.Status, &referral.CommissionRuleID, &referral.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReferralNotFound
+		}
+		return err
+	}
+
+	if hook != nil {
+		if err := hook(ctx, tx, referral); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE referrals SET status = $2 WHERE id = $1`, id, status); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *ReferralRepository) GetStatsByReferrer(ctx context.Context, referrerID uuid.UUID) (totalCount int, totalEarnings int64, pendingEarnings int64, err error) {
@@ -167,7 +285,7 @@ func (r *ReferralRepository) GetStatsByReferrer(ctx context.Context, referrerID
 		SELECT 
 			COUNT(*),
 			COALESCE(SUM(CASE WHEN status = 'paid' THEN earnings ELSE 0 END), 0),
-			COALESCE(SUM(CASE WHEN status = 'pending' THEN earnings ELSE 0 END), 0)
+			COALESCE(SUM(CASE WHEN status IN ('pending', 'approved') THEN earnings ELSE 0 END), 0)
 		FROM referrals WHERE referrer_id = $1
 	`
 	err = r.db.Pool.QueryRow(ctx, query, referrerID).Scan(&totalCount, &totalEarnings, &pendingEarnings)
@@ -193,7 +311,7 @@ func (r *ReferralRepository) GetTotalStats(ctx context.Context) (totalReferrals
 		SELECT 
 			COUNT(CASE WHEN r.referrer_id IS NOT NULL THEN r.id END), 
 			COALESCE(SUM(r.earnings), 0),
-			COALESCE(SUM(CASE WHEN r.status = 'pending' THEN r.earnings ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.status IN ('pending', 'approved') THEN r.earnings ELSE 0 END), 0),
 			COALESCE(SUM(CASE WHEN r.status = 'paid' THEN r.earnings ELSE 0 END), 0),
 			COUNT(CASE WHEN r.status = 'paid' THEN r.id END)
 		FROM referrals r
@@ -257,75 +375,212 @@ func (r *ReferralRepository) GetTotalStats(ctx context.Context) (totalReferrals
 	return
 }
 
-func (r *ReferralRepository) GetReferrersStats(ctx context.Context, page, perPage int) ([]models.ReferrerStats, int64, error) {
-	offset := (page - 1) * perPage
+// ReferrerSorts are the sort keys GetReferrersStats accepts
+var ReferrerSorts = SortColumns{
+	"total_earnings": "total_earnings",
+	"total_usage":    "total_usage",
+	"name":           "u.name",
+	"joined_at":      "u.created_at",
+}
+
+// GetReferrersStats returns referrers with their referral count and earnings,
+// highest earning first unless filter says otherwise. Course and the date
+// range limit which referrals are counted; status is active (has referrals)
+// or inactive. Search matches the referrer's name, email or code.
+func (r *ReferralRepository) GetReferrersStats(ctx context.Context, filter models.ListFilter, page, perPage int) ([]models.ReferrerStats, int64, error) {
+	grouped, c := referrersQuery(filter)
 
-	// Count users who have a referral code
-	countQuery := `SELECT COUNT(*) FROM users WHERE referral_code IS NOT NULL AND referral_code != '' AND role != 'admin'`
 	var total int64
-	if err := r.db.Pool.QueryRow(ctx, countQuery).Scan(&total); err != nil {
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM (`+grouped+`) referrers`, c.args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	// Query to aggregate stats per referrer
-	query := `
+	limit, args := c.page(page, perPage)
+	query := fmt.Sprintf("%s %s %s", grouped, referrersOrderBy(filter), limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	stats := []models.ReferrerStats{}
+	for rows.Next() {
+		s, err := scanReferrerStats(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		stats = append(stats, *s)
+	}
+
+	return stats, total, nil
+}
+
+// ExportReferrersStats calls fn for every referrer matching filter, in the
+// order GetReferrersStats would return them, streaming rows from a cursor
+func (r *ReferralRepository) ExportReferrersStats(ctx context.Context, filter models.ListFilter, fn func(*models.ReferrerStats) error) error {
+	grouped, c := referrersQuery(filter)
+	return stream(ctx, r.db, grouped+" "+referrersOrderBy(filter), c.args, func(rows pgx.Rows) error {
+		s, err := scanReferrerStats(rows)
+		if err != nil {
+			return err
+		}
+		return fn(s)
+	})
+}
+
+// referrersQuery builds the grouped, unordered query behind
+// GetReferrersStats and ExportReferrersStats
+func referrersQuery(filter models.ListFilter) (string, conditions) {
+	var c conditions
+	if filter.Course != "" {
+		c.add("LOWER(r.course) = LOWER($%d)", filter.Course)
+	}
+	if filter.From != nil {
+		c.add("r.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		c.add("r.created_at < $%d", *filter.To)
+	}
+	joinOn := c.take("AND")
+
+	if filter.ReferrerID != nil {
+		c.add("u.id = $%d", *filter.ReferrerID)
+	}
+	if filter.Search != "" {
+		c.add("(u.name ILIKE $%[1]d OR u.email ILIKE $%[1]d OR u.referral_code ILIKE $%[1]d)", containsPattern(filter.Search))
+	}
+	where := c.join("AND")
+
+	having := ""
+	switch filter.Status {
+	case "active":
+		having = "HAVING COUNT(r.id) > 0"
+	case "inactive":
+		having = "HAVING COUNT(r.id) = 0"
+	}
+
+	// Aggregate stats per user who has a referral code
+	grouped := fmt.Sprintf(`
 		SELECT 
 			u.id, 
 			u.name, 
 			u.referral_code, 
 			COUNT(r.id) as total_usage, 
 			COALESCE(SUM(r.earnings), 0) as total_earnings,
-			u.is_blocked
+			u.is_blocked,
+			COALESCE(u.bank_name, ''), COALESCE(u.account_number, ''), COALESCE(u.account_name, '')
 		FROM users u
-		LEFT JOIN referrals r ON u.id = r.referrer_id
-		WHERE u.referral_code IS NOT NULL AND u.referral_code != '' AND u.role != 'admin'
-		GROUP BY u.id, u.name, u.referral_code, u.is_blocked
-		ORDER BY total_earnings DESC, total_usage DESC
-		LIMIT $1 OFFSET $2
-	`
+		LEFT JOIN referrals r ON u.id = r.referrer_id %s
+		WHERE u.referral_code IS NOT NULL AND u.referral_code != '' AND u.role != 'admin' %s
+		GROUP BY u.id, u.name, u.referral_code, u.is_blocked, u.bank_name, u.account_number, u.account_name
+		%s
+	`, joinOn, where, having)
+
+	return grouped, c
+}
+
+func referrersOrderBy(filter models.ListFilter) string {
+	return ReferrerSorts.orderBy(filter.Sort, filter.Order, "total_earnings", "total_usage DESC, u.id")
+}
+
+func scanReferrerStats(row pgx.Row) (*models.ReferrerStats, error) {
+	var s models.ReferrerStats
+	if err := row.Scan(
+		&s.ReferrerID, &s.ReferrerName, &s.ReferralCode,
+		&s.TotalUsage, &s.TotalEarnings, &s.IsBlocked,
+		&s.BankName, &s.AccountNumber, &s.AccountName,
+	); err != nil {
+		return nil, err
+	}
+
+	// Derive status based on usage
+	if s.TotalUsage > 0 {
+		s.Status = "Active"
+	} else {
+		s.Status = "Inactive"
+	}
+
+	return &s, nil
+}
 
-	rows, err := r.db.Pool.Query(ctx, query, perPage, offset)
+// MarkReferralsAsPaid marks all of a referrer's pending and approved referrals
+// as paid and passes the updated referrals to hook in the same transaction.
+func (r *ReferralRepository) MarkReferralsAsPaid(ctx context.Context, referrerID uuid.UUID, actor uuid.UUID, hook ReferralsHook) error {
+	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
-		return nil, 0, err
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setActor(ctx, tx, actor); err != nil {
+		return err
 	}
-	defer rows.Close()
 
-	var stats []models.ReferrerStats
+	query := `
+		UPDATE referrals 
+		SET status = 'paid' 
+		WHERE referrer_id = $1 AND status IN ('pending', 'approved')
+		RETURNING id, referrer_id, referred_name, referred_email, referred_phone, course, course_price, earnings, status, commission_rule_id, created_at
+	`
+	rows, err := tx.Query(ctx, query, referrerID)
+	if err != nil {
+		return err
+	}
+
+	var referrals []models.Referral
 	for rows.Next() {
-		var s models.ReferrerStats
+		var ref models.Referral
 		if err := rows.Scan(
-			&s.ReferrerID, &s.ReferrerName, &s.ReferralCode,
-			&s.TotalUsage, &s.TotalEarnings, &s.IsBlocked,
+			&ref.ID, &ref.ReferrerID, &ref.ReferredName, &ref.ReferredEmail,
+			&ref.ReferredPhone, &ref.Course, &ref.CoursePrice, &ref.Earnings,
+			&ref.Status, &ref.CommissionRuleID, &ref.CreatedAt,
 		); err != nil {
-			return nil, 0, err
+			rows.Close()
+			return err
 		}
+		referrals = append(referrals, ref)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-		// Derive status based on usage
-		if s.TotalUsage > 0 {
-			s.Status = "Active"
-		} else {
-			s.Status = "Inactive"
+	if hook != nil {
+		if err := hook(ctx, tx, referrals); err != nil {
+			return err
 		}
-
-		stats = append(stats, s)
 	}
 
-	return stats, total, nil
+	return tx.Commit(ctx)
 }
 
-func (r *ReferralRepository) MarkReferralsAsPaid(ctx context.Context, referrerID uuid.UUID) error {
+// ListStatusHistory returns a referral's status changes, oldest first
+func (r *ReferralRepository) ListStatusHistory(ctx context.Context, id uuid.UUID) ([]models.ReferralStatusChange, error) {
 	query := `
-		UPDATE referrals 
-		SET status = 'paid' 
-		WHERE referrer_id = $1 AND status = 'pending'
+		SELECT h.id, h.referral_id, h.from_status, h.to_status, h.changed_by, u.name, h.created_at
+		FROM referral_status_history h
+		LEFT JOIN users u ON h.changed_by = u.id
+		WHERE h.referral_id = $1
+		ORDER BY h.created_at ASC
 	`
-	_, err := r.db.Pool.Exec(ctx, query, referrerID)
-	return err
-}
 
-// MarkReferralAsPaid marks a single referral as paid
-func (r *ReferralRepository) MarkReferralAsPaid(ctx context.Context, id uuid.UUID) error {
-	return r.UpdateStatus(ctx, id, "paid")
+	rows, err := r.db.Pool.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.ReferralStatusChange{}
+	for rows.Next() {
+		var c models.ReferralStatusChange
+		if err := rows.Scan(&c.ID, &c.ReferralID, &c.FromStatus, &c.ToStatus, &c.ChangedBy, &c.ChangedByName, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+
+	return history, rows.Err()
 }
 
 // InvalidateDashboardCache clears the cached dashboard stats
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRevoked  = errors.New("refresh token has been revoked")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+)
+
+type RefreshToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	FamilyID   uuid.UUID
+	ExpiresAt  time.Time
+	UsedAt     *time.Time
+	ReplacedBy *uuid.UUID
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+type RefreshTokenRepository struct {
+	db *database.DB
+}
+
+func NewRefreshTokenRepository(db *database.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create records a newly issued refresh token
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+	return r.db.Pool.QueryRow(ctx, query, token.ID, token.UserID, token.FamilyID, token.ExpiresAt).Scan(&token.CreatedAt)
+}
+
+// Rotate marks the token oldID as used and records next as its replacement in
+// the same family. A token that was already used has been replayed, so the
+// whole family is revoked and ErrRefreshTokenReused is returned.
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, oldID, userID uuid.UUID, next *RefreshToken) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current RefreshToken
+	err = tx.QueryRow(ctx, `
+		SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE id = $1
+		FOR UPDATE
+	`, oldID).Scan(&current.ID, &current.UserID, &current.FamilyID, &current.ExpiresAt, &current.UsedAt, &current.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRefreshTokenNotFound
+		}
+		return err
+	}
+
+	if current.UserID != userID {
+		return ErrRefreshTokenNotFound
+	}
+	if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return ErrRefreshTokenRevoked
+	}
+
+	if current.UsedAt != nil {
+		if _, err := tx.Exec(ctx, `
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, current.FamilyID); err != nil {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET used_at = NOW(), replaced_by = $2 WHERE id = $1
+	`, current.ID, next.ID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, next.ID, next.UserID, next.FamilyID, next.ExpiresAt).Scan(&next.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RevokeFamily revokes the login session the token id belongs to
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, id, userID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE id = $1 AND user_id = $2)
+		  AND revoked_at IS NULL
+	`
+	_, err := r.db.Pool.Exec(ctx, query, id, userID)
+	return err
+}
+
+// RevokeAllForUser revokes every outstanding refresh token of a user
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+// CleanupExpired removes tokens that can no longer be used
+func (r *RefreshTokenRepository) CleanupExpired(ctx context.Context) error {
+	_, err := r.db.Pool.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at < NOW()")
+	return err
+}
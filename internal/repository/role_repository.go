@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+)
+
+type RoleRepository struct {
+	db *database.DB
+}
+
+func NewRoleRepository(db *database.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// List returns every admin role with its permissions
+func (r *RoleRepository) List(ctx context.Context) ([]models.AdminRoleInfo, error) {
+	query := `
+		SELECT ar.name, ar.description,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM admin_roles ar
+		LEFT JOIN admin_role_permissions rp ON rp.role = ar.name
+		GROUP BY ar.name, ar.description
+		ORDER BY ar.name
+	`
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.AdminRoleInfo{}
+	for rows.Next() {
+		var role models.AdminRoleInfo
+		var permissions []string
+		if err := rows.Scan(&role.Name, &role.Description, &permissions); err != nil {
+			return nil, err
+		}
+		role.Permissions = []models.Permission{}
+		for _, p := range permissions {
+			role.Permissions = append(role.Permissions, models.Permission(p))
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// Exists reports whether an admin role is defined
+func (r *RoleRepository) Exists(ctx context.Context, role models.AdminRole) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM admin_roles WHERE name = $1)`, role).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TxHook runs inside a repository transaction so that related writes (such as
+// ledger postings) commit or roll back together with the primary change.
+// Returning an error aborts the transaction.
+type TxHook func(ctx context.Context, tx pgx.Tx) error
+
+// ReferralHook receives a referral locked for update, before its new status
+// is written. Referral.Status still holds the previous status.
+type ReferralHook func(ctx context.Context, tx pgx.Tx, referral *models.Referral) error
+
+// ReferralsHook receives every referral touched by a bulk update
+type ReferralsHook func(ctx context.Context, tx pgx.Tx, referrals []models.Referral) error
+
+// PayoutHook receives a payout after its status change has been written
+type PayoutHook func(ctx context.Context, tx pgx.Tx, payout *models.Payout) error
+
+func runHook(ctx context.Context, tx pgx.Tx, hook TxHook) error {
+	if hook == nil {
+		return nil
+	}
+	return hook(ctx, tx)
+}
+
+// setActor records who is making the changes in tx, for triggers that keep
+// history (see referral_status_history). uuid.Nil means the system.
+func setActor(ctx context.Context, tx pgx.Tx, actor uuid.UUID) error {
+	value := ""
+	if actor != uuid.Nil {
+		value = actor.String()
+	}
+	_, err := tx.Exec(ctx, `SELECT set_config('app.actor_id', $1, true)`, value)
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/cirvee/referral-backend/internal/database"
 	"github.com/cirvee/referral-backend/internal/models"
@@ -14,6 +15,7 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserExists         = errors.New("user already exists")
 	ErrReferralCodeExists = errors.New("referral code already exists")
+	ErrLastSuperAdmin     = errors.New("cannot remove the last active super admin")
 )
 
 type UserRepository struct {
@@ -26,14 +28,14 @@ func NewUserRepository(db *database.DB) *UserRepository {
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, email, password_hash, name, phone, role, bank_name, account_number, account_name, referral_code, is_blocked)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO users (id, email, password_hash, name, phone, role, admin_role, bank_name, account_number, account_name, referral_code, is_blocked, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING created_at, updated_at
 	`
 
 	err := r.db.Pool.QueryRow(ctx, query,
-		user.ID, user.Email, user.PasswordHash, user.Name, user.Phone, user.Role,
-		user.BankName, user.AccountNumber, user.AccountName, user.ReferralCode, user.IsBlocked,
+		user.ID, user.Email, user.PasswordHash, user.Name, user.Phone, user.Role, user.AdminRole,
+		user.BankName, user.AccountNumber, user.AccountName, user.ReferralCode, user.IsBlocked, user.EmailVerifiedAt,
 	).Scan(&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
@@ -48,15 +50,40 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, phone, role, bank_name, account_number, account_name, referral_code, is_blocked, created_at, updated_at
+		SELECT id, email, password_hash, name, phone, role, admin_role, bank_code, bank_name, account_number, account_name, bank_verified_at, bank_name_mismatch, bank_hold_until, referral_code, is_blocked, email_verified_at, created_at, updated_at
 		FROM users WHERE id = $1
 	`
 
 	user := &models.User{}
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role,
-		&user.BankName, &user.AccountNumber, &user.AccountName, &user.ReferralCode, &user.IsBlocked,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role, &user.AdminRole,
+		&user.BankCode, &user.BankName, &user.AccountNumber, &user.AccountName, &user.BankVerifiedAt, &user.BankNameMismatch, &user.BankHoldUntil, &user.ReferralCode, &user.IsBlocked,
+		&user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetBankDetailsTx locks the user's row and returns their bank details and
+// bank hold. It takes the same lock as BankChangeRepository.Confirm, so no
+// bank change can be confirmed until tx ends.
+func (r *UserRepository) GetBankDetailsTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*models.User, error) {
+	query := `
+		SELECT id, bank_code, bank_name, account_number, account_name, bank_hold_until
+		FROM users WHERE id = $1
+		FOR UPDATE
+	`
+
+	user := &models.User{}
+	err := tx.QueryRow(ctx, query, id).Scan(
+		&user.ID, &user.BankCode, &user.BankName, &user.AccountNumber, &user.AccountName, &user.BankHoldUntil,
 	)
 
 	if err != nil {
@@ -71,15 +98,15 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, phone, role, bank_name, account_number, account_name, referral_code, is_blocked, created_at, updated_at
+		SELECT id, email, password_hash, name, phone, role, admin_role, bank_code, bank_name, account_number, account_name, bank_verified_at, bank_name_mismatch, bank_hold_until, referral_code, is_blocked, email_verified_at, created_at, updated_at
 		FROM users WHERE email = $1
 	`
 
 	user := &models.User{}
 	err := r.db.Pool.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role,
-		&user.BankName, &user.AccountNumber, &user.AccountName, &user.ReferralCode, &user.IsBlocked,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role, &user.AdminRole,
+		&user.BankCode, &user.BankName, &user.AccountNumber, &user.AccountName, &user.BankVerifiedAt, &user.BankNameMismatch, &user.BankHoldUntil, &user.ReferralCode, &user.IsBlocked,
+		&user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -95,13 +122,20 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users SET 
-			name = $2, phone = $3, bank_name = $4, account_number = $5, account_name = $6, updated_at = NOW()
+			name = $2, phone = $3, bank_name = $4, account_number = $5, account_name = $6,
+			bank_code = $7, bank_verified_at = $8, bank_name_mismatch = $9, updated_at = NOW(),
+			payout_recipient_code = CASE
+				WHEN bank_name IS DISTINCT FROM $4 OR account_number IS DISTINCT FROM $5 OR account_name IS DISTINCT FROM $6
+					OR bank_code IS DISTINCT FROM $7 THEN NULL
+				ELSE payout_recipient_code
+			END
 		WHERE id = $1
 		RETURNING updated_at
 	`
 
 	err := r.db.Pool.QueryRow(ctx, query,
 		user.ID, user.Name, user.Phone, user.BankName, user.AccountNumber, user.AccountName,
+		user.BankCode, user.BankVerifiedAt, user.BankNameMismatch,
 	).Scan(&user.UpdatedAt)
 
 	if err != nil {
@@ -114,6 +148,107 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	return nil
 }
 
+// UpdateProfile saves the fields a user may change directly: name, phone and
+// the bank name mismatch flag, which depends on the name. Bank details only
+// change through a confirmed BankDetailChange.
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users SET name = $2, phone = $3, bank_name_mismatch = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query, user.ID, user.Name, user.Phone, user.BankNameMismatch).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetAccessState returns whether a user is blocked or verified, their
+// current token version and, for admins, the permissions of their role
+func (r *UserRepository) GetAccessState(ctx context.Context, id uuid.UUID) (*models.UserAccessState, error) {
+	query := `
+		SELECT u.is_blocked, u.email_verified_at IS NOT NULL, u.token_version,
+			COALESCE(array_agg(rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM users u
+		LEFT JOIN admin_role_permissions rp ON rp.role = u.admin_role
+		WHERE u.id = $1
+		GROUP BY u.id
+	`
+	state := &models.UserAccessState{}
+	var permissions []string
+	err := r.db.Pool.QueryRow(ctx, query, id).
+		Scan(&state.IsBlocked, &state.EmailVerified, &state.TokenVersion, &permissions)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	for _, p := range permissions {
+		state.Permissions = append(state.Permissions, models.Permission(p))
+	}
+	return state, nil
+}
+
+// IncrementTokenVersion invalidates every access token issued to a user so
+// far and returns the new version
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, id uuid.UUID) (int, error) {
+	var version int
+	err := r.db.Pool.QueryRow(ctx, `
+		UPDATE users SET token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1
+		RETURNING token_version
+	`, id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+// GetPayoutRecipient returns the cached transfer recipient for a user with
+// the given payout provider, or an empty string if none has been created with
+// that provider for the current bank details
+func (r *UserRepository) GetPayoutRecipient(ctx context.Context, id uuid.UUID, provider string) (string, error) {
+	var code, owner *string
+	query := `SELECT payout_recipient_code, payout_recipient_provider FROM users WHERE id = $1`
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(&code, &owner)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+
+	if code == nil || owner == nil || *owner != provider {
+		return "", nil
+	}
+	return *code, nil
+}
+
+func (r *UserRepository) SetPayoutRecipient(ctx context.Context, id uuid.UUID, provider, code string) error {
+	query := `UPDATE users SET payout_recipient_code = $3, payout_recipient_provider = $2 WHERE id = $1`
+
+	result, err := r.db.Pool.Exec(ctx, query, id, provider, code)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
 
@@ -141,7 +276,7 @@ func (r *UserRepository) List(ctx context.Context, role models.Role, page, perPa
 
 	// List query
 	query := `
-		SELECT id, email, password_hash, name, phone, role, bank_name, account_number, account_name, referral_code, is_blocked, created_at, updated_at
+		SELECT id, email, password_hash, name, phone, role, admin_role, bank_code, bank_name, account_number, account_name, bank_verified_at, bank_name_mismatch, bank_hold_until, referral_code, is_blocked, email_verified_at, created_at, updated_at
 		FROM users WHERE role = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
@@ -157,9 +292,9 @@ func (r *UserRepository) List(ctx context.Context, role models.Role, page, perPa
 	for rows.Next() {
 		var user models.User
 		if err := rows.Scan(
-			&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role,
-			&user.BankName, &user.AccountNumber, &user.AccountName, &user.ReferralCode, &user.IsBlocked,
-			&user.CreatedAt, &user.UpdatedAt,
+			&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role, &user.AdminRole,
+			&user.BankCode, &user.BankName, &user.AccountNumber, &user.AccountName, &user.BankVerifiedAt, &user.BankNameMismatch, &user.BankHoldUntil, &user.ReferralCode, &user.IsBlocked,
+			&user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -169,6 +304,62 @@ func (r *UserRepository) List(ctx context.Context, role models.Role, page, perPa
 	return users, total, nil
 }
 
+// Search returns users matching filter for the admin user directory, newest
+// first
+func (r *UserRepository) Search(ctx context.Context, filter models.UserFilter, page, perPage int) ([]models.User, int64, error) {
+	var c conditions
+	if filter.Search != "" {
+		c.add("(name ILIKE $%[1]d OR email ILIKE $%[1]d OR phone ILIKE $%[1]d OR referral_code ILIKE $%[1]d)", containsPattern(filter.Search))
+	}
+	if filter.Role != nil {
+		c.add("role = $%d", *filter.Role)
+	}
+	if filter.IsBlocked != nil {
+		c.add("is_blocked = $%d", *filter.IsBlocked)
+	}
+	if filter.HasBankDetails != nil {
+		c.add("(COALESCE(bank_name, '') <> '' AND COALESCE(account_number, '') <> '') = $%d", *filter.HasBankDetails)
+	}
+	if filter.BankNameMismatch != nil {
+		c.add("bank_name_mismatch = $%d", *filter.BankNameMismatch)
+	}
+
+	var total int64
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM users `+c.where(), c.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, args := c.page(page, perPage)
+	query := fmt.Sprintf(`
+		SELECT id, email, password_hash, name, phone, role, admin_role, bank_code, bank_name, account_number, account_name, bank_verified_at, bank_name_mismatch, bank_hold_until, referral_code, is_blocked, email_verified_at, created_at, updated_at
+		FROM users
+		%s
+		ORDER BY created_at DESC
+		%s
+	`, c.where(), limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role, &user.AdminRole,
+			&user.BankCode, &user.BankName, &user.AccountNumber, &user.AccountName, &user.BankVerifiedAt, &user.BankNameMismatch, &user.BankHoldUntil, &user.ReferralCode, &user.IsBlocked,
+			&user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
 func (r *UserRepository) ListStudents(ctx context.Context, page, perPage int) ([]models.StudentResponse, int64, error) {
 	offset := (page - 1) * perPage
 
@@ -233,15 +424,15 @@ func (r *UserRepository) ExistsByReferralCode(ctx context.Context, code string)
 
 func (r *UserRepository) GetByReferralCode(ctx context.Context, code string) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, phone, role, bank_name, account_number, account_name, referral_code, is_blocked, created_at, updated_at
+		SELECT id, email, password_hash, name, phone, role, admin_role, bank_code, bank_name, account_number, account_name, bank_verified_at, bank_name_mismatch, bank_hold_until, referral_code, is_blocked, email_verified_at, created_at, updated_at
 		FROM users WHERE referral_code = $1
 	`
 
 	user := &models.User{}
 	err := r.db.Pool.QueryRow(ctx, query, code).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role,
-		&user.BankName, &user.AccountNumber, &user.AccountName, &user.ReferralCode, &user.IsBlocked,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Phone, &user.Role, &user.AdminRole,
+		&user.BankCode, &user.BankName, &user.AccountNumber, &user.AccountName, &user.BankVerifiedAt, &user.BankNameMismatch, &user.BankHoldUntil, &user.ReferralCode, &user.IsBlocked,
+		&user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -254,6 +445,20 @@ func (r *UserRepository) GetByReferralCode(ctx context.Context, code string) (*m
 	return user, nil
 }
 
+// MarkEmailVerified records that a user has confirmed their email address.
+// Verifying twice keeps the first timestamp.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() WHERE id = $1`
+	result, err := r.db.Pool.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 // UpdatePassword updates a user's password hash
 func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
 	query := `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`
@@ -267,16 +472,111 @@ func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, p
 	return nil
 }
 
-// UpdateStatus updates a user's blocked status
+// UpdateStatus updates a user's blocked status. Blocking a user also revokes
+// all of their refresh tokens so no session outlives the block.
 func (r *UserRepository) UpdateStatus(ctx context.Context, userID uuid.UUID, isBlocked bool) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if isBlocked {
+		if err := ensureNotLastSuperAdmin(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+
 	query := `UPDATE users SET is_blocked = $2, updated_at = NOW() WHERE id = $1`
-	result, err := r.db.Pool.Exec(ctx, query, userID, isBlocked)
+	result, err := tx.Exec(ctx, query, userID, isBlocked)
 	if err != nil {
 		return err
 	}
 	if result.RowsAffected() == 0 {
 		return ErrUserNotFound
 	}
+
+	if isBlocked {
+		_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UpdateAdmin changes an admin's role and/or blocked status in one
+// transaction; nil leaves a field unchanged. Blocking an admin also revokes
+// all of their refresh tokens.
+func (r *UserRepository) UpdateAdmin(ctx context.Context, userID uuid.UUID, role *models.AdminRole, isBlocked *bool) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	demoted := role != nil && *role != models.AdminRoleSuperAdmin
+	blocked := isBlocked != nil && *isBlocked
+	if demoted || blocked {
+		if err := ensureNotLastSuperAdmin(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE users
+		SET admin_role = COALESCE($2, admin_role), is_blocked = COALESCE($3, is_blocked), updated_at = NOW()
+		WHERE id = $1 AND role = 'admin'
+	`
+	result, err := tx.Exec(ctx, query, userID, role, isBlocked)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if blocked {
+		_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ensureNotLastSuperAdmin returns ErrLastSuperAdmin if userID is the only
+// active super admin. The super admin rows stay locked until tx ends, so two
+// concurrent changes cannot each leave the other as the last one.
+func ensureNotLastSuperAdmin(ctx context.Context, tx pgx.Tx, userID uuid.UUID) error {
+	rows, err := tx.Query(ctx, `
+		SELECT id FROM users
+		WHERE admin_role = 'super_admin' AND NOT is_blocked
+		ORDER BY id
+		FOR UPDATE
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) == 1 && ids[0] == userID {
+		return ErrLastSuperAdmin
+	}
 	return nil
 }
 
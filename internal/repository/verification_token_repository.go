@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type EmailVerificationToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Token     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type VerificationTokenRepository struct {
+	db *database.DB
+}
+
+func NewVerificationTokenRepository(db *database.DB) *VerificationTokenRepository {
+	return &VerificationTokenRepository{db: db}
+}
+
+// Create creates a new email verification token, replacing any earlier one
+func (r *VerificationTokenRepository) Create(ctx context.Context, userID uuid.UUID, expiry time.Duration) (*EmailVerificationToken, error) {
+	// Only the latest link sent to a user works
+	_, _ = r.db.Pool.Exec(ctx, "DELETE FROM email_verification_tokens WHERE user_id = $1", userID)
+
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	verificationToken := &EmailVerificationToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(expiry),
+		CreatedAt: time.Now(),
+	}
+
+	query := `
+		INSERT INTO email_verification_tokens (id, user_id, token, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = r.db.Pool.Exec(ctx, query, verificationToken.ID, verificationToken.UserID, verificationToken.Token, verificationToken.ExpiresAt, verificationToken.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return verificationToken, nil
+}
+
+// GetByToken retrieves a token by its value
+func (r *VerificationTokenRepository) GetByToken(ctx context.Context, token string) (*EmailVerificationToken, error) {
+	query := `
+		SELECT id, user_id, token, expires_at, created_at
+		FROM email_verification_tokens WHERE token = $1
+	`
+	var t EmailVerificationToken
+	err := r.db.Pool.QueryRow(ctx, query, token).Scan(&t.ID, &t.UserID, &t.Token, &t.ExpiresAt, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	if time.Now().After(t.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return &t, nil
+}
+
+// DeleteByUserID deletes all tokens for a user
+func (r *VerificationTokenRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx, "DELETE FROM email_verification_tokens WHERE user_id = $1", userID)
+	return err
+}
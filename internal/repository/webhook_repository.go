@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cirvee/referral-backend/internal/database"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrDuplicateEvent = errors.New("webhook event already processed")
+)
+
+type WebhookRepository struct {
+	db *database.DB
+}
+
+func NewWebhookRepository(db *database.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Record stores an inbound event and runs hook in the same transaction, so an
+// event is either stored and fully applied or not stored at all. Events that
+// were already recorded return ErrDuplicateEvent without running hook.
+func (r *WebhookRepository) Record(ctx context.Context, event *models.WebhookEvent, hook TxHook) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO webhook_events (provider, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, event_id) DO NOTHING
+		RETURNING id, received_at
+	`
+	err = tx.QueryRow(ctx, query, event.Provider, event.EventID, event.EventType, event.Payload).Scan(&event.ID, &event.ReceivedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrDuplicateEvent
+		}
+		return err
+	}
+
+	if err := runHook(ctx, tx, hook); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
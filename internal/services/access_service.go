@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/cache"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// accessStateTTL bounds how stale a cached access state can be if an
+// invalidation is ever missed
+const accessStateTTL = 5 * time.Minute
+
+// AccessService answers, on every authenticated request, whether a user's
+// access token is still good. State is cached in Redis and read from
+// Postgres when the cache is missing or unavailable.
+type AccessService struct {
+	userRepo *repository.UserRepository
+	cache    *cache.Cache
+}
+
+func NewAccessService(userRepo *repository.UserRepository, cache *cache.Cache) *AccessService {
+	return &AccessService{
+		userRepo: userRepo,
+		cache:    cache,
+	}
+}
+
+// CheckAccess returns ErrUserBlocked if the user is blocked and
+// ErrTokenRevoked if the token was issued before the user's token version was
+// last bumped or the user no longer exists
+func (s *AccessService) CheckAccess(ctx context.Context, userID uuid.UUID, tokenVersion int) error {
+	state, err := s.state(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+
+	if state.IsBlocked {
+		return ErrUserBlocked
+	}
+	if tokenVersion < state.TokenVersion {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// EmailVerified reports whether a user has confirmed their email address
+func (s *AccessService) EmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	state, err := s.state(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return state.EmailVerified, nil
+}
+
+// HasPermission reports whether a user's admin role grants permission
+func (s *AccessService) HasPermission(ctx context.Context, userID uuid.UUID, permission models.Permission) (bool, error) {
+	state, err := s.state(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range state.Permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MarkEmailVerified records a confirmed email address, effective on the
+// user's next request
+func (s *AccessService) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	if err := s.userRepo.MarkEmailVerified(ctx, userID); err != nil {
+		return err
+	}
+	s.invalidate(ctx, userID)
+	return nil
+}
+
+// SetBlocked blocks or unblocks a user, effective on their next request
+func (s *AccessService) SetBlocked(ctx context.Context, userID uuid.UUID, blocked bool) error {
+	if err := s.userRepo.UpdateStatus(ctx, userID, blocked); err != nil {
+		return err
+	}
+	s.invalidate(ctx, userID)
+	return nil
+}
+
+// UpdateAdmin changes an admin's role and/or blocked status together,
+// effective on their next request
+func (s *AccessService) UpdateAdmin(ctx context.Context, userID uuid.UUID, role *models.AdminRole, blocked *bool) error {
+	if err := s.userRepo.UpdateAdmin(ctx, userID, role, blocked); err != nil {
+		return err
+	}
+	s.invalidate(ctx, userID)
+	return nil
+}
+
+// RevokeAccessTokens invalidates every access token issued to a user so far
+func (s *AccessService) RevokeAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.userRepo.IncrementTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+	s.invalidate(ctx, userID)
+	return nil
+}
+
+func (s *AccessService) state(ctx context.Context, userID uuid.UUID) (*models.UserAccessState, error) {
+	key := accessStateKey(userID)
+
+	if s.cache != nil {
+		if cached, err := s.cache.Get(ctx, key); err == nil {
+			var state models.UserAccessState
+			if jsonErr := json.Unmarshal([]byte(cached), &state); jsonErr == nil {
+				return &state, nil
+			}
+		}
+	}
+
+	state, err := s.userRepo.GetAccessState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cache != nil {
+		if stateJSON, err := json.Marshal(state); err == nil {
+			_ = s.cache.Set(ctx, key, stateJSON, accessStateTTL)
+		}
+	}
+
+	return state, nil
+}
+
+func (s *AccessService) invalidate(ctx context.Context, userID uuid.UUID) {
+	if s.cache == nil {
+		return
+	}
+	_ = s.cache.Delete(ctx, accessStateKey(userID))
+}
+
+func accessStateKey(userID uuid.UUID) string {
+	return "user:access:v2:" + userID.String()
+}
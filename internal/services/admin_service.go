@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownAdminRole = errors.New("unknown admin role")
+	ErrNotAnAdmin       = errors.New("user is not an admin")
+)
+
+// adminInviteExpiry is how long an admin invitation link stays valid
+const adminInviteExpiry = 72 * time.Hour
+
+// AdminService manages the admin team: inviting new admins and changing the
+// role or access of existing ones
+type AdminService struct {
+	userRepo      *repository.UserRepository
+	roleRepo      *repository.RoleRepository
+	inviteRepo    *repository.AdminInviteRepository
+	accessService *AccessService
+	emailService  *EmailService
+}
+
+func NewAdminService(
+	userRepo *repository.UserRepository,
+	roleRepo *repository.RoleRepository,
+	inviteRepo *repository.AdminInviteRepository,
+	accessService *AccessService,
+	emailService *EmailService,
+) *AdminService {
+	return &AdminService{
+		userRepo:      userRepo,
+		roleRepo:      roleRepo,
+		inviteRepo:    inviteRepo,
+		accessService: accessService,
+		emailService:  emailService,
+	}
+}
+
+// Invite emails a one-time link that lets email join as an admin with role.
+// Inviting the same address again replaces the earlier link.
+func (s *AdminService) Invite(ctx context.Context, inviterID uuid.UUID, email string, role models.AdminRole) (*models.AdminInvite, error) {
+	exists, err := s.userRepo.ExistsByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEmailAlreadyExists
+	}
+
+	if err := s.checkRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	inviter, err := s.userRepo.GetByID(ctx, inviterID)
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &models.AdminInvite{
+		Email:     email,
+		AdminRole: role,
+		InvitedBy: &inviterID,
+	}
+	if err := s.inviteRepo.Create(ctx, invite, adminInviteExpiry); err != nil {
+		return nil, err
+	}
+
+	if s.emailService != nil {
+		go s.emailService.SendAdminInviteEmail(invite.Email, inviter.Name, invite.Token)
+	}
+
+	return invite, nil
+}
+
+// AcceptInvite creates the admin account an invite was for. The address the
+// invite was sent to counts as verified.
+func (s *AdminService) AcceptInvite(ctx context.Context, req *models.AcceptInviteRequest) (*models.User, error) {
+	if err := utils.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+
+	invite, err := s.inviteRepo.GetByToken(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	referralCode := utils.GenerateReferralCode(req.Name)
+	for {
+		exists, err := s.userRepo.ExistsByReferralCode(ctx, referralCode)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			break
+		}
+		referralCode = utils.GenerateReferralCode(req.Name)
+	}
+
+	verifiedAt := time.Now()
+	adminRole := invite.AdminRole
+	user := &models.User{
+		ID:              uuid.New(),
+		Email:           invite.Email,
+		PasswordHash:    passwordHash,
+		Name:            req.Name,
+		Role:            models.RoleAdmin,
+		AdminRole:       &adminRole,
+		ReferralCode:    referralCode,
+		EmailVerifiedAt: &verifiedAt,
+	}
+
+	if err := s.inviteRepo.Accept(ctx, invite.ID, user); err != nil {
+		if errors.Is(err, repository.ErrUserExists) {
+			return nil, ErrEmailAlreadyExists
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ListAdmins returns a page of admin accounts
+func (s *AdminService) ListAdmins(ctx context.Context, page, perPage int) ([]models.User, int64, error) {
+	return s.userRepo.List(ctx, models.RoleAdmin, page, perPage)
+}
+
+// ListRoles returns the admin roles that can be assigned
+func (s *AdminService) ListRoles(ctx context.Context) ([]models.AdminRoleInfo, error) {
+	return s.roleRepo.List(ctx)
+}
+
+// GetAdmin returns an admin account, or ErrNotAnAdmin for any other user
+func (s *AdminService) GetAdmin(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != models.RoleAdmin {
+		return nil, ErrNotAnAdmin
+	}
+	return user, nil
+}
+
+// UpdateAdmin changes an admin's role and/or blocks or unblocks them. Both
+// changes are applied together or not at all, and the last active super
+// admin can be neither demoted nor blocked.
+func (s *AdminService) UpdateAdmin(ctx context.Context, userID uuid.UUID, req *models.UpdateAdminRequest) (*models.User, error) {
+	if _, err := s.GetAdmin(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if req.AdminRole != nil {
+		if err := s.checkRole(ctx, *req.AdminRole); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.accessService.UpdateAdmin(ctx, userID, req.AdminRole, req.IsBlocked); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *AdminService) checkRole(ctx context.Context, role models.AdminRole) error {
+	exists, err := s.roleRepo.Exists(ctx, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownAdminRole
+	}
+	return nil
+}
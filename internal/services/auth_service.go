@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/cirvee/referral-backend/internal/models"
 	"github.com/cirvee/referral-backend/internal/repository"
@@ -14,21 +15,45 @@ var (
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrEmailAlreadyExists = errors.New("email already exists")
 	ErrUserBlocked        = errors.New("user account is blocked")
+	ErrIncorrectPassword  = errors.New("current password is incorrect")
+	ErrPasswordUnchanged  = errors.New("new password must differ from the current password")
 )
 
 type AuthService struct {
-	userRepo   *repository.UserRepository
-	jwtManager *utils.JWTManager
+	userRepo         *repository.UserRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	accessService    *AccessService
+	mfaService       *MFAService
+	loginSecurity    *LoginSecurityService
+	jwtManager       *utils.JWTManager
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtManager *utils.JWTManager) *AuthService {
+// NewAuthService creates the auth service. With a nil mfaService login never
+// asks for a second factor; with a nil loginSecurity failed logins are
+// neither throttled nor recorded.
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	refreshTokenRepo *repository.RefreshTokenRepository,
+	accessService *AccessService,
+	mfaService *MFAService,
+	loginSecurity *LoginSecurityService,
+	jwtManager *utils.JWTManager,
+) *AuthService {
 	return &AuthService{
-		userRepo:   userRepo,
-		jwtManager: jwtManager,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		accessService:    accessService,
+		mfaService:       mfaService,
+		loginSecurity:    loginSecurity,
+		jwtManager:       jwtManager,
 	}
 }
 
 func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error) {
+	if err := utils.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+
 	// Check if email exists
 	exists, err := s.userRepo.ExistsByEmail(ctx, req.Email)
 	if err != nil {
@@ -75,62 +100,118 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
 		return nil, err
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		return nil, err
-	}
+	return s.startSession(ctx, user, false)
+}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		return nil, err
+// Login checks a user's password. Users with two-factor authentication
+// enabled get a challenge to pass to VerifyMFAChallenge instead of tokens.
+// Repeated failures for an email make it wait before trying again.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.MFAChallengeResponse, error) {
+	if s.loginSecurity != nil {
+		if err := s.loginSecurity.Check(ctx, req.Email); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return &models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         *user,
-	}, nil
-}
-
-func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
 	// Find user by email
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			return nil, ErrInvalidCredentials
+			// Unknown emails are throttled too, so lockouts reveal nothing
+			s.recordLoginFailure(ctx, req.Email, nil, client, models.LoginFailureInvalidPassword)
+			return nil, nil, ErrInvalidCredentials
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Check if user is blocked
 	if user.IsBlocked {
-		return nil, ErrUserBlocked
+		return nil, nil, ErrUserBlocked
 	}
 
 	// Check password
 	if !utils.CheckPassword(req.Password, user.PasswordHash) {
-		return nil, ErrInvalidCredentials
+		s.recordLoginFailure(ctx, req.Email, user, client, models.LoginFailureInvalidPassword)
+		return nil, nil, ErrInvalidCredentials
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email, string(user.Role))
+	if s.mfaService != nil {
+		enabled, err := s.mfaService.Enabled(ctx, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if enabled {
+			challenge, err := s.jwtManager.GenerateChallengeToken(user.ID, user.Email, string(user.Role))
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, &models.MFAChallengeResponse{
+				MFARequired:    true,
+				ChallengeToken: challenge,
+				ExpiresIn:      int(utils.ChallengeExpiry.Seconds()),
+			}, nil
+		}
+	}
+
+	response, err := s.startSession(ctx, user, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.recordLoginSuccess(ctx, user, client)
+	return response, nil, nil
+}
+
+// VerifyMFAChallenge completes a login that returned a challenge. Wrong codes
+// count as failed logins; a challenge that completed a login cannot be used
+// again.
+func (s *AuthService) VerifyMFAChallenge(ctx context.Context, challengeToken, code string, client models.ClientInfo) (*models.AuthResponse, error) {
+	claims, err := s.jwtManager.ValidateChallengeToken(challengeToken)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
+	if s.loginSecurity != nil {
+		if err := s.loginSecurity.Check(ctx, claims.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         *user,
-	}, nil
+	if user.IsBlocked {
+		return nil, ErrUserBlocked
+	}
+
+	if s.mfaService == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.mfaService.Verify(ctx, user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, user.Email, user, client, models.LoginFailureInvalidMFACode)
+		}
+		return nil, err
+	}
+	expiresAt := time.Now().Add(utils.ChallengeExpiry)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := s.mfaService.UseChallenge(ctx, claims.ID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	response, err := s.startSession(ctx, user, true)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLoginSuccess(ctx, user, client)
+	return response, nil
 }
 
+// RefreshToken exchanges a refresh token for a new pair. Each refresh token
+// can be used once; presenting a used one revokes the whole session.
 func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*models.AuthResponse, error) {
 	// Validate refresh token
 	claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
@@ -138,26 +219,104 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*m
 		return nil, err
 	}
 
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, utils.ErrInvalidToken
+	}
+
 	// Get user
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate new tokens
-	newAccessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email, string(user.Role))
+	if user.IsBlocked {
+		return nil, ErrUserBlocked
+	}
+
+	next := &repository.RefreshToken{
+		ID:        uuid.New(),
+		ExpiresAt: time.Now().Add(s.jwtManager.RefreshExpiry()),
+	}
+	if err := s.refreshTokenRepo.Rotate(ctx, tokenID, user.ID, next); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, next.ID, claims.MFA)
+}
+
+// Logout revokes the session a refresh token belongs to. Tokens that are
+// invalid or already revoked are ignored.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil
+	}
+
+	return s.refreshTokenRepo.RevokeFamily(ctx, tokenID, claims.UserID)
+}
+
+// LogoutAll revokes every session of a user, including access tokens that
+// have not expired yet
+func (s *AuthService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.accessService.RevokeAccessTokens(ctx, userID)
+}
+
+func (s *AuthService) recordLoginFailure(ctx context.Context, email string, user *models.User, client models.ClientInfo, reason string) {
+	if s.loginSecurity != nil {
+		s.loginSecurity.RecordFailure(ctx, email, user, client, reason)
+	}
+}
+
+func (s *AuthService) recordLoginSuccess(ctx context.Context, user *models.User, client models.ClientInfo) {
+	if s.loginSecurity != nil {
+		s.loginSecurity.RecordSuccess(ctx, user, client)
+	}
+}
+
+// startSession issues a token pair that begins a new refresh token family.
+// mfa records whether a second factor was checked.
+func (s *AuthService) startSession(ctx context.Context, user *models.User, mfa bool) (*models.AuthResponse, error) {
+	token := &repository.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(s.jwtManager.RefreshExpiry()),
+	}
+	if err := s.refreshTokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, token.ID, mfa)
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, refreshTokenID uuid.UUID, mfa bool) (*models.AuthResponse, error) {
+	state, err := s.userRepo.GetAccessState(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email, string(user.Role), state.TokenVersion, mfa)
 	if err != nil {
 		return nil, err
 	}
 
-	newRefreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email, string(user.Role), refreshTokenID, mfa)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.AuthResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 		User:         *user,
 	}, nil
 }
@@ -181,15 +340,20 @@ func (s *AuthService) CreateAdmin(ctx context.Context, email, password, name str
 	// Generate referral code
 	referralCode := utils.GenerateReferralCode(name)
 
-	// Create admin user
+	// Create admin user. Admins are provisioned directly, so their email
+	// address needs no confirmation. The first admin has full access.
+	verifiedAt := time.Now()
+	adminRole := models.AdminRoleSuperAdmin
 	user := &models.User{
-		ID:           uuid.New(),
-		Email:        email,
-		PasswordHash: passwordHash,
-		Name:         name,
-		Phone:        "",
-		Role:         models.RoleAdmin,
-		ReferralCode: referralCode,
+		ID:              uuid.New(),
+		Email:           email,
+		PasswordHash:    passwordHash,
+		Name:            name,
+		Phone:           "",
+		Role:            models.RoleAdmin,
+		AdminRole:       &adminRole,
+		ReferralCode:    referralCode,
+		EmailVerifiedAt: &verifiedAt,
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
@@ -199,8 +363,45 @@ func (s *AuthService) CreateAdmin(ctx context.Context, email, password, name str
 	return user, nil
 }
 
-// UpdatePassword updates a user's password
+// VerifyEmail marks a user's email address as confirmed
+func (s *AuthService) VerifyEmail(ctx context.Context, userID uuid.UUID) error {
+	return s.accessService.MarkEmailVerified(ctx, userID)
+}
+
+// ChangePassword replaces a user's password after checking the current one.
+// Every other session is ended; the returned token pair continues the
+// caller's session, keeping whether it passed a second factor.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string, mfa bool) (*models.AuthResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.CheckPassword(currentPassword, user.PasswordHash) {
+		return nil, ErrIncorrectPassword
+	}
+	if currentPassword == newPassword {
+		return nil, ErrPasswordUnchanged
+	}
+
+	if err := s.UpdatePassword(ctx, userID, newPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, mfa)
+}
+
+// UpdatePassword updates a user's password after checking it against the
+// password policy
 func (s *AuthService) UpdatePassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
+	if err := utils.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
 	// Hash new password
 	passwordHash, err := utils.HashPassword(newPassword)
 	if err != nil {
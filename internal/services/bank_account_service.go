@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/banks"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBankVerificationUnavailable = errors.New("bank account verification is not available")
+	ErrUnknownBank                 = errors.New("unknown bank code")
+	ErrAccountNotResolved          = errors.New("could not resolve bank account")
+	ErrAccountNameMismatch         = errors.New("bank account name does not match your name")
+	ErrBankVerificationFailed      = errors.New("bank account could not be verified")
+)
+
+// bankChangeExpiry is how long a bank detail change can be confirmed for
+const bankChangeExpiry = 24 * time.Hour
+
+// BankAccountService manages users' bank details. New details are verified
+// with the payout provider, so payouts only go to accounts that exist and, unless
+// flagged, belong to the user, and they only take effect once confirmed from
+// the user's email, so a hijacked session cannot redirect payouts.
+type BankAccountService struct {
+	changeRepo     *repository.BankChangeRepository
+	userRepo       *repository.UserRepository
+	provider       payouts.PayoutProvider
+	banks          *banks.Directory
+	emailService   *EmailService
+	rejectMismatch bool
+	cooldown       time.Duration
+}
+
+// NewBankAccountService returns a BankAccountService. With rejectMismatch,
+// accounts whose holder name does not match the user's name are refused
+// rather than flagged. Payouts to a replacement account are held for
+// cooldown after it is confirmed.
+func NewBankAccountService(
+	changeRepo *repository.BankChangeRepository,
+	userRepo *repository.UserRepository,
+	provider payouts.PayoutProvider,
+	bankDirectory *banks.Directory,
+	emailService *EmailService,
+	rejectMismatch bool,
+	cooldown time.Duration,
+) *BankAccountService {
+	return &BankAccountService{
+		changeRepo:     changeRepo,
+		userRepo:       userRepo,
+		provider:       provider,
+		banks:          bankDirectory,
+		emailService:   emailService,
+		rejectMismatch: rejectMismatch,
+		cooldown:       cooldown,
+	}
+}
+
+// RequestChange verifies new bank details for user and stores them as a
+// pending change, emailing the user a link to confirm it. Any earlier
+// pending change stops working.
+func (s *BankAccountService) RequestChange(ctx context.Context, user *models.User, bankCode, accountNumber, ipAddress string) (*models.BankDetailChange, error) {
+	change, err := s.verify(ctx, user.Name, bankCode, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	change.UserID = user.ID
+	if ipAddress != "" {
+		change.IPAddress = &ipAddress
+	}
+
+	if err := s.changeRepo.Create(ctx, change, bankChangeExpiry); err != nil {
+		return nil, err
+	}
+
+	go s.emailService.SendBankChangeConfirmationEmail(user.Email, user.Name, change.BankName, change.AccountNumber, change.Token)
+
+	return change, nil
+}
+
+// ConfirmChange applies the pending change with token and tells the user
+// their details changed
+func (s *BankAccountService) ConfirmChange(ctx context.Context, token string) (*models.BankDetailChange, error) {
+	change, err := s.changeRepo.Confirm(ctx, token, s.cooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	if user, err := s.userRepo.GetByID(ctx, change.UserID); err == nil {
+		go s.emailService.SendBankDetailsChangedEmail(user.Email, user.Name, change.BankName, change.AccountNumber, change.HoldUntil)
+	}
+
+	return change, nil
+}
+
+// Pending returns the user's change awaiting confirmation, or nil
+func (s *BankAccountService) Pending(ctx context.Context, userID uuid.UUID) (*models.BankDetailChange, error) {
+	change, err := s.changeRepo.GetPending(ctx, userID)
+	if errors.Is(err, repository.ErrBankChangeNotFound) {
+		return nil, nil
+	}
+	return change, err
+}
+
+// History returns every bank detail change requested for the user, newest
+// first
+func (s *BankAccountService) History(ctx context.Context, userID uuid.UUID) ([]models.BankDetailChange, error) {
+	return s.changeRepo.ListByUser(ctx, userID)
+}
+
+// Recheck updates the mismatch flag after user's name has changed. Names
+// are not checked against unverified accounts.
+func (s *BankAccountService) Recheck(user *models.User) {
+	if user.BankVerifiedAt != nil {
+		user.BankNameMismatch = !utils.NamesMatch(user.Name, user.AccountName)
+	}
+}
+
+// verify resolves accountNumber at the bank with bankCode and returns the
+// resulting details, noting whether the holder's name differs from name
+func (s *BankAccountService) verify(ctx context.Context, name, bankCode, accountNumber string) (*models.BankDetailChange, error) {
+	if s.provider == nil || !s.provider.Enabled() {
+		return nil, ErrBankVerificationUnavailable
+	}
+
+	bank, ok := s.banks.ByCode(ctx, bankCode)
+	if !ok {
+		return nil, ErrUnknownBank
+	}
+
+	account, err := s.provider.ResolveAccount(ctx, accountNumber, bank.Code)
+	if err != nil {
+		if errors.Is(err, payouts.ErrAccountNotFound) {
+			return nil, ErrAccountNotResolved
+		}
+		return nil, fmt.Errorf("%w: %v", ErrBankVerificationFailed, err)
+	}
+
+	mismatch := !utils.NamesMatch(name, account.AccountName)
+	if mismatch && s.rejectMismatch {
+		return nil, ErrAccountNameMismatch
+	}
+
+	return &models.BankDetailChange{
+		BankCode:         bank.Code,
+		BankName:         bank.Name,
+		AccountNumber:    accountNumber,
+		AccountName:      account.AccountName,
+		BankNameMismatch: mismatch,
+	}, nil
+}
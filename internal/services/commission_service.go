@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCommissionRule = errors.New("invalid commission rule")
+)
+
+type CommissionService struct {
+	ruleRepo   *repository.CommissionRepository
+	courseRepo *repository.CourseRepository
+}
+
+func NewCommissionService(ruleRepo *repository.CommissionRepository, courseRepo *repository.CourseRepository) *CommissionService {
+	return &CommissionService{
+		ruleRepo:   ruleRepo,
+		courseRepo: courseRepo,
+	}
+}
+
+// Evaluate returns the commission earned for referring a student to the course
+// and the rule that produced it. When no rule is in effect the commission is
+// zero and the returned rule is nil.
+func (s *CommissionService) Evaluate(ctx context.Context, course *models.Course, at time.Time) (int64, *models.CommissionRule, error) {
+	rule, err := s.ruleRepo.GetActiveForCourse(ctx, course.ID, at)
+	if err != nil {
+		if errors.Is(err, repository.ErrCommissionRuleNotFound) {
+			return 0, nil, nil
+		}
+		return 0, nil, err
+	}
+
+	return calculateCommission(rule, course.Price), rule, nil
+}
+
+// CreateRule validates and stores a new commission rule
+func (s *CommissionService) CreateRule(ctx context.Context, req *models.CreateCommissionRuleRequest, createdBy uuid.UUID) (*models.CommissionRule, error) {
+	if req.Type == models.CommissionTypePercentage && req.Value > 10000 {
+		return nil, ErrInvalidCommissionRule
+	}
+
+	rule := &models.CommissionRule{
+		ID:            uuid.New(),
+		Name:          req.Name,
+		CourseID:      req.CourseID,
+		Type:          req.Type,
+		Value:         req.Value,
+		Cap:           req.Cap,
+		EffectiveFrom: time.Now(),
+		EffectiveTo:   req.EffectiveTo,
+		CreatedBy:     &createdBy,
+	}
+	if req.EffectiveFrom != nil {
+		rule.EffectiveFrom = *req.EffectiveFrom
+	}
+	if rule.EffectiveTo != nil && !rule.EffectiveTo.After(rule.EffectiveFrom) {
+		return nil, ErrInvalidCommissionRule
+	}
+
+	if rule.CourseID != nil {
+		course, err := s.courseRepo.GetByID(ctx, *rule.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		rule.CourseName = course.Name
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// calculateCommission applies a rule to a course price. Percentage values are
+// in basis points and the optional cap bounds the result for either type.
+func calculateCommission(rule *models.CommissionRule, coursePrice int64) int64 {
+	var amount int64
+	switch rule.Type {
+	case models.CommissionTypeFlat:
+		amount = rule.Value
+	case models.CommissionTypePercentage:
+		amount = coursePrice * rule.Value / 10000
+	}
+
+	if rule.Cap != nil && amount > *rule.Cap {
+		amount = *rule.Cap
+	}
+	if amount < 0 {
+		amount = 0
+	}
+
+	return amount
+}
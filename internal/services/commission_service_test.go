@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateCommission(t *testing.T) {
+	capAt := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name  string
+		rule  models.CommissionRule
+		price int64
+		want  int64
+	}{
+		{"flat", models.CommissionRule{Type: models.CommissionTypeFlat, Value: 10000}, 750000, 10000},
+		{"flat_ignores_price", models.CommissionRule{Type: models.CommissionTypeFlat, Value: 10000}, 100000, 10000},
+		{"percentage", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 500}, 400000, 20000},
+		{"percentage_rounds_down", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 333}, 100001, 3330},
+		{"percentage_capped", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 1000, Cap: capAt(50000)}, 750000, 50000},
+		{"percentage_below_cap", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 1000, Cap: capAt(50000)}, 350000, 35000},
+		{"flat_capped", models.CommissionRule{Type: models.CommissionTypeFlat, Value: 20000, Cap: capAt(15000)}, 750000, 15000},
+		{"unknown_type", models.CommissionRule{Type: "tiered", Value: 10000}, 750000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, calculateCommission(&tt.rule, tt.price))
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"html/template"
 	"net/smtp"
+	"strings"
+	"time"
 
 	"github.com/cirvee/referral-backend/internal/config"
 )
@@ -80,6 +82,48 @@ func (s *EmailService) SendWelcomeEmail(email, name string) error {
 	return s.SendEmail(email, subject, body)
 }
 
+// SendVerificationEmail sends the link a new user follows to confirm their
+// email address
+func (s *EmailService) SendVerificationEmail(email, name, verificationToken string) error {
+	subject := "Verify Your Email - Cirvee"
+	verifyLink := fmt.Sprintf("%s/verify-email?token=%s", s.cfg.FrontendURL, verificationToken)
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #1F2937; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6D00E7; color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #EFF4FE; padding: 30px; border-radius: 0 0 10px 10px; }
+        .button { display: inline-block; background: #6D00E7; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; margin-top: 20px; }
+        .footer { text-align: center; margin-top: 20px; color: #808080; font-size: 12px; }
+        .warning { background: #FFCA9E; border: 1px solid #ffc107; padding: 15px; border-radius: 5px; margin-top: 20px; color: #1F2937; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Confirm Your Email</h1>
+        </div>
+        <div class="content">
+            <h2>Hi %s,</h2>
+            <p>Thanks for signing up to Cirvee. Please confirm your email address to start tracking referrals and requesting payouts:</p>
+            <a href="%s" class="button">Verify Email</a>
+            <div class="warning">
+                <strong>⚠️ Important:</strong> This link will expire in 24 hours. If you didn't create an account, please ignore this email.
+            </div>
+        </div>
+        <div class="footer">
+            <p>© 2024 Cirvee. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, name, verifyLink)
+	return s.SendEmail(email, subject, body)
+}
+
 // SendPasswordResetEmail sends a password reset link
 func (s *EmailService) SendPasswordResetEmail(email, resetToken string) error {
 	subject := "Reset Your Password - Cirvee"
@@ -120,7 +164,244 @@ func (s *EmailService) SendPasswordResetEmail(email, resetToken string) error {
 	return s.SendEmail(email, subject, body)
 }
 
+// SendPasswordChangedEmail tells a user their password was changed, in case
+// it was not them
+func (s *EmailService) SendPasswordChangedEmail(email, name string) error {
+	subject := "Your Password Was Changed - Cirvee"
+	resetLink := fmt.Sprintf("%s/forgot-password", s.cfg.FrontendURL)
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #1F2937; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6D00E7; color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #EFF4FE; padding: 30px; border-radius: 0 0 10px 10px; }
+        .button { display: inline-block; background: #6D00E7; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; margin-top: 20px; }
+        .footer { text-align: center; margin-top: 20px; color: #808080; font-size: 12px; }
+        .warning { background: #FFCA9E; border: 1px solid #ffc107; padding: 15px; border-radius: 5px; margin-top: 20px; color: #1F2937; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Password Changed</h1>
+        </div>
+        <div class="content">
+            <h2>Hi %s,</h2>
+            <p>The password for your Cirvee account was just changed. You have been signed out on all other devices.</p>
+            <div class="warning">
+                <strong>⚠️ Wasn't you?</strong> Reset your password straight away and contact support.
+            </div>
+            <a href="%s" class="button">Reset Password</a>
+        </div>
+        <div class="footer">
+            <p>© 2024 Cirvee. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, name, resetLink)
+	return s.SendEmail(email, subject, body)
+}
+
+// SendNewSignInEmail alerts a user to a sign-in from a device or network they
+// have not used before
+func (s *EmailService) SendNewSignInEmail(email, name, ipAddress, userAgent string, at time.Time) error {
+	subject := "New Sign-in to Your Account - Cirvee"
+	resetLink := fmt.Sprintf("%s/forgot-password", s.cfg.FrontendURL)
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #1F2937; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6D00E7; color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #EFF4FE; padding: 30px; border-radius: 0 0 10px 10px; }
+        .details { background: white; padding: 20px; border-radius: 8px; margin: 20px 0; }
+        .button { display: inline-block; background: #6D00E7; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; margin-top: 20px; }
+        .footer { text-align: center; margin-top: 20px; color: #808080; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>New Sign-in</h1>
+        </div>
+        <div class="content">
+            <h2>Hi %s,</h2>
+            <p>Your Cirvee account was signed in to from a device we haven't seen before.</p>
+            <div class="details">
+                <p><strong>Time:</strong> %s</p>
+                <p><strong>IP address:</strong> %s</p>
+                <p><strong>Device:</strong> %s</p>
+            </div>
+            <p>If this was you, there's nothing to do. If not, reset your password straight away.</p>
+            <a href="%s" class="button">Reset Password</a>
+        </div>
+        <div class="footer">
+            <p>© 2024 Cirvee. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(name), at.UTC().Format(time.RFC1123), template.HTMLEscapeString(ipAddress), template.HTMLEscapeString(userAgent), resetLink)
+	return s.SendEmail(email, subject, body)
+}
+
+// SendBankChangeConfirmationEmail asks a user to confirm new bank details.
+// The details only take effect once the link is followed.
+func (s *EmailService) SendBankChangeConfirmationEmail(email, name, bankName, accountNumber, confirmToken string) error {
+	subject := "Confirm Your New Bank Details - Cirvee"
+	confirmLink := fmt.Sprintf("%s/confirm-bank-change?token=%s", s.cfg.FrontendURL, confirmToken)
+	resetLink := fmt.Sprintf("%s/forgot-password", s.cfg.FrontendURL)
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #1F2937; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6D00E7; color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #EFF4FE; padding: 30px; border-radius: 0 0 10px 10px; }
+        .details { background: white; padding: 20px; border-radius: 8px; margin: 20px 0; }
+        .button { display: inline-block; background: #6D00E7; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; margin-top: 20px; }
+        .footer { text-align: center; margin-top: 20px; color: #808080; font-size: 12px; }
+        .warning { background: #FFCA9E; border: 1px solid #ffc107; padding: 15px; border-radius: 5px; margin-top: 20px; color: #1F2937; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Confirm Bank Details</h1>
+        </div>
+        <div class="content">
+            <h2>Hi %s,</h2>
+            <p>A request was made to send your future payouts to this account:</p>
+            <div class="details">
+                <p><strong>Bank:</strong> %s</p>
+                <p><strong>Account number:</strong> %s</p>
+            </div>
+            <p>Nothing changes until you confirm:</p>
+            <a href="%s" class="button">Confirm Bank Details</a>
+            <div class="warning">
+                <strong>⚠️ Wasn't you?</strong> Don't click the link. Someone may have access to your account, so reset your password straight away: <a href="%s">%s</a>
+            </div>
+        </div>
+        <div class="footer">
+            <p>© 2024 Cirvee. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(name), template.HTMLEscapeString(bankName), maskAccountNumber(accountNumber), confirmLink, resetLink, resetLink)
+	return s.SendEmail(email, subject, body)
+}
+
+// SendBankDetailsChangedEmail tells a user their bank details were changed
+// and, if payouts to the new account are held, until when
+func (s *EmailService) SendBankDetailsChangedEmail(email, name, bankName, accountNumber string, holdUntil *time.Time) error {
+	subject := "Your Bank Details Were Changed - Cirvee"
+	resetLink := fmt.Sprintf("%s/forgot-password", s.cfg.FrontendURL)
+
+	hold := ""
+	if holdUntil != nil {
+		hold = fmt.Sprintf("<p>For your security, payouts to the new account are on hold until %s.</p>", holdUntil.UTC().Format(time.RFC1123))
+	}
+
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #1F2937; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6D00E7; color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #EFF4FE; padding: 30px; border-radius: 0 0 10px 10px; }
+        .details { background: white; padding: 20px; border-radius: 8px; margin: 20px 0; }
+        .button { display: inline-block; background: #6D00E7; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; margin-top: 20px; }
+        .footer { text-align: center; margin-top: 20px; color: #808080; font-size: 12px; }
+        .warning { background: #FFCA9E; border: 1px solid #ffc107; padding: 15px; border-radius: 5px; margin-top: 20px; color: #1F2937; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Bank Details Changed</h1>
+        </div>
+        <div class="content">
+            <h2>Hi %s,</h2>
+            <p>Your payouts will now be sent to:</p>
+            <div class="details">
+                <p><strong>Bank:</strong> %s</p>
+                <p><strong>Account number:</strong> %s</p>
+            </div>
+            %s
+            <div class="warning">
+                <strong>⚠️ Wasn't you?</strong> Reset your password straight away and contact support.
+            </div>
+            <a href="%s" class="button">Reset Password</a>
+        </div>
+        <div class="footer">
+            <p>© 2024 Cirvee. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(name), template.HTMLEscapeString(bankName), maskAccountNumber(accountNumber), hold, resetLink)
+	return s.SendEmail(email, subject, body)
+}
+
+// maskAccountNumber hides all but the last four digits of an account number
+func maskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
 // SendStudentConfirmation sends confirmation email to student
+func (s *EmailService) SendAdminInviteEmail(email, inviterName, inviteToken string) error {
+	subject := "You've been invited to join Cirvee as an admin"
+	inviteLink := fmt.Sprintf("%s/accept-invite?token=%s", s.cfg.FrontendURL, inviteToken)
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #1F2937; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #6D00E7; color: white; padding: 30px; text-align: center; border-radius: 10px 10px 0 0; }
+        .content { background: #EFF4FE; padding: 30px; border-radius: 0 0 10px 10px; }
+        .button { display: inline-block; background: #6D00E7; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; margin-top: 20px; }
+        .footer { text-align: center; margin-top: 20px; color: #808080; font-size: 12px; }
+        .warning { background: #FFCA9E; border: 1px solid #ffc107; padding: 15px; border-radius: 5px; margin-top: 20px; color: #1F2937; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Admin Invitation</h1>
+        </div>
+        <div class="content">
+            <p>%s has invited you to help run the Cirvee referral programme. Click the button below to set your password and join:</p>
+            <a href="%s" class="button">Accept Invitation</a>
+            <div class="warning">
+                <strong>⚠️ Important:</strong> This link will expire in 72 hours and can only be used once. If you weren't expecting it, please ignore this email.
+            </div>
+        </div>
+        <div class="footer">
+            <p>© 2024 Cirvee. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(inviterName), inviteLink)
+	return s.SendEmail(email, subject, body)
+}
+
 func (s *EmailService) SendStudentConfirmation(email, name, course string) error {
 	subject := "Registration Confirmed - Cirvee"
 	body := fmt.Sprintf(`
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// LedgerService translates business events into balanced journal entries.
+// Referrer accounts are liabilities: credits (negative postings) increase what
+// the platform owes the referrer and debits (positive postings) reduce it.
+type LedgerService struct {
+	ledgerRepo *repository.LedgerRepository
+	userRepo   *repository.UserRepository
+}
+
+func NewLedgerService(ledgerRepo *repository.LedgerRepository, userRepo *repository.UserRepository) *LedgerService {
+	return &LedgerService{
+		ledgerRepo: ledgerRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// AccrueCommission returns a hook that credits the referrer with the
+// referral's earnings when the referral is created.
+func (s *LedgerService) AccrueCommission(referral *models.Referral) repository.TxHook {
+	return func(ctx context.Context, tx pgx.Tx) error {
+		if referral.ReferrerID == nil || referral.Earnings <= 0 {
+			return nil
+		}
+
+		entry := &models.LedgerEntry{
+			Kind:          models.LedgerEntryCommission,
+			Description:   fmt.Sprintf("Commission for %s (%s)", referral.ReferredName, referral.Course),
+			ReferenceType: "referral",
+			ReferenceID:   &referral.ID,
+			Postings: []models.LedgerPosting{
+				{AccountCode: models.LedgerAccountCommissionExpense, Amount: referral.Earnings},
+				{UserID: referral.ReferrerID, Amount: -referral.Earnings},
+			},
+		}
+		return s.post(ctx, tx, entry)
+	}
+}
+
+// SettlePayout returns a hook that debits the referrer when a payout is approved
+func (s *LedgerService) SettlePayout(approvedBy uuid.UUID) repository.PayoutHook {
+	return func(ctx context.Context, tx pgx.Tx, payout *models.Payout) error {
+		if payout.Status != models.PayoutStatusApproved {
+			return nil
+		}
+
+		entry := &models.LedgerEntry{
+			Kind:          models.LedgerEntryPayout,
+			Description:   "Payout to referrer",
+			ReferenceType: "payout",
+			ReferenceID:   &payout.ID,
+			CreatedBy:     &approvedBy,
+			Postings: []models.LedgerPosting{
+				{UserID: &payout.UserID, Amount: payout.Amount},
+				{AccountCode: models.LedgerAccountCash, Amount: -payout.Amount},
+			},
+		}
+		return s.post(ctx, tx, entry)
+	}
+}
+
+// ReversePayout credits the referrer back when an approved payout's transfer
+// fails or is reversed by the bank.
+func (s *LedgerService) ReversePayout(ctx context.Context, tx pgx.Tx, payout *models.Payout) error {
+	entry := &models.LedgerEntry{
+		Kind:          models.LedgerEntryPayoutReversal,
+		Description:   "Payout transfer " + string(payout.Status),
+		ReferenceType: "payout",
+		ReferenceID:   &payout.ID,
+		Postings: []models.LedgerPosting{
+			{AccountCode: models.LedgerAccountCash, Amount: payout.Amount},
+			{UserID: &payout.UserID, Amount: -payout.Amount},
+		},
+	}
+	return s.post(ctx, tx, entry)
+}
+
+// ReferralStatusChange returns a hook that records the ledger effect of an
+// admin moving a referral to newStatus: rejecting or reversing claws the
+// commission back, and marking a referral paid records an out-of-band payment.
+func (s *LedgerService) ReferralStatusChange(newStatus string, actor uuid.UUID) repository.ReferralHook {
+	return func(ctx context.Context, tx pgx.Tx, referral *models.Referral) error {
+		if referral.ReferrerID == nil || referral.Earnings <= 0 || referral.Status == newStatus {
+			return nil
+		}
+
+		switch newStatus {
+		case models.ReferralStatusRejected, models.ReferralStatusReversed:
+			return s.clawBack(ctx, tx, referral, actor)
+		case models.ReferralStatusPaid:
+			return s.recordManualPayment(ctx, tx, referral, actor)
+		}
+		return nil
+	}
+}
+
+// ManualPayments returns a hook that records out-of-band payments for
+// referrals marked paid in bulk.
+func (s *LedgerService) ManualPayments(actor uuid.UUID) repository.ReferralsHook {
+	return func(ctx context.Context, tx pgx.Tx, referrals []models.Referral) error {
+		for i := range referrals {
+			if referrals[i].ReferrerID == nil || referrals[i].Earnings <= 0 {
+				continue
+			}
+			if err := s.recordManualPayment(ctx, tx, &referrals[i], actor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Adjust posts an admin correction or bonus to a referrer's balance. Positive
+// amounts increase what the referrer is owed; negative amounts reduce it.
+func (s *LedgerService) Adjust(ctx context.Context, req *models.LedgerAdjustmentRequest, adminID uuid.UUID) (*models.LedgerEntry, error) {
+	if _, err := s.userRepo.GetByID(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	entry := &models.LedgerEntry{
+		ID:            uuid.New(),
+		Kind:          models.LedgerEntryAdjustment,
+		Description:   req.Reason,
+		ReferenceType: "adjustment",
+		CreatedBy:     &adminID,
+		Postings: []models.LedgerPosting{
+			{AccountCode: models.LedgerAccountAdjustments, Amount: req.Amount},
+			{UserID: &req.UserID, Amount: -req.Amount},
+		},
+	}
+	// Adjustments reference themselves so the uniqueness guard never collapses two of them
+	entry.ReferenceID = &entry.ID
+
+	if err := s.ledgerRepo.Post(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// UserSummary returns a referrer's earned, paid out and outstanding amounts
+func (s *LedgerService) UserSummary(ctx context.Context, userID uuid.UUID) (*models.LedgerSummary, error) {
+	return s.ledgerRepo.UserSummary(ctx, userID)
+}
+
+// PlatformSummary returns the same figures aggregated across all referrers
+func (s *LedgerService) PlatformSummary(ctx context.Context) (*models.LedgerSummary, error) {
+	return s.ledgerRepo.PlatformSummary(ctx)
+}
+
+func (s *LedgerService) clawBack(ctx context.Context, tx pgx.Tx, referral *models.Referral, actor uuid.UUID) error {
+	entry := &models.LedgerEntry{
+		Kind:          models.LedgerEntryClawback,
+		Description:   fmt.Sprintf("Commission reversed for %s (%s)", referral.ReferredName, referral.Course),
+		ReferenceType: "referral",
+		ReferenceID:   &referral.ID,
+		CreatedBy:     &actor,
+		Postings: []models.LedgerPosting{
+			{UserID: referral.ReferrerID, Amount: referral.Earnings},
+			{AccountCode: models.LedgerAccountCommissionExpense, Amount: -referral.Earnings},
+		},
+	}
+	return s.post(ctx, tx, entry)
+}
+
+func (s *LedgerService) recordManualPayment(ctx context.Context, tx pgx.Tx, referral *models.Referral, actor uuid.UUID) error {
+	entry := &models.LedgerEntry{
+		Kind:          models.LedgerEntryPayout,
+		Description:   fmt.Sprintf("Commission paid for %s (%s)", referral.ReferredName, referral.Course),
+		ReferenceType: "referral",
+		ReferenceID:   &referral.ID,
+		CreatedBy:     &actor,
+		Postings: []models.LedgerPosting{
+			{UserID: referral.ReferrerID, Amount: referral.Earnings},
+			{AccountCode: models.LedgerAccountCash, Amount: -referral.Earnings},
+		},
+	}
+	return s.post(ctx, tx, entry)
+}
+
+// post records an entry, treating an already-recorded entry as success so
+// that replays of the same event are harmless.
+func (s *LedgerService) post(ctx context.Context, tx pgx.Tx, entry *models.LedgerEntry) error {
+	err := s.ledgerRepo.PostTx(ctx, tx, entry)
+	if errors.Is(err, repository.ErrLedgerEntryExists) {
+		return nil
+	}
+	return err
+}
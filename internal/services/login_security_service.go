@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/cache"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+)
+
+var (
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+)
+
+// LoginThrottledError is returned while an account is backing off or locked
+// after failed logins. It matches ErrTooManyLoginAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s; try again in %s", ErrTooManyLoginAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// Failed login policy. The first few failures are free; after that each one
+// doubles the wait before the next attempt, and enough of them lock the
+// account for a while. Failures are forgotten after failureWindow.
+const (
+	freeLoginFailures = 3
+	maxLoginFailures  = 10
+	loginBackoffBase  = time.Second
+	loginLockout      = 15 * time.Minute
+	failureWindow     = 15 * time.Minute
+)
+
+// LoginSecurityService slows down password guessing against an account and
+// keeps its sign-in history. Counters live in Redis and are keyed by email so
+// attempts from many IPs add up; if Redis is unavailable logins are not
+// throttled.
+type LoginSecurityService struct {
+	cache        *cache.Cache
+	historyRepo  *repository.LoginHistoryRepository
+	emailService *EmailService
+}
+
+func NewLoginSecurityService(cache *cache.Cache, historyRepo *repository.LoginHistoryRepository, emailService *EmailService) *LoginSecurityService {
+	return &LoginSecurityService{
+		cache:        cache,
+		historyRepo:  historyRepo,
+		emailService: emailService,
+	}
+}
+
+// Check returns a *LoginThrottledError if the account may not attempt to log
+// in yet
+func (s *LoginSecurityService) Check(ctx context.Context, email string) error {
+	if s.cache == nil {
+		return nil
+	}
+
+	for _, key := range []string{loginLockKey(email), loginBackoffKey(email)} {
+		ttl, err := s.cache.TTL(ctx, key)
+		if err == nil && ttl > 0 {
+			return &LoginThrottledError{RetryAfter: ttl}
+		}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt against email and, for an existing
+// user, adds it to their login history
+func (s *LoginSecurityService) RecordFailure(ctx context.Context, email string, user *models.User, client models.ClientInfo, reason string) {
+	if s.cache != nil {
+		s.countFailure(ctx, email)
+	}
+
+	if user != nil {
+		s.record(ctx, &models.LoginEvent{
+			UserID:        user.ID,
+			IPAddress:     client.IPAddress,
+			UserAgent:     client.UserAgent,
+			FailureReason: &reason,
+		})
+	}
+}
+
+// RecordSuccess clears the account's failure count, adds the sign-in to its
+// history and emails the user if it came from a device they have not signed
+// in from before
+func (s *LoginSecurityService) RecordSuccess(ctx context.Context, user *models.User, client models.ClientInfo) {
+	if s.cache != nil {
+		_ = s.cache.Delete(ctx, loginFailuresKey(user.Email))
+		_ = s.cache.Delete(ctx, loginBackoffKey(user.Email))
+	}
+
+	if s.historyRepo == nil {
+		return
+	}
+
+	// The very first sign-in is from a new device by definition, so only
+	// later ones are worth an alert
+	newDevice := false
+	if seen, err := s.historyRepo.HasSucceeded(ctx, user.ID); err == nil && seen {
+		known, err := s.historyRepo.HasSucceededFrom(ctx, user.ID, client.IPAddress, client.UserAgent)
+		newDevice = err == nil && !known
+	}
+
+	event := &models.LoginEvent{
+		UserID:    user.ID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Success:   true,
+	}
+	s.record(ctx, event)
+
+	if newDevice && s.emailService != nil {
+		go s.emailService.SendNewSignInEmail(user.Email, user.Name, client.IPAddress, client.UserAgent, event.CreatedAt)
+	}
+}
+
+func (s *LoginSecurityService) countFailure(ctx context.Context, email string) {
+	failures, err := s.cache.Incr(ctx, loginFailuresKey(email))
+	if err != nil {
+		log.Printf("failed to count login failure: %v", err)
+		return
+	}
+	if failures == 1 {
+		_ = s.cache.Expire(ctx, loginFailuresKey(email), failureWindow)
+	}
+
+	backoff, lock := loginPenalty(failures)
+	switch {
+	case lock:
+		_ = s.cache.Set(ctx, loginLockKey(email), "1", loginLockout)
+		_ = s.cache.Delete(ctx, loginFailuresKey(email))
+		_ = s.cache.Delete(ctx, loginBackoffKey(email))
+	case backoff > 0:
+		_ = s.cache.Set(ctx, loginBackoffKey(email), "1", backoff)
+	}
+}
+
+// loginPenalty returns how long to wait after the given number of
+// consecutive failures, or lock if the account should be locked
+func loginPenalty(failures int64) (backoff time.Duration, lock bool) {
+	if failures >= maxLoginFailures {
+		return 0, true
+	}
+	if failures <= freeLoginFailures {
+		return 0, false
+	}
+	return loginBackoffBase << (failures - freeLoginFailures - 1), false
+}
+
+func (s *LoginSecurityService) record(ctx context.Context, event *models.LoginEvent) {
+	if s.historyRepo == nil {
+		return
+	}
+	if err := s.historyRepo.Create(ctx, event); err != nil {
+		log.Printf("failed to record login for user %s: %v", event.UserID, err)
+	}
+}
+
+func loginFailuresKey(email string) string {
+	return "login:failures:" + strings.ToLower(email)
+}
+
+func loginBackoffKey(email string) string {
+	return "login:backoff:" + strings.ToLower(email)
+}
+
+func loginLockKey(email string) string {
+	return "login:locked:" + strings.ToLower(email)
+}
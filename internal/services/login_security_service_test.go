@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginPenalty(t *testing.T) {
+	tests := []struct {
+		failures    int64
+		wantBackoff time.Duration
+		wantLock    bool
+	}{
+		{1, 0, false},
+		{3, 0, false},
+		{4, time.Second, false},
+		{5, 2 * time.Second, false},
+		{9, 32 * time.Second, false},
+		{10, 0, true},
+		{12, 0, true},
+	}
+
+	for _, tt := range tests {
+		backoff, lock := loginPenalty(tt.failures)
+		assert.Equal(t, tt.wantBackoff, backoff, "failures=%d", tt.failures)
+		assert.Equal(t, tt.wantLock, lock, "failures=%d", tt.failures)
+	}
+}
+
+func TestLoginThrottledError(t *testing.T) {
+	var err error = &LoginThrottledError{RetryAfter: 90 * time.Second}
+
+	assert.True(t, errors.Is(err, ErrTooManyLoginAttempts))
+	assert.Contains(t, err.Error(), "1m30s")
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/cache"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/cirvee/referral-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotSetUp       = errors.New("two-factor authentication setup has not been started")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrMFAMandatory      = errors.New("two-factor authentication is mandatory for this account")
+)
+
+// recoveryCodeCount is how many single-use recovery codes a user gets when
+// enabling two-factor authentication
+const recoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAService manages TOTP two-factor authentication. Secrets are encrypted
+// at rest with key; recovery codes are stored as SHA-256 hashes.
+type MFAService struct {
+	mfaRepo *repository.MFARepository
+	cache   *cache.Cache
+	key     []byte
+	issuer  string
+}
+
+// NewMFAService creates the MFA service. With a nil cache login challenges
+// are not tracked, so each can be used until it expires.
+func NewMFAService(mfaRepo *repository.MFARepository, cache *cache.Cache, key []byte, issuer string) *MFAService {
+	return &MFAService{
+		mfaRepo: mfaRepo,
+		cache:   cache,
+		key:     key,
+		issuer:  issuer,
+	}
+}
+
+// Enabled reports whether a user must pass a second factor to log in
+func (s *MFAService) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	state, err := s.mfaRepo.GetState(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return state.EnabledAt != nil, nil
+}
+
+// Setup starts enrolment by generating a new secret. It only takes effect
+// once confirmed with Enable; calling Setup again replaces the pending secret.
+func (s *MFAService) Setup(ctx context.Context, userID uuid.UUID, email string) (*models.MFASetupResponse, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := utils.Encrypt(s.key, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SetPendingSecret(ctx, userID, encrypted); err != nil {
+		return nil, err
+	}
+
+	return &models.MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.issuer, email, secret),
+	}, nil
+}
+
+// Enable confirms enrolment with a code from the authenticator app and
+// returns the user's recovery codes. They are only ever shown here.
+func (s *MFAService) Enable(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	state, err := s.mfaRepo.GetState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if state.SecretEncrypted == nil {
+		return nil, ErrMFANotSetUp
+	}
+
+	secret, err := utils.Decrypt(s.key, *state.SecretEncrypted)
+	if err != nil {
+		return nil, err
+	}
+	counter, ok := utils.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := s.mfaRepo.Enable(ctx, userID, counter, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking a current code.
+// Admins cannot turn it off.
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, role models.Role, code string) error {
+	if role == models.RoleAdmin {
+		return ErrMFAMandatory
+	}
+
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.Disable(ctx, userID)
+}
+
+// Verify checks a second factor. code may be a current authenticator code not
+// used before, or an unused recovery code. Either is used up.
+func (s *MFAService) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	state, err := s.mfaRepo.GetState(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if state.EnabledAt == nil || state.SecretEncrypted == nil {
+		return ErrMFANotEnabled
+	}
+
+	secret, err := utils.Decrypt(s.key, *state.SecretEncrypted)
+	if err != nil {
+		return err
+	}
+	if counter, ok := utils.MatchTOTP(secret, code, time.Now()); ok {
+		used, err := s.mfaRepo.UseTOTPCounter(ctx, userID, counter)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// UseChallenge marks the login challenge with tokenID (its jti) as used until
+// expiresAt, returning utils.ErrInvalidToken if it already was, so that each
+// challenge completes at most one login
+func (s *MFAService) UseChallenge(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return utils.ErrInvalidToken
+	}
+	if s.cache == nil {
+		return nil
+	}
+
+	claimed, err := s.cache.SetNX(ctx, mfaChallengeKey(tokenID), "1", max(time.Until(expiresAt), time.Second))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return utils.ErrInvalidToken
+	}
+	return nil
+}
+
+func mfaChallengeKey(tokenID string) string {
+	return "mfa:challenge:" + tokenID
+}
+
+// generateRecoveryCode returns a code like "ABCDE-FGHIJ"
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := recoveryCodeEncoding.EncodeToString(b)[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case, spaces and dashes
+// so users can type it however it was written down
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrMissingBankDetails  = errors.New("bank details are required to request a payout")
+	ErrInsufficientBalance = errors.New("payout amount exceeds available earnings")
+	ErrInvalidPayoutStatus = errors.New("payout status must be approved or rejected")
+	ErrUnsupportedBank     = errors.New("referrer's bank is not supported for transfers")
+	ErrTransferFailed      = errors.New("payout transfer could not be initiated")
+	ErrBankChangeHold      = errors.New("payouts are on hold after a recent bank detail change")
+	ErrBankDetailsChanged  = errors.New("referrer's bank details changed during approval; try again")
+	ErrNoTransfer          = errors.New("payout has no transfer")
+	ErrTransferProvider    = errors.New("payout transfer was sent with a different provider")
+	ErrTransferRejected    = errors.New("payout transfer was refused by the provider; the payout has been marked failed")
+	ErrTransferUnconfirmed = errors.New("payout approved but its transfer could not be confirmed; sync the transfer to retry")
+)
+
+type PayoutService struct {
+	payoutRepo   *repository.PayoutRepository
+	referralRepo *repository.ReferralRepository
+	userRepo     *repository.UserRepository
+	ledger       *LedgerService
+	provider     payouts.PayoutProvider
+}
+
+func NewPayoutService(
+	payoutRepo *repository.PayoutRepository,
+	referralRepo *repository.ReferralRepository,
+	userRepo *repository.UserRepository,
+	ledger *LedgerService,
+	provider payouts.PayoutProvider,
+) *PayoutService {
+	return &PayoutService{
+		payoutRepo:   payoutRepo,
+		referralRepo: referralRepo,
+		userRepo:     userRepo,
+		ledger:       ledger,
+		provider:     provider,
+	}
+}
+
+// AvailableBalance returns the earnings a referrer can currently withdraw:
+// pending referral earnings, capped by the ledger balance so that clawbacks
+// and negative adjustments are taken into account.
+func (s *PayoutService) AvailableBalance(ctx context.Context, userID uuid.UUID) (int64, error) {
+	_, _, pendingEarnings, err := s.referralRepo.GetStatsByReferrer(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	summary, err := s.ledger.UserSummary(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(0, min(pendingEarnings, summary.Balance)), nil
+}
+
+// RequestPayout creates a pending withdrawal for a referrer after checking
+// bank details and available earnings. Only one pending payout may exist.
+// The payout reserves whole referrals, so its amount is the largest total of
+// pending referral earnings that does not exceed the requested amount.
+func (s *PayoutService) RequestPayout(ctx context.Context, userID uuid.UUID, amount int64) (*models.Payout, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.BankName == "" || user.AccountNumber == "" || user.AccountName == "" {
+		return nil, ErrMissingBankDetails
+	}
+
+	available, err := s.AvailableBalance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > available {
+		return nil, ErrInsufficientBalance
+	}
+
+	payout := &models.Payout{
+		ID:     uuid.New(),
+		UserID: userID,
+		Amount: amount,
+		Status: models.PayoutStatusPending,
+	}
+
+	if err := s.payoutRepo.CreateWithReferrals(ctx, payout, amount); err != nil {
+		if errors.Is(err, repository.ErrNoEligibleReferrals) {
+			return nil, ErrInsufficientBalance
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// UpdateStatus approves or rejects a pending payout on behalf of an admin.
+// When a payout provider is configured, approval also sends a transfer to the
+// referrer's bank account. The approval and the transfer's reference are
+// committed before the provider is called, so money is never sent for an
+// approval that did not happen. A transfer the provider refuses unwinds the
+// payout and returns ErrTransferRejected; one whose outcome is unknown leaves
+// the payout approved and returns ErrTransferUnconfirmed, to be settled by
+// SyncTransfer. Approval is refused while the referrer's bank details are
+// held after a change.
+func (s *PayoutService) UpdateStatus(ctx context.Context, payoutID uuid.UUID, status models.PayoutStatus, adminID uuid.UUID) error {
+	switch status {
+	case models.PayoutStatusApproved:
+		payout, err := s.payoutRepo.GetByID(ctx, payoutID)
+		if err != nil {
+			return err
+		}
+		if payout.Status != models.PayoutStatusPending {
+			return repository.ErrPayoutNotPending
+		}
+		if err := s.checkBankHold(ctx, payout.UserID); err != nil {
+			return err
+		}
+
+		settle := s.ledger.SettlePayout(adminID)
+		if !s.transfersEnabled() {
+			return s.payoutRepo.Approve(ctx, payoutID, adminID, func(ctx context.Context, tx pgx.Tx, approved *models.Payout) error {
+				if err := s.lockBankDetails(ctx, tx, approved.UserID, nil); err != nil {
+					return err
+				}
+				return settle(ctx, tx, approved)
+			})
+		}
+
+		recipient, err := s.transferRecipient(ctx, payout.UserID)
+		if err != nil {
+			return err
+		}
+
+		err = s.payoutRepo.Approve(ctx, payoutID, adminID, func(ctx context.Context, tx pgx.Tx, approved *models.Payout) error {
+			if err := s.lockBankDetails(ctx, tx, approved.UserID, recipient); err != nil {
+				return err
+			}
+			if err := settle(ctx, tx, approved); err != nil {
+				return err
+			}
+
+			provider, reference, transferStatus := s.provider.Name(), approved.ID.String(), payouts.TransferPending
+			approved.TransferProvider = &provider
+			approved.TransferReference = &reference
+			approved.TransferStatus = &transferStatus
+			payout = approved
+			return s.payoutRepo.RecordTransferTx(ctx, tx, approved)
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.disburse(ctx, payout, recipient)
+	case models.PayoutStatusRejected:
+		return s.reject(ctx, payoutID, adminID)
+	default:
+		return ErrInvalidPayoutStatus
+	}
+}
+
+// GetPayout returns a payout together with the referrals it covers
+func (s *PayoutService) GetPayout(ctx context.Context, payoutID uuid.UUID) (*models.Payout, error) {
+	payout, err := s.payoutRepo.GetByID(ctx, payoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.payoutRepo.ListItems(ctx, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	payout.Items = items
+
+	return payout, nil
+}
+
+// checkBankHold refuses to approve a payout while the referrer's bank
+// details are in their post-change cooldown
+func (s *PayoutService) checkBankHold(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return bankHold(user)
+}
+
+// lockBankDetails repeats checkBankHold inside an approval, with the
+// referrer's row locked, so a bank change confirmed since the first check
+// rolls the approval back. When a transfer is to be sent, the bank details
+// recipient was built from must also be unchanged.
+func (s *PayoutService) lockBankDetails(ctx context.Context, tx pgx.Tx, userID uuid.UUID, recipient *payouts.TransferRequest) error {
+	user, err := s.userRepo.GetBankDetailsTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bankHold(user); err != nil {
+		return err
+	}
+
+	if recipient != nil && (user.AccountNumber != recipient.AccountNumber || user.AccountName != recipient.AccountName ||
+		(user.BankCode != "" && user.BankCode != recipient.BankCode)) {
+		return ErrBankDetailsChanged
+	}
+	return nil
+}
+
+// bankHold returns ErrBankChangeHold while user's bank details are held
+func bankHold(user *models.User) error {
+	if user.BankHoldUntil != nil && time.Now().Before(*user.BankHoldUntil) {
+		return fmt.Errorf("%w until %s", ErrBankChangeHold, user.BankHoldUntil.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// reject rejects a pending payout. A payout that somehow carries a transfer
+// may only be rejected once the provider confirms the transfer failed, so
+// its status is fetched first.
+func (s *PayoutService) reject(ctx context.Context, payoutID uuid.UUID, adminID uuid.UUID) error {
+	payout, err := s.payoutRepo.GetByID(ctx, payoutID)
+	if err != nil {
+		return err
+	}
+
+	if payout.Status == models.PayoutStatusPending && payout.TransferReference != nil && s.transfersEnabled() &&
+		payout.TransferProvider != nil && *payout.TransferProvider == s.provider.Name() {
+		code := ""
+		if payout.TransferCode != nil {
+			code = *payout.TransferCode
+		}
+		transfer, err := s.provider.TransferStatus(ctx, *payout.TransferReference, code)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrTransferFailed, err)
+		}
+
+		err = s.payoutRepo.UpdateTransfer(ctx, payoutID, func(ctx context.Context, tx pgx.Tx, locked *models.Payout) error {
+			return applyTransferStatus(ctx, tx, s.payoutRepo, s.ledger, locked, transfer.Status)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.payoutRepo.Reject(ctx, payoutID, adminID)
+}
+
+// SyncTransfer asks the payout provider for the current status of a payout's
+// transfer and applies it, for transfers whose outcome was never reported.
+// A transfer that was never confirmed as sent is sent again; its reference
+// stops the provider paying twice.
+func (s *PayoutService) SyncTransfer(ctx context.Context, payoutID uuid.UUID) (*models.Payout, error) {
+	payout, err := s.payoutRepo.GetByID(ctx, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	if payout.TransferReference == nil {
+		return nil, ErrNoTransfer
+	}
+	if !s.transfersEnabled() || payout.TransferProvider == nil || *payout.TransferProvider != s.provider.Name() {
+		return nil, ErrTransferProvider
+	}
+
+	if payout.Status == models.PayoutStatusApproved && payout.TransferCode == nil {
+		if err := s.checkBankHold(ctx, payout.UserID); err != nil {
+			return nil, err
+		}
+		recipient, err := s.transferRecipient(ctx, payout.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.disburse(ctx, payout, recipient); err != nil {
+			return nil, err
+		}
+		return s.payoutRepo.GetByID(ctx, payoutID)
+	}
+
+	code := ""
+	if payout.TransferCode != nil {
+		code = *payout.TransferCode
+	}
+	transfer, err := s.provider.TransferStatus(ctx, *payout.TransferReference, code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTransferFailed, err)
+	}
+
+	err = s.payoutRepo.UpdateTransfer(ctx, payoutID, func(ctx context.Context, tx pgx.Tx, locked *models.Payout) error {
+		payout = locked
+		return applyTransferStatus(ctx, tx, s.payoutRepo, s.ledger, payout, transfer.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// applyTransferStatus records the latest status of a payout's transfer.
+// Failed and reversed transfers put the payout's referrals back to pending
+// and credit the referrer's ledger balance so the money can be withdrawn
+// again.
+func applyTransferStatus(ctx context.Context, tx pgx.Tx, payoutRepo *repository.PayoutRepository, ledger *LedgerService, payout *models.Payout, status string) error {
+	payout.TransferStatus = &status
+
+	var unwindTo models.PayoutStatus
+	switch status {
+	case payouts.TransferFailed:
+		unwindTo = models.PayoutStatusFailed
+	case payouts.TransferReversed:
+		unwindTo = models.PayoutStatusReversed
+	}
+
+	if unwindTo == "" || payout.Status != models.PayoutStatusApproved {
+		return payoutRepo.RecordTransferTx(ctx, tx, payout)
+	}
+
+	if err := payoutRepo.UnwindTx(ctx, tx, payout, unwindTo); err != nil {
+		return err
+	}
+
+	return ledger.ReversePayout(ctx, tx, payout)
+}
+
+// transfersEnabled reports whether approvals move money
+func (s *PayoutService) transfersEnabled() bool {
+	return s.provider != nil && s.provider.Enabled()
+}
+
+// transferRecipient returns a referrer as a transfer destination, creating
+// and caching a recipient with the payout provider from their bank details if
+// needed.
+func (s *PayoutService) transferRecipient(ctx context.Context, userID uuid.UUID) (*payouts.TransferRequest, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.BankName == "" || user.AccountNumber == "" || user.AccountName == "" {
+		return nil, ErrMissingBankDetails
+	}
+
+	// Accounts set before bank verification only have a bank name
+	bankCode := user.BankCode
+	if bankCode == "" {
+		bankCode, err = payouts.BankCodeByName(ctx, s.provider, user.BankName)
+	}
+	if err != nil {
+		if errors.Is(err, payouts.ErrBankNotFound) {
+			return nil, ErrUnsupportedBank
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTransferFailed, err)
+	}
+
+	recipient := &payouts.TransferRequest{
+		AccountNumber: user.AccountNumber,
+		AccountName:   user.AccountName,
+		BankCode:      bankCode,
+	}
+
+	recipient.RecipientCode, err = s.userRepo.GetPayoutRecipient(ctx, user.ID, s.provider.Name())
+	if err != nil || recipient.RecipientCode != "" {
+		return recipient, err
+	}
+
+	recipient.RecipientCode, err = s.provider.CreateRecipient(ctx, user.AccountName, user.AccountNumber, bankCode)
+	if err != nil {
+		if errors.Is(err, payouts.ErrBankNotFound) {
+			return nil, ErrUnsupportedBank
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTransferFailed, err)
+	}
+
+	if err := s.userRepo.SetPayoutRecipient(ctx, user.ID, s.provider.Name(), recipient.RecipientCode); err != nil {
+		return nil, err
+	}
+
+	return recipient, nil
+}
+
+// disburse sends the transfer for an approved payout, whose reference was
+// recorded with the approval, and records the outcome. The payout ID is the
+// reference, so a transfer sent again can never pay twice.
+func (s *PayoutService) disburse(ctx context.Context, payout *models.Payout, recipient *payouts.TransferRequest) error {
+	req := *recipient
+	req.Amount = payout.Amount * 100 // naira to kobo
+	req.Reference = *payout.TransferReference
+	req.Reason = "Cirvee referral payout"
+
+	code := ""
+	if payout.TransferCode != nil {
+		code = *payout.TransferCode
+	}
+	transfer, sendErr := sendTransfer(ctx, s.provider, &req, code)
+	if sendErr != nil && !errors.Is(sendErr, ErrTransferRejected) {
+		return sendErr
+	}
+
+	status := payouts.TransferFailed
+	if transfer != nil {
+		status, code = transfer.Status, transfer.Code
+	}
+
+	err := s.payoutRepo.UpdateTransfer(ctx, payout.ID, func(ctx context.Context, tx pgx.Tx, locked *models.Payout) error {
+		if code != "" {
+			locked.TransferCode = &code
+		}
+		if locked.TransferStatus != nil && *locked.TransferStatus != payouts.TransferPending {
+			// A webhook reported the outcome before the provider's response
+			// arrived, so only the code is missing
+			return s.payoutRepo.RecordTransferTx(ctx, tx, locked)
+		}
+		return applyTransferStatus(ctx, tx, s.payoutRepo, s.ledger, locked, status)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendErr
+}
+
+// sendTransfer initiates req with provider. A failed call may still have
+// reached the provider: the response may have timed out after the transfer
+// was accepted, or an earlier attempt may already have sent it, so the
+// reference is now rejected as a duplicate. The transfer is therefore looked
+// up by its reference, and code if known, before the failure is reported.
+// Only a refusal the lookup confirms is reported as ErrTransferRejected;
+// anything else is ErrTransferUnconfirmed.
+func sendTransfer(ctx context.Context, provider payouts.PayoutProvider, req *payouts.TransferRequest, code string) (*payouts.Transfer, error) {
+	transfer, err := provider.Transfer(ctx, req)
+	if err == nil {
+		return transfer, nil
+	}
+
+	sent, lookupErr := provider.TransferStatus(ctx, req.Reference, code)
+	if lookupErr == nil {
+		return sent, nil
+	}
+	if payouts.Refused(err) && errors.Is(lookupErr, payouts.ErrTransferNotFound) {
+		return nil, fmt.Errorf("%w: %v", ErrTransferRejected, err)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrTransferUnconfirmed, err)
+}
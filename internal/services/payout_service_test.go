@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cirvee/referral-backend/internal/config"
+	"github.com/cirvee/referral-backend/internal/flutterwave"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableLookups is a provider whose transfer lookups time out
+type unreachableLookups struct {
+	*payouts.Fake
+}
+
+func (unreachableLookups) TransferStatus(ctx context.Context, reference, code string) (*payouts.Transfer, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestSendTransfer(t *testing.T) {
+	ctx := context.Background()
+	provider := payouts.NewFake()
+	req := &payouts.TransferRequest{Amount: 1000000, RecipientCode: "RCP_1", Reference: "payout-1"}
+
+	// The provider accepts the transfer but the response times out
+	provider.LoseNextResponse(context.DeadlineExceeded)
+	transfer, err := sendTransfer(ctx, provider, req, "")
+	require.NoError(t, err)
+	assert.Equal(t, "payout-1", transfer.Reference)
+	assert.Equal(t, payouts.TransferPending, transfer.Status)
+
+	// A resent transfer is refused as a duplicate and adopts the first one
+	require.NoError(t, provider.SetTransferStatus("payout-1", payouts.TransferSuccess))
+	transfer, err = sendTransfer(ctx, provider, req, "")
+	require.NoError(t, err)
+	assert.Equal(t, payouts.TransferSuccess, transfer.Status)
+	assert.Len(t, provider.Transfers(), 1, "the money is only sent once")
+
+	// A transfer the provider refused, and does not know, is rejected
+	provider.RefuseNextTransfer("insufficient balance")
+	_, err = sendTransfer(ctx, provider, &payouts.TransferRequest{Amount: 1000000, Reference: "payout-2"}, "")
+	assert.ErrorIs(t, err, ErrTransferRejected)
+
+	// Without a lookup the outcome of a lost response is unknown
+	lost := unreachableLookups{payouts.NewFake()}
+	lost.LoseNextResponse(context.DeadlineExceeded)
+	_, err = sendTransfer(ctx, lost, &payouts.TransferRequest{Amount: 1000000, Reference: "payout-3"}, "")
+	assert.ErrorIs(t, err, ErrTransferUnconfirmed)
+
+	// So is a refusal the lookup cannot confirm
+	lost.RefuseNextTransfer("insufficient balance")
+	_, err = sendTransfer(ctx, lost, &payouts.TransferRequest{Amount: 1000000, Reference: "payout-4"}, "")
+	assert.ErrorIs(t, err, ErrTransferUnconfirmed)
+}
+
+func TestSendTransfer_Flutterwave(t *testing.T) {
+	var mu sync.Mutex
+	sent := make(map[string]flutterwave.Transfer)
+
+	// Flutterwave queues transfers but is too slow to answer, and refuses
+	// the ones marked for refusal
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v3/transfers":
+			var req flutterwave.TransferRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if req.Narration == "refuse" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"status": "error", "message": "Insufficient balance"})
+				return
+			}
+			mu.Lock()
+			sent[req.Reference] = flutterwave.Transfer{ID: int64(396456 + len(sent)), Reference: req.Reference, Status: "NEW"}
+			mu.Unlock()
+			<-r.Context().Done()
+		case "GET /v3/transfers":
+			mu.Lock()
+			transfers := []flutterwave.Transfer{}
+			if transfer, ok := sent[r.URL.Query().Get("reference")]; ok {
+				transfers = append(transfers, transfer)
+			}
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": transfers})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	provider := payouts.NewFlutterwave(flutterwave.NewClient(&config.FlutterwaveConfig{
+		SecretKey: "FLWSECK_TEST-secret", BaseURL: server.URL, Timeout: 50 * time.Millisecond,
+	}))
+	ctx := context.Background()
+
+	// The transfer times out, so its ID is unknown and it is found by
+	// reference instead
+	transfer, err := sendTransfer(ctx, provider, &payouts.TransferRequest{Amount: 1000000, Reference: "payout-1"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, &payouts.Transfer{Reference: "payout-1", Code: "396456", Status: payouts.TransferPending}, transfer)
+
+	_, err = sendTransfer(ctx, provider, &payouts.TransferRequest{Amount: 1000000, Reference: "payout-2", Reason: "refuse"}, "")
+	assert.ErrorIs(t, err, ErrTransferRejected)
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrUnknownReferralStatus = errors.New("unknown referral status")
+	ErrIllegalTransition     = errors.New("illegal referral status transition")
+)
+
+// referralTransitions lists the status changes an admin may make. Processing
+// is entered and left only by the payout flow, and rejected and reversed are
+// final.
+var referralTransitions = map[string][]string{
+	models.ReferralStatusPending:  {models.ReferralStatusApproved, models.ReferralStatusRejected, models.ReferralStatusPaid},
+	models.ReferralStatusApproved: {models.ReferralStatusPaid, models.ReferralStatusRejected},
+	models.ReferralStatusPaid:     {models.ReferralStatusReversed},
+}
+
+var referralStatuses = map[string]bool{
+	models.ReferralStatusPending:    true,
+	models.ReferralStatusApproved:   true,
+	models.ReferralStatusProcessing: true,
+	models.ReferralStatusPaid:       true,
+	models.ReferralStatusRejected:   true,
+	models.ReferralStatusReversed:   true,
+}
+
+// CanTransition reports whether an admin may move a referral from one status
+// to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range referralTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+type ReferralService struct {
+	referralRepo *repository.ReferralRepository
+	ledger       *LedgerService
+}
+
+func NewReferralService(referralRepo *repository.ReferralRepository, ledger *LedgerService) *ReferralService {
+	return &ReferralService{
+		referralRepo: referralRepo,
+		ledger:       ledger,
+	}
+}
+
+// Transition moves a referral to a new status on behalf of an admin, applying
+// the ledger effect of the change in the same transaction. It returns the
+// status the referral had before.
+func (s *ReferralService) Transition(ctx context.Context, id uuid.UUID, to string, actor uuid.UUID) (string, error) {
+	if !referralStatuses[to] {
+		return "", ErrUnknownReferralStatus
+	}
+
+	ledgerHook := s.ledger.ReferralStatusChange(to, actor)
+
+	var from string
+	err := s.referralRepo.UpdateStatus(ctx, id, to, actor, func(ctx context.Context, tx pgx.Tx, referral *models.Referral) error {
+		from = referral.Status
+		if !CanTransition(referral.Status, to) {
+			return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, referral.Status, to)
+		}
+		return ledgerHook(ctx, tx, referral)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_ = s.referralRepo.InvalidateDashboardCache(ctx)
+	return from, nil
+}
+
+// MarkReferrerPaid marks every pending and approved referral of a referrer as
+// paid out of band
+func (s *ReferralService) MarkReferrerPaid(ctx context.Context, referrerID uuid.UUID, actor uuid.UUID) error {
+	if err := s.referralRepo.MarkReferralsAsPaid(ctx, referrerID, actor, s.ledger.ManualPayments(actor)); err != nil {
+		return err
+	}
+
+	_ = s.referralRepo.InvalidateDashboardCache(ctx)
+	return nil
+}
+
+// History returns who changed a referral's status and when
+func (s *ReferralService) History(ctx context.Context, id uuid.UUID) ([]models.ReferralStatusChange, error) {
+	if _, err := s.referralRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.referralRepo.ListStatusHistory(ctx, id)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.ReferralStatusPending, models.ReferralStatusApproved, true},
+		{models.ReferralStatusPending, models.ReferralStatusRejected, true},
+		{models.ReferralStatusPending, models.ReferralStatusPaid, true},
+		{models.ReferralStatusApproved, models.ReferralStatusPaid, true},
+		{models.ReferralStatusApproved, models.ReferralStatusRejected, true},
+		{models.ReferralStatusPaid, models.ReferralStatusReversed, true},
+
+		{models.ReferralStatusPaid, models.ReferralStatusPending, false},
+		{models.ReferralStatusPaid, models.ReferralStatusRejected, false},
+		{models.ReferralStatusApproved, models.ReferralStatusPending, false},
+		{models.ReferralStatusPending, models.ReferralStatusReversed, false},
+		{models.ReferralStatusPending, models.ReferralStatusPending, false},
+		{models.ReferralStatusPending, models.ReferralStatusProcessing, false},
+		{models.ReferralStatusProcessing, models.ReferralStatusPaid, false},
+		{models.ReferralStatusProcessing, models.ReferralStatusRejected, false},
+		{models.ReferralStatusRejected, models.ReferralStatusPending, false},
+		{models.ReferralStatusReversed, models.ReferralStatusPaid, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"_to_"+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestReferralService_Transition_UnknownStatus(t *testing.T) {
+	service := NewReferralService(nil, nil)
+
+	_, err := service.Transition(context.Background(), uuid.New(), "archived", uuid.New())
+	assert.ErrorIs(t, err, ErrUnknownReferralStatus)
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/cirvee/referral-backend/internal/flutterwave"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/cirvee/referral-backend/internal/paystack"
+	"github.com/cirvee/referral-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidWebhookPayload = errors.New("invalid webhook payload")
+)
+
+type WebhookService struct {
+	webhookRepo *repository.WebhookRepository
+	payoutRepo  *repository.PayoutRepository
+	ledger      *LedgerService
+}
+
+func NewWebhookService(
+	webhookRepo *repository.WebhookRepository,
+	payoutRepo *repository.PayoutRepository,
+	ledger *LedgerService,
+) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		payoutRepo:  payoutRepo,
+		ledger:      ledger,
+	}
+}
+
+// HandlePaystackEvent records a verified Paystack event and applies it.
+// Redelivered events are acknowledged without being applied again.
+func (s *WebhookService) HandlePaystackEvent(ctx context.Context, payload []byte) error {
+	var event paystack.Event
+	if err := json.Unmarshal(payload, &event); err != nil || event.Event == "" {
+		return ErrInvalidWebhookPayload
+	}
+
+	eventID, err := event.ID()
+	if err != nil {
+		return ErrInvalidWebhookPayload
+	}
+
+	record := &models.WebhookEvent{
+		Provider:  payouts.ProviderPaystack,
+		EventID:   eventID,
+		EventType: event.Event,
+		Payload:   payload,
+	}
+
+	err = s.webhookRepo.Record(ctx, record, func(ctx context.Context, tx pgx.Tx) error {
+		switch event.Event {
+		case paystack.EventTransferSuccess, paystack.EventTransferFailed, paystack.EventTransferReversed:
+			return s.applyTransferEvent(ctx, tx, &event)
+		}
+		// Charge and other events are stored for reference only
+		return nil
+	})
+	if errors.Is(err, repository.ErrDuplicateEvent) {
+		return nil
+	}
+	return err
+}
+
+// HandleFlutterwaveEvent records a verified Flutterwave event and applies
+// it. Redelivered events are acknowledged without being applied again.
+func (s *WebhookService) HandleFlutterwaveEvent(ctx context.Context, payload []byte) error {
+	var event flutterwave.Event
+	if err := json.Unmarshal(payload, &event); err != nil || event.Event == "" {
+		return ErrInvalidWebhookPayload
+	}
+
+	eventID, err := event.ID()
+	if err != nil {
+		return ErrInvalidWebhookPayload
+	}
+
+	record := &models.WebhookEvent{
+		Provider:  payouts.ProviderFlutterwave,
+		EventID:   eventID,
+		EventType: event.Event,
+		Payload:   payload,
+	}
+
+	err = s.webhookRepo.Record(ctx, record, func(ctx context.Context, tx pgx.Tx) error {
+		if event.Event != flutterwave.EventTransferCompleted {
+			// Other events are stored for reference only
+			return nil
+		}
+
+		var data flutterwave.TransferEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return ErrInvalidWebhookPayload
+		}
+		code := strconv.FormatInt(data.ID, 10)
+		return s.applyTransfer(ctx, tx, payouts.ProviderFlutterwave, data.Reference, code, payouts.FlutterwaveStatus(data.Status))
+	})
+	if errors.Is(err, repository.ErrDuplicateEvent) {
+		return nil
+	}
+	return err
+}
+
+// applyTransferEvent updates the payout behind a Paystack transfer
+func (s *WebhookService) applyTransferEvent(ctx context.Context, tx pgx.Tx, event *paystack.Event) error {
+	var data paystack.TransferEventData
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return ErrInvalidWebhookPayload
+	}
+
+	var status string
+	switch event.Event {
+	case paystack.EventTransferSuccess:
+		status = payouts.TransferSuccess
+	case paystack.EventTransferFailed:
+		status = payouts.TransferFailed
+	case paystack.EventTransferReversed:
+		status = payouts.TransferReversed
+	}
+
+	return s.applyTransfer(ctx, tx, payouts.ProviderPaystack, data.Reference, data.TransferCode, status)
+}
+
+// applyTransfer applies the status a provider reported for the transfer with
+// reference and code to the payout that sent it
+func (s *WebhookService) applyTransfer(ctx context.Context, tx pgx.Tx, provider, reference, code, status string) error {
+	// Transfers are sent with the payout ID as their reference, which is
+	// committed with the approval before the transfer is sent
+	payoutID, err := uuid.Parse(reference)
+	if err != nil {
+		// Transfers not initiated by this service are ignored
+		return nil
+	}
+
+	payout, err := s.payoutRepo.GetByIDTx(ctx, tx, payoutID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPayoutNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Only transfers sent through a provider are updated by its events
+	if payout.TransferReference == nil || *payout.TransferReference != reference ||
+		payout.TransferProvider == nil || *payout.TransferProvider != provider {
+		return nil
+	}
+
+	// The event may arrive before the response to the transfer call
+	if payout.TransferCode == nil && code != "" {
+		payout.TransferCode = &code
+	}
+
+	return applyTransferStatus(ctx, tx, s.payoutRepo, s.ledger, payout, status)
+}
@@ -18,13 +18,22 @@ type TokenType string
 const (
 	AccessToken  TokenType = "access"
 	RefreshToken TokenType = "refresh"
+	// ChallengeToken proves a password was checked and is exchanged, with a
+	// second factor, for an access and refresh token
+	ChallengeToken TokenType = "mfa_challenge"
 )
 
+// ChallengeExpiry is how long a user has to enter their second factor
+const ChallengeExpiry = 5 * time.Minute
+
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
-	Role   string    `json:"role"`
-	Type   TokenType `json:"type"`
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	Type         TokenType `json:"type"`
+	TokenVersion int       `json:"token_version,omitempty"`
+	// MFA is set on tokens issued after a second factor was checked
+	MFA bool `json:"mfa,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -44,12 +53,17 @@ func NewJWTManager(accessSecret, refreshSecret string, accessExpiry, refreshExpi
 	}
 }
 
-func (j *JWTManager) GenerateAccessToken(userID uuid.UUID, email, role string) (string, error) {
+// GenerateAccessToken signs an access token. tokenVersion is the user's token
+// version at issue time; the token stops being accepted once it is bumped.
+// mfa records that the session was opened with a second factor.
+func (j *JWTManager) GenerateAccessToken(userID uuid.UUID, email, role string, tokenVersion int, mfa bool) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		Type:   AccessToken,
+		UserID:       userID,
+		Email:        email,
+		Role:         role,
+		Type:         AccessToken,
+		TokenVersion: tokenVersion,
+		MFA:          mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -61,13 +75,18 @@ func (j *JWTManager) GenerateAccessToken(userID uuid.UUID, email, role string) (
 	return token.SignedString(j.accessSecret)
 }
 
-func (j *JWTManager) GenerateRefreshToken(userID uuid.UUID, email, role string) (string, error) {
+// GenerateRefreshToken signs a refresh token carrying tokenID as its jti so
+// the server can track and revoke it. mfa is carried over to the access
+// tokens it is exchanged for.
+func (j *JWTManager) GenerateRefreshToken(userID uuid.UUID, email, role string, tokenID uuid.UUID, mfa bool) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
 		Role:   role,
 		Type:   RefreshToken,
+		MFA:    mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.refreshExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "cirvee-referral",
@@ -78,6 +97,32 @@ func (j *JWTManager) GenerateRefreshToken(userID uuid.UUID, email, role string)
 	return token.SignedString(j.refreshSecret)
 }
 
+// GenerateChallengeToken signs a short-lived token issued after a correct
+// password for a user who must also pass a second factor. It carries a random
+// jti so that it can be used only once.
+func (j *JWTManager) GenerateChallengeToken(userID uuid.UUID, email, role string) (string, error) {
+	claims := &Claims{
+		UserID: userID,
+		Email:  email,
+		Role:   role,
+		Type:   ChallengeToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ChallengeExpiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "cirvee-referral",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(j.accessSecret)
+}
+
+// RefreshExpiry is how long a refresh token stays valid
+func (j *JWTManager) RefreshExpiry() time.Duration {
+	return j.refreshExpiry
+}
+
 func (j *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
 	return j.validateToken(tokenString, j.accessSecret, AccessToken)
 }
@@ -86,6 +131,10 @@ func (j *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
 	return j.validateToken(tokenString, j.refreshSecret, RefreshToken)
 }
 
+func (j *JWTManager) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return j.validateToken(tokenString, j.accessSecret, ChallengeToken)
+}
+
 func (j *JWTManager) validateToken(tokenString string, secret []byte, expectedType TokenType) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
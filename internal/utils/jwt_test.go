@@ -11,7 +11,7 @@ func TestJWTManager_GenerateAccessToken(t *testing.T) {
 	jm := NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, 7*24*time.Hour)
 	userID := uuid.New()
 
-	token, err := jm.GenerateAccessToken(userID, "test@example.com", "user")
+	token, err := jm.GenerateAccessToken(userID, "test@example.com", "user", 0, false)
 	if err != nil {
 		t.Fatalf("GenerateAccessToken() error = %v", err)
 	}
@@ -27,7 +27,7 @@ func TestJWTManager_ValidateAccessToken(t *testing.T) {
 	email := "test@example.com"
 	role := "admin"
 
-	token, _ := jm.GenerateAccessToken(userID, email, role)
+	token, _ := jm.GenerateAccessToken(userID, email, role, 3, false)
 
 	claims, err := jm.ValidateAccessToken(token)
 	if err != nil {
@@ -46,13 +46,18 @@ func TestJWTManager_ValidateAccessToken(t *testing.T) {
 	if claims.Type != AccessToken {
 		t.Errorf("Type = %v, want %v", claims.Type, AccessToken)
 	}
+	if claims.TokenVersion != 3 {
+		t.Errorf("TokenVersion = %v, want %v", claims.TokenVersion, 3)
+	}
 }
 
 func TestJWTManager_ValidateRefreshToken(t *testing.T) {
 	jm := NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, 7*24*time.Hour)
 	userID := uuid.New()
 
-	token, _ := jm.GenerateRefreshToken(userID, "test@example.com", "user")
+	tokenID := uuid.New()
+
+	token, _ := jm.GenerateRefreshToken(userID, "test@example.com", "user", tokenID, false)
 
 	claims, err := jm.ValidateRefreshToken(token)
 	if err != nil {
@@ -62,6 +67,9 @@ func TestJWTManager_ValidateRefreshToken(t *testing.T) {
 	if claims.Type != RefreshToken {
 		t.Errorf("Type = %v, want %v", claims.Type, RefreshToken)
 	}
+	if claims.ID != tokenID.String() {
+		t.Errorf("ID = %v, want %v", claims.ID, tokenID)
+	}
 }
 
 func TestJWTManager_InvalidToken(t *testing.T) {
@@ -91,7 +99,7 @@ func TestJWTManager_ExpiredToken(t *testing.T) {
 	jm := NewJWTManager("test-access-secret", "test-refresh-secret", 1*time.Millisecond, 7*24*time.Hour)
 	userID := uuid.New()
 
-	token, _ := jm.GenerateAccessToken(userID, "test@example.com", "user")
+	token, _ := jm.GenerateAccessToken(userID, "test@example.com", "user", 0, false)
 
 	// Wait for token to expire
 	time.Sleep(10 * time.Millisecond)
@@ -107,7 +115,7 @@ func TestJWTManager_WrongTokenType(t *testing.T) {
 	userID := uuid.New()
 
 	// Generate refresh token but try to validate as access token
-	refreshToken, _ := jm.GenerateRefreshToken(userID, "test@example.com", "user")
+	refreshToken, _ := jm.GenerateRefreshToken(userID, "test@example.com", "user", uuid.New(), false)
 	
 	_, err := jm.ValidateAccessToken(refreshToken)
 	if err != ErrInvalidToken {
@@ -115,7 +123,7 @@ func TestJWTManager_WrongTokenType(t *testing.T) {
 	}
 
 	// Generate access token but try to validate as refresh token
-	accessToken, _ := jm.GenerateAccessToken(userID, "test@example.com", "user")
+	accessToken, _ := jm.GenerateAccessToken(userID, "test@example.com", "user", 0, false)
 	
 	_, err = jm.ValidateRefreshToken(accessToken)
 	if err != ErrInvalidToken {
@@ -128,10 +136,36 @@ func TestJWTManager_WrongSecret(t *testing.T) {
 	jm2 := NewJWTManager("secret-2", "refresh-2", 15*time.Minute, 7*24*time.Hour)
 	userID := uuid.New()
 
-	token, _ := jm1.GenerateAccessToken(userID, "test@example.com", "user")
+	token, _ := jm1.GenerateAccessToken(userID, "test@example.com", "user", 0, false)
 	
 	_, err := jm2.ValidateAccessToken(token)
 	if err != ErrInvalidToken {
 		t.Errorf("ValidateAccessToken() with wrong secret should return ErrInvalidToken, got %v", err)
 	}
 }
+
+func TestJWTManager_ChallengeToken(t *testing.T) {
+	jm := NewJWTManager("test-access-secret", "test-refresh-secret", 15*time.Minute, 7*24*time.Hour)
+	userID := uuid.New()
+
+	token, err := jm.GenerateChallengeToken(userID, "admin@example.com", "admin")
+	if err != nil {
+		t.Fatalf("GenerateChallengeToken() error = %v", err)
+	}
+
+	claims, err := jm.ValidateChallengeToken(token)
+	if err != nil {
+		t.Fatalf("ValidateChallengeToken() error = %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("UserID = %v, want %v", claims.UserID, userID)
+	}
+	if claims.ID == "" {
+		t.Error("challenge token should carry a jti so it can be used once")
+	}
+
+	// A challenge token is not an access token
+	if _, err := jm.ValidateAccessToken(token); err != ErrInvalidToken {
+		t.Errorf("ValidateAccessToken() with challenge token should return ErrInvalidToken, got %v", err)
+	}
+}
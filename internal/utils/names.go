@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// nameTitles are dropped before comparing names, since banks include them
+// inconsistently
+var nameTitles = map[string]bool{
+	"mr": true, "mrs": true, "miss": true, "ms": true, "dr": true, "prof": true,
+	"engr": true, "chief": true, "alhaji": true, "alhaja": true, "pastor": true, "rev": true,
+}
+
+// NamesMatch reports whether accountName, as returned by a bank, plausibly
+// belongs to the person called name. Word order, case, punctuation, titles
+// and extra middle names are ignored, initials match a word with the same
+// first letter and words of five or more letters may differ by one typo. At
+// least two words of name (or all of them, if it has fewer) must match, one
+// of them in full.
+func NamesMatch(name, accountName string) bool {
+	want := nameWords(name)
+	have := nameWords(accountName)
+	if len(want) == 0 || len(have) == 0 {
+		return false
+	}
+
+	used := make([]bool, len(have))
+	matched, full := 0, 0
+	for _, w := range want {
+		for j, h := range have {
+			if used[j] || !wordsMatch(w, h) {
+				continue
+			}
+			used[j] = true
+			matched++
+			if len(w) > 1 && len(h) > 1 {
+				full++
+			}
+			break
+		}
+	}
+
+	need := min(2, len(want), len(have))
+	return matched >= need && full > 0
+}
+
+// nameWords splits a name into lower case words without titles
+func nameWords(name string) [][]rune {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	words := make([][]rune, 0, len(fields))
+	for _, f := range fields {
+		if !nameTitles[f] {
+			words = append(words, []rune(f))
+		}
+	}
+	return words
+}
+
+func wordsMatch(a, b []rune) bool {
+	if len(a) == 1 || len(b) == 1 {
+		return a[0] == b[0]
+	}
+	if string(a) == string(b) {
+		return true
+	}
+	return min(len(a), len(b)) >= 5 && editDistance(a, b) <= 1
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,32 @@
+package utils
+
+import "testing"
+
+func TestNamesMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountName string
+		want        bool
+	}{
+		{"Ada Lovelace", "ADA LOVELACE", true},
+		{"Ada Lovelace", "LOVELACE ADA KING", true},
+		{"Chukwuemeka Okafor", "OKAFOR, CHUKWUEMEKA J.", true},
+		{"Mrs. Ngozi Eze", "EZE NGOZI", true},
+		{"Olumide Adeyemi", "ADEYEMMI OLUMIDE", true},
+		{"A. Lovelace", "LOVELACE ADA", true},
+		{"Ada", "ADA LOVELACE", true},
+		{"Ada Lovelace", "GRACE HOPPER", false},
+		{"Ada Lovelace", "ADA HOPPER", false},
+		{"A L", "ADA LOVELACE", false},
+		{"Emeka Okafor", "OKAFOR CHUKWUEMEKA", false},
+		{"Bola Ade", "BOLU ADE", false},
+		{"Ada Lovelace", "", false},
+		{"", "ADA LOVELACE", false},
+	}
+
+	for _, tt := range tests {
+		if got := NamesMatch(tt.name, tt.accountName); got != tt.want {
+			t.Errorf("NamesMatch(%q, %q) = %v, want %v", tt.name, tt.accountName, got, tt.want)
+		}
+	}
+}
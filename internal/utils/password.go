@@ -1,11 +1,52 @@
 package utils
 
 import (
+	"errors"
+	"unicode"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
 const bcryptCost = 12
 
+// Password policy. bcrypt ignores everything past 72 bytes, so longer
+// passwords are refused rather than silently truncated.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
+	ErrPasswordTooLong  = errors.New("password must be at most 72 bytes")
+	ErrPasswordTooWeak  = errors.New("password must contain at least one letter and one number")
+)
+
+// ValidatePassword checks a new password against the password policy
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return ErrPasswordTooWeak
+	}
+
+	return nil
+}
+
 // HashPassword generates a bcrypt hash of the password
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
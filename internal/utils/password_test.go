@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -77,3 +78,26 @@ func TestHashPasswordUniqueness(t *testing.T) {
 		t.Error("hash2 should validate")
 	}
 }
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{"valid", "password123", nil},
+		{"valid unicode", "пароль1234", nil},
+		{"too short", "pass12", ErrPasswordTooShort},
+		{"letters only", "passwordpassword", ErrPasswordTooWeak},
+		{"digits only", "1234567890", ErrPasswordTooWeak},
+		{"too long", strings.Repeat("a1", 37), ErrPasswordTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePassword(tt.password); err != tt.want {
+				t.Errorf("ValidatePassword() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
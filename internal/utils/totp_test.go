@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B vectors for SHA-1, truncated to six digits
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// base32 of the ASCII key "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %v, want %v", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+
+	now := time.Now()
+	code, _ := TOTPCode(secret, now)
+
+	if !ValidateTOTP(secret, code, now) {
+		t.Error("ValidateTOTP() should accept the current code")
+	}
+	if !ValidateTOTP(secret, code, now.Add(30*time.Second)) {
+		t.Error("ValidateTOTP() should accept a code one period old")
+	}
+	if ValidateTOTP(secret, code, now.Add(2*time.Minute)) {
+		t.Error("ValidateTOTP() should reject a stale code")
+	}
+	if ValidateTOTP(secret, "12345", now) {
+		t.Error("ValidateTOTP() should reject a short code")
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+
+	counter, ok := MatchTOTP(secret, "050471", now)
+	if !ok || counter != 37037037 {
+		t.Errorf("MatchTOTP() = %d, %v, want 37037037, true", counter, ok)
+	}
+
+	// A code from the previous period matches its own counter
+	counter, ok = MatchTOTP(secret, "050471", now.Add(30*time.Second))
+	if !ok || counter != 37037037 {
+		t.Errorf("MatchTOTP() one period later = %d, %v, want 37037037, true", counter, ok)
+	}
+
+	if _, ok := MatchTOTP(secret, "000000", now); ok {
+		t.Error("MatchTOTP() should reject a wrong code")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Cirvee", "admin@cirvee.com", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Cirvee:admin@cirvee.com?") {
+		t.Errorf("TOTPURI() = %v, unexpected label", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Cirvee") {
+		t.Errorf("TOTPURI() = %v, missing parameters", uri)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	sealed, err := Encrypt(key, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Error("Encrypt() leaked the plaintext")
+	}
+
+	opened, err := Decrypt(key, sealed)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Decrypt() = %v, want %v", opened, "JBSWY3DPEHPK3PXP")
+	}
+
+	if _, err := Decrypt([]byte("fedcba9876543210fedcba9876543210"), sealed); err != ErrInvalidCiphertext {
+		t.Errorf("Decrypt() with wrong key error = %v, want ErrInvalidCiphertext", err)
+	}
+	if _, err := Encrypt([]byte("short"), "x"); err != ErrInvalidKey {
+		t.Errorf("Encrypt() with short key error = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,3 @@
+-- Drop course catalog
+
+DROP TABLE IF EXISTS courses;
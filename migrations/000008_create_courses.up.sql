@@ -0,0 +1,24 @@
+-- Course catalog (replaces hardcoded course prices)
+
+CREATE TABLE IF NOT EXISTS courses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) UNIQUE NOT NULL,
+    price BIGINT NOT NULL CHECK (price > 0),
+    archived_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_courses_archived_at ON courses(archived_at);
+
+-- Seed with the prices previously hardcoded in the student handler
+INSERT INTO courses (name, price) VALUES
+    ('Web Development', 750000),
+    ('Data Science', 400000),
+    ('Mobile Development', 400000),
+    ('UI/UX Design', 350000),
+    ('Digital Marketing', 100000),
+    ('Cybersecurity', 400000),
+    ('Cloud Computing', 400000),
+    ('Machine Learning', 400000)
+ON CONFLICT (name) DO NOTHING;
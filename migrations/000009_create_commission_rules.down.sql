@@ -0,0 +1,4 @@
+-- Drop commission rules engine
+
+ALTER TABLE referrals DROP COLUMN IF EXISTS commission_rule_id;
+DROP TABLE IF EXISTS commission_rules;
@@ -0,0 +1,27 @@
+-- Commission rules engine
+
+CREATE TABLE IF NOT EXISTS commission_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    course_id UUID REFERENCES courses(id) ON DELETE RESTRICT,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('flat', 'percentage')),
+    -- Naira for flat rules, basis points (1/100 of a percent) for percentage rules
+    value BIGINT NOT NULL CHECK (value > 0),
+    cap BIGINT CHECK (cap IS NULL OR cap > 0),
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    effective_to TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (type != 'percentage' OR value <= 10000),
+    CHECK (effective_to IS NULL OR effective_to > effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_commission_rules_course_id ON commission_rules(course_id);
+CREATE INDEX IF NOT EXISTS idx_commission_rules_effective ON commission_rules(effective_from, effective_to);
+
+-- Record which rule produced each referral's earnings
+ALTER TABLE referrals ADD COLUMN commission_rule_id UUID REFERENCES commission_rules(id) ON DELETE RESTRICT;
+
+-- Preserve the previous flat commission as the default rule for all courses
+INSERT INTO commission_rules (name, course_id, type, value, effective_from)
+VALUES ('Default flat commission', NULL, 'flat', 10000, '2000-01-01T00:00:00Z');
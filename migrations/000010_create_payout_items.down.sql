@@ -0,0 +1,7 @@
+-- Drop payout items and release reserved referrals
+
+DROP TABLE IF EXISTS payout_items;
+
+UPDATE referrals SET status = 'pending' WHERE status = 'processing';
+ALTER TABLE referrals DROP CONSTRAINT referrals_status_check;
+ALTER TABLE referrals ADD CONSTRAINT referrals_status_check CHECK (status IN ('pending', 'paid', 'rejected'));
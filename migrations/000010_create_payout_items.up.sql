@@ -0,0 +1,17 @@
+-- Link payouts to the referrals they settle
+
+-- Referrals reserved by a pending payout are 'processing'
+ALTER TABLE referrals DROP CONSTRAINT referrals_status_check;
+ALTER TABLE referrals ADD CONSTRAINT referrals_status_check CHECK (status IN ('pending', 'processing', 'paid', 'rejected'));
+
+CREATE TABLE IF NOT EXISTS payout_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payout_id UUID NOT NULL REFERENCES payouts(id) ON DELETE CASCADE,
+    referral_id UUID NOT NULL REFERENCES referrals(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (payout_id, referral_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_items_payout_id ON payout_items(payout_id);
+CREATE INDEX IF NOT EXISTS idx_payout_items_referral_id ON payout_items(referral_id);
@@ -0,0 +1,98 @@
+-- Double-entry earnings ledger
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(100) UNIQUE NOT NULL,
+    type VARCHAR(30) NOT NULL CHECK (type IN ('referrer_payable', 'platform')),
+    -- No cascade: users with ledger history must not be deleted
+    user_id UUID UNIQUE REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK ((type = 'referrer_payable') = (user_id IS NOT NULL))
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(30) NOT NULL CHECK (kind IN ('commission_accrual', 'payout', 'clawback', 'adjustment')),
+    description TEXT NOT NULL,
+    reference_type VARCHAR(30),
+    reference_id UUID,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    -- Each referral/payout can only be accrued, paid or clawed back once
+    UNIQUE (kind, reference_type, reference_id)
+);
+
+-- Amounts are signed: positive debits, negative credits. Every entry sums to zero.
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    entry_id UUID NOT NULL REFERENCES ledger_entries(id),
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    amount BIGINT NOT NULL CHECK (amount != 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference ON ledger_entries(reference_type, reference_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_created_at ON ledger_entries(created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_entry_id ON ledger_postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account_id ON ledger_postings(account_id);
+
+-- Journal entries are immutable; corrections are new entries
+CREATE OR REPLACE FUNCTION ledger_prevent_mutation() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger records are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_immutable
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_prevent_mutation();
+
+CREATE TRIGGER ledger_postings_immutable
+    BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_prevent_mutation();
+
+-- Platform accounts
+INSERT INTO ledger_accounts (code, type) VALUES
+    ('platform:commission_expense', 'platform'),
+    ('platform:cash', 'platform'),
+    ('platform:adjustments', 'platform');
+
+-- Backfill opening balances from existing referrals
+INSERT INTO ledger_accounts (code, type, user_id)
+SELECT DISTINCT 'referrer:' || referrer_id::text, 'referrer_payable', referrer_id
+FROM referrals
+WHERE referrer_id IS NOT NULL;
+
+INSERT INTO ledger_entries (kind, description, reference_type, reference_id, created_at)
+SELECT 'commission_accrual', 'Opening balance: commission for ' || referred_name, 'referral', id, created_at
+FROM referrals
+WHERE referrer_id IS NOT NULL AND earnings > 0 AND status != 'rejected';
+
+INSERT INTO ledger_postings (entry_id, account_id, amount)
+SELECT e.id, a.id, -r.earnings
+FROM ledger_entries e
+JOIN referrals r ON r.id = e.reference_id
+JOIN ledger_accounts a ON a.user_id = r.referrer_id
+WHERE e.kind = 'commission_accrual'
+UNION ALL
+SELECT e.id, (SELECT id FROM ledger_accounts WHERE code = 'platform:commission_expense'), r.earnings
+FROM ledger_entries e
+JOIN referrals r ON r.id = e.reference_id
+WHERE e.kind = 'commission_accrual';
+
+INSERT INTO ledger_entries (kind, description, reference_type, reference_id, created_at)
+SELECT 'payout', 'Opening balance: paid commission for ' || referred_name, 'referral', id, created_at
+FROM referrals
+WHERE referrer_id IS NOT NULL AND earnings > 0 AND status = 'paid';
+
+INSERT INTO ledger_postings (entry_id, account_id, amount)
+SELECT e.id, a.id, r.earnings
+FROM ledger_entries e
+JOIN referrals r ON r.id = e.reference_id
+JOIN ledger_accounts a ON a.user_id = r.referrer_id
+WHERE e.kind = 'payout'
+UNION ALL
+SELECT e.id, (SELECT id FROM ledger_accounts WHERE code = 'platform:cash'), -r.earnings
+FROM ledger_entries e
+JOIN referrals r ON r.id = e.reference_id
+WHERE e.kind = 'payout';
@@ -0,0 +1,7 @@
+-- Remove Paystack transfer tracking
+
+ALTER TABLE users DROP COLUMN IF EXISTS paystack_recipient_code;
+
+ALTER TABLE payouts DROP COLUMN IF EXISTS transfer_status;
+ALTER TABLE payouts DROP COLUMN IF EXISTS transfer_code;
+ALTER TABLE payouts DROP COLUMN IF EXISTS transfer_reference;
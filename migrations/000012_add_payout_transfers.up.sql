@@ -0,0 +1,8 @@
+-- Track Paystack transfers for approved payouts
+
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS transfer_reference VARCHAR(100) UNIQUE;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS transfer_code VARCHAR(100);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS transfer_status VARCHAR(30);
+
+-- Cached transfer recipient; cleared whenever bank details change
+ALTER TABLE users ADD COLUMN IF NOT EXISTS paystack_recipient_code VARCHAR(100);
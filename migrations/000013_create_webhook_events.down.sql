@@ -0,0 +1,11 @@
+-- Drop webhook events
+
+-- Ledger entries are immutable, so existing reversals are left in place
+ALTER TABLE ledger_entries DROP CONSTRAINT ledger_entries_kind_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_kind_check CHECK (kind IN ('commission_accrual', 'payout', 'clawback', 'adjustment')) NOT VALID;
+
+UPDATE payouts SET status = 'rejected' WHERE status IN ('failed', 'reversed');
+ALTER TABLE payouts DROP CONSTRAINT payouts_status_check;
+ALTER TABLE payouts ADD CONSTRAINT payouts_status_check CHECK (status IN ('pending', 'approved', 'rejected'));
+
+DROP TABLE IF EXISTS webhook_events;
@@ -0,0 +1,20 @@
+-- Inbound webhook events, deduplicated per provider
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR(30) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_event_type ON webhook_events(event_type);
+
+-- Payouts whose transfer failed or was reversed by the bank
+ALTER TABLE payouts DROP CONSTRAINT payouts_status_check;
+ALTER TABLE payouts ADD CONSTRAINT payouts_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'failed', 'reversed'));
+
+ALTER TABLE ledger_entries DROP CONSTRAINT ledger_entries_kind_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_kind_check CHECK (kind IN ('commission_accrual', 'payout', 'payout_reversal', 'clawback', 'adjustment'));
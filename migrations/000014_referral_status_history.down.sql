@@ -0,0 +1,12 @@
+-- Drop referral status history and the approved/reversed statuses
+
+DROP TRIGGER IF EXISTS referrals_status_history ON referrals;
+DROP FUNCTION IF EXISTS record_referral_status_change();
+DROP TABLE IF EXISTS referral_status_history;
+
+ALTER TABLE payout_items DROP COLUMN IF EXISTS referral_status;
+
+UPDATE referrals SET status = 'pending' WHERE status = 'approved';
+UPDATE referrals SET status = 'rejected' WHERE status = 'reversed';
+ALTER TABLE referrals DROP CONSTRAINT referrals_status_check;
+ALTER TABLE referrals ADD CONSTRAINT referrals_status_check CHECK (status IN ('pending', 'processing', 'paid', 'rejected'));
@@ -0,0 +1,36 @@
+-- Referral status state machine: approval and post-payment reversal, plus an
+-- audit trail of every status change
+
+ALTER TABLE referrals DROP CONSTRAINT referrals_status_check;
+ALTER TABLE referrals ADD CONSTRAINT referrals_status_check CHECK (status IN ('pending', 'approved', 'processing', 'paid', 'rejected', 'reversed'));
+
+-- Status a referral had when a payout reserved it, restored if the payout is
+-- rejected or its transfer fails
+ALTER TABLE payout_items ADD COLUMN IF NOT EXISTS referral_status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS referral_status_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    referral_id UUID NOT NULL REFERENCES referrals(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    -- NULL when the change was made by the system (e.g. a payout webhook)
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_status_history_referral_id ON referral_status_history(referral_id, created_at);
+
+-- The acting user is passed per transaction with set_config('app.actor_id', ...)
+CREATE OR REPLACE FUNCTION record_referral_status_change() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO referral_status_history (referral_id, from_status, to_status, changed_by)
+    VALUES (NEW.id, OLD.status, NEW.status, NULLIF(current_setting('app.actor_id', true), '')::uuid);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER referrals_status_history
+    AFTER UPDATE OF status ON referrals
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION record_referral_status_change();
@@ -0,0 +1,3 @@
+-- Drop admin audit trail
+
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,3 @@
+-- Drop refresh token tracking
+
+DROP TABLE IF EXISTS refresh_tokens;
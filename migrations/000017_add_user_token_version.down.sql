@@ -0,0 +1,3 @@
+-- Drop user token version
+
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
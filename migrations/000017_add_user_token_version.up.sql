@@ -0,0 +1,4 @@
+-- Access tokens carry the user's token version; bumping it invalidates every
+-- access token issued before
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
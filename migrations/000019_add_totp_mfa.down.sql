@@ -0,0 +1,6 @@
+-- Remove TOTP two-factor authentication
+
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret_encrypted;
@@ -0,0 +1,3 @@
+-- Drop login history
+
+DROP TABLE IF EXISTS login_history;
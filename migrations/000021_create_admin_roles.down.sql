@@ -0,0 +1,8 @@
+-- Drop admin roles and permissions
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_admin_role_check;
+ALTER TABLE users DROP COLUMN IF EXISTS admin_role;
+
+DROP TABLE IF EXISTS admin_role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS admin_roles;
@@ -0,0 +1,59 @@
+-- Role-based access control for admins. users.role still tells admins from
+-- referrers; an admin's admin_role decides what they may do.
+
+CREATE TABLE IF NOT EXISTS admin_roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS admin_role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES admin_roles(name) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO admin_roles (name, description) VALUES
+    ('super_admin', 'Full access, including managing other admins'),
+    ('finance', 'Approves payouts, settles referrals and adjusts balances'),
+    ('support', 'Looks up referrers, students and payouts and blocks accounts')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('reports:read', 'View the dashboard, referrals, referrers, students, courses and commission rules'),
+    ('referrals:write', 'Change referral status and mark referrals and referrers paid'),
+    ('payouts:read', 'View payout requests'),
+    ('payouts:approve', 'Approve or reject payout requests'),
+    ('users:block', 'Block and unblock accounts'),
+    ('catalog:write', 'Manage courses and commission rules'),
+    ('ledger:adjust', 'Post manual ledger adjustments'),
+    ('audit:read', 'View the admin audit log')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO admin_role_permissions (role, permission)
+SELECT 'super_admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO admin_role_permissions (role, permission) VALUES
+    ('finance', 'reports:read'),
+    ('finance', 'referrals:write'),
+    ('finance', 'payouts:read'),
+    ('finance', 'payouts:approve'),
+    ('finance', 'ledger:adjust'),
+    ('support', 'reports:read'),
+    ('support', 'payouts:read'),
+    ('support', 'users:block')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS admin_role VARCHAR(50) REFERENCES admin_roles(name);
+
+-- Existing admins keep the full access they had
+UPDATE users SET admin_role = 'super_admin' WHERE role = 'admin' AND admin_role IS NULL;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_admin_role_check;
+ALTER TABLE users ADD CONSTRAINT users_admin_role_check CHECK ((role = 'admin') = (admin_role IS NOT NULL));
@@ -0,0 +1,6 @@
+-- Drop admin invitations
+
+DROP TABLE IF EXISTS admin_invites;
+
+DELETE FROM admin_role_permissions WHERE permission = 'admins:manage';
+DELETE FROM permissions WHERE name = 'admins:manage';
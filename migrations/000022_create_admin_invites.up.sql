@@ -0,0 +1,24 @@
+-- Admin invitations. An invite is a one-time link that lets the invitee set
+-- a password and join with the role chosen by the inviter.
+
+INSERT INTO permissions (name, description) VALUES
+    ('admins:manage', 'Invite admins and change or disable their access')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO admin_role_permissions (role, permission) VALUES
+    ('super_admin', 'admins:manage')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS admin_invites (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email VARCHAR(255) NOT NULL,
+    admin_role VARCHAR(50) NOT NULL REFERENCES admin_roles(name),
+    token VARCHAR(255) UNIQUE NOT NULL,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_invites_token ON admin_invites(token);
+CREATE INDEX IF NOT EXISTS idx_admin_invites_email ON admin_invites(email);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_bank_name_mismatch;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS bank_name_mismatch,
+    DROP COLUMN IF EXISTS bank_verified_at,
+    DROP COLUMN IF EXISTS bank_code;
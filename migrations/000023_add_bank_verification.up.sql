@@ -0,0 +1,9 @@
+-- Bank details are verified with Paystack when a user sets them. bank_code
+-- identifies the bank; bank_name is kept for display and exports. Accounts
+-- whose holder name does not match the user's name are flagged for review.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS bank_code VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bank_verified_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS bank_name_mismatch BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_bank_name_mismatch ON users(bank_name_mismatch) WHERE bank_name_mismatch;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS bank_hold_until;
+
+DROP TABLE IF EXISTS bank_detail_changes;
@@ -0,0 +1,29 @@
+-- Bank detail changes only take effect once confirmed from a link emailed to
+-- the account's address. Each request is kept as a record of who changed
+-- what, and when.
+CREATE TABLE IF NOT EXISTS bank_detail_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bank_code VARCHAR(20) NOT NULL,
+    bank_name VARCHAR(100) NOT NULL,
+    account_number VARCHAR(20) NOT NULL,
+    account_name VARCHAR(255) NOT NULL,
+    bank_name_mismatch BOOLEAN NOT NULL DEFAULT FALSE,
+    -- The details replaced, filled in on confirmation
+    previous_bank_name VARCHAR(100),
+    previous_account_number VARCHAR(20),
+    previous_account_name VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'cancelled')),
+    -- Cleared once the change is confirmed or cancelled
+    token VARCHAR(255) UNIQUE,
+    ip_address VARCHAR(45),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    hold_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_detail_changes_user ON bank_detail_changes(user_id, created_at DESC);
+
+-- Payouts to a newly confirmed account are held until this time
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bank_hold_until TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,8 @@
+-- Recipients created with another provider mean nothing to Paystack
+UPDATE users SET payout_recipient_code = NULL
+WHERE payout_recipient_provider IS DISTINCT FROM 'paystack';
+
+ALTER TABLE payouts DROP COLUMN IF EXISTS transfer_provider;
+
+ALTER TABLE users DROP COLUMN IF EXISTS payout_recipient_provider;
+ALTER TABLE users RENAME COLUMN payout_recipient_code TO paystack_recipient_code;
@@ -0,0 +1,9 @@
+-- Payouts can be sent through more than one provider, so cached recipients
+-- and initiated transfers record which provider they belong to
+
+ALTER TABLE users RENAME COLUMN paystack_recipient_code TO payout_recipient_code;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS payout_recipient_provider VARCHAR(30);
+UPDATE users SET payout_recipient_provider = 'paystack' WHERE payout_recipient_code IS NOT NULL;
+
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS transfer_provider VARCHAR(30);
+UPDATE payouts SET transfer_provider = 'paystack' WHERE transfer_reference IS NOT NULL;
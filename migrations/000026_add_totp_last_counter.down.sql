@@ -0,0 +1,3 @@
+-- Remove the record of used authenticator codes
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_counter;
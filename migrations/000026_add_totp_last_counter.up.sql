@@ -0,0 +1,4 @@
+-- The time step of the last authenticator code accepted for each user, so a
+-- code cannot be used twice
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_counter BIGINT;
@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -214,3 +216,90 @@ func TestProtectedRoutes(t *testing.T) {
 		})
 	}
 }
+
+// postJSON sends an authenticated JSON POST and returns the recorder
+func postJSON(ts http.Handler, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestTwoFactorLogin enrols a user in TOTP and logs in with a code and then
+// with a recovery code
+func TestTwoFactorLogin(t *testing.T) {
+	ts, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	token := registerAndLogin(t, ts, "mfa@example.com", "password123", "MFA User")
+
+	rr := postJSON(ts, "/api/v1/auth/2fa/setup", token, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var setup models.MFASetupResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &setup))
+	assert.Contains(t, setup.OTPAuthURI, "otpauth://totp/")
+
+	rr = postJSON(ts, "/api/v1/auth/2fa/enable", token, models.MFACodeRequest{Code: "000000"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	code, err := utils.TOTPCode(setup.Secret, time.Now())
+	require.NoError(t, err)
+	rr = postJSON(ts, "/api/v1/auth/2fa/enable", token, models.MFACodeRequest{Code: code})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var enabled models.MFAEnableResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &enabled))
+	require.Len(t, enabled.RecoveryCodes, 10)
+
+	login := func() models.MFAChallengeResponse {
+		rr := postJSON(ts, "/api/v1/auth/login", "", models.LoginRequest{Email: "mfa@example.com", Password: "password123"})
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		var challenge models.MFAChallengeResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &challenge))
+		require.True(t, challenge.MFARequired)
+		return challenge
+	}
+
+	// Authenticator code. The one that enabled 2FA is used up, so the next
+	// period's code is sent.
+	challenge := login()
+	rr = postJSON(ts, "/api/v1/auth/2fa/verify", "", models.MFAVerifyRequest{ChallengeToken: challenge.ChallengeToken, Code: code})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	code, err = utils.TOTPCode(setup.Secret, time.Now().Add(30*time.Second))
+	require.NoError(t, err)
+	rr = postJSON(ts, "/api/v1/auth/2fa/verify", "", models.MFAVerifyRequest{ChallengeToken: challenge.ChallengeToken, Code: code})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var auth models.AuthResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &auth))
+	assert.NotEmpty(t, auth.AccessToken)
+
+	// Neither the code nor the challenge can be replayed
+	rr = postJSON(ts, "/api/v1/auth/2fa/verify", "", models.MFAVerifyRequest{ChallengeToken: login().ChallengeToken, Code: code})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = postJSON(ts, "/api/v1/auth/2fa/verify", "", models.MFAVerifyRequest{ChallengeToken: challenge.ChallengeToken, Code: enabled.RecoveryCodes[2]})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// The challenge token is not an access token
+	req := httptest.NewRequest("GET", "/api/v1/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+challenge.ChallengeToken)
+	rr = httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Recovery codes work once
+	recovery := enabled.RecoveryCodes[0]
+	rr = postJSON(ts, "/api/v1/auth/2fa/verify", "", models.MFAVerifyRequest{ChallengeToken: login().ChallengeToken, Code: recovery})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = postJSON(ts, "/api/v1/auth/2fa/verify", "", models.MFAVerifyRequest{ChallengeToken: login().ChallengeToken, Code: recovery})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Users can turn it off again
+	rr = postJSON(ts, "/api/v1/auth/2fa/disable", auth.AccessToken, models.MFACodeRequest{Code: enabled.RecoveryCodes[1]})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = postJSON(ts, "/api/v1/auth/login", "", models.LoginRequest{Email: "mfa@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
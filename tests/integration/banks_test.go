@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cirvee/referral-backend/internal/handlers"
+	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListBanks(t *testing.T) {
+	ts, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("GET", "/api/v1/banks", nil)
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response handlers.BankListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response.Data, len(payouts.FakeBanks))
+}
+
+func TestResolveBankAccount(t *testing.T) {
+	ts, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	testPayouts.AddAccount("058", "0123456789", "ADA LOVELACE")
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"known account", "account_number=0123456789&bank_code=058", http.StatusOK},
+		{"unknown account", "account_number=9999999999&bank_code=058", http.StatusBadRequest},
+		{"wrong bank", "account_number=0123456789&bank_code=044", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/banks/resolve?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			ts.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.code, rr.Code, rr.Body.String())
+			if tt.code == http.StatusOK {
+				var response handlers.ResolveAccountResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+				assert.Equal(t, "ADA LOVELACE", response.Data.AccountName)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileBankDetails(t *testing.T) {
+	ts, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	token := registerAndLogin(t, ts, "bankdetails@example.com", "password123", "Grace Hopper")
+	testPayouts.AddAccount("058", "1111111111", "GRACE HOPPER")
+
+	body, _ := json.Marshal(models.UpdateProfileRequest{BankCode: "058", AccountNumber: "1111111111"})
+	req := httptest.NewRequest("PATCH", "/api/v1/user/profile", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	ts.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var response struct {
+		PendingBankChange *models.BankDetailChange `json:"pending_bank_change"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.NotNil(t, response.PendingBankChange)
+	assert.Equal(t, "Guaranty Trust Bank", response.PendingBankChange.BankName)
+	assert.Equal(t, "GRACE HOPPER", response.PendingBankChange.AccountName)
+	assert.False(t, response.PendingBankChange.BankNameMismatch)
+}
@@ -7,18 +7,31 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cirvee/referral-backend/internal/banks"
 	"github.com/cirvee/referral-backend/internal/cache"
 	"github.com/cirvee/referral-backend/internal/config"
 	"github.com/cirvee/referral-backend/internal/database"
 	"github.com/cirvee/referral-backend/internal/handlers"
 	"github.com/cirvee/referral-backend/internal/middleware"
 	"github.com/cirvee/referral-backend/internal/models"
+	"github.com/cirvee/referral-backend/internal/payouts"
 	"github.com/cirvee/referral-backend/internal/repository"
 	"github.com/cirvee/referral-backend/internal/services"
 	"github.com/cirvee/referral-backend/internal/utils"
 	"github.com/go-chi/chi/v5"
 )
 
+// testDB is the database behind the most recent setupTestServer, for tests
+// that need to arrange state the API cannot
+var testDB *database.DB
+
+// testPayouts is the payout provider behind the most recent setupTestServer,
+// so tests can add bank accounts and settle transfers
+var testPayouts *payouts.Fake
+
+// testMFAKey encrypts TOTP secrets in tests
+var testMFAKey = []byte("0123456789abcdef0123456789abcdef")
+
 func setupTestServer(t *testing.T) (http.Handler, func()) {
 	// Use test database URL
 	dbURL := os.Getenv("DATABASE_TEST_URL")
@@ -37,6 +50,9 @@ func setupTestServer(t *testing.T) (http.Handler, func()) {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
+	testDB = db
+	testPayouts = payouts.NewFake()
+
 	// Connect to Redis
 	redisCache, err := cache.New(redisURL)
 	if err != nil {
@@ -62,20 +78,35 @@ func setupTestServer(t *testing.T) (http.Handler, func()) {
 	referralRepo := repository.NewReferralRepository(db, redisCache)
 	payoutRepo := repository.NewPayoutRepository(db)
 	clickRepo := repository.NewClickRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(db)
 
 	// Services
-	authService := services.NewAuthService(userRepo, jwtManager)
+	accessService := services.NewAccessService(userRepo, redisCache)
+	mfaService := services.NewMFAService(repository.NewMFARepository(db), redisCache, testMFAKey, "Cirvee Test")
 	// Stub email service for testing (won't actually send emails)
 	emailService := services.NewEmailService(&config.SMTPConfig{})
+	// Login history is recorded but not throttled, so tests can fail logins freely
+	loginSecurityService := services.NewLoginSecurityService(nil, loginHistoryRepo, emailService)
+	authService := services.NewAuthService(userRepo, repository.NewRefreshTokenRepository(db), accessService, mfaService, loginSecurityService, jwtManager)
+	ledgerService := services.NewLedgerService(ledgerRepo, userRepo)
+	bankDirectory := banks.NewDirectory(testPayouts, nil)
+	payoutService := services.NewPayoutService(payoutRepo, referralRepo, userRepo, ledgerService, testPayouts)
 
 	// Handlers
-	authHandler := handlers.NewAuthHandler(authService, emailService, userRepo, nil)
-	adminHandler := handlers.NewAdminHandler(userRepo, referralRepo, payoutRepo)
-	userHandler := handlers.NewUserHandler(userRepo, referralRepo, clickRepo)
+	authHandler := handlers.NewAuthHandler(authService, emailService, userRepo, nil, repository.NewVerificationTokenRepository(db))
+	adminHandler := handlers.NewAdminHandler(userRepo, referralRepo, payoutRepo, payoutService, ledgerService, services.NewReferralService(referralRepo, ledgerService), accessService)
+	userHandler := handlers.NewUserHandler(userRepo, referralRepo, clickRepo, ledgerRepo, services.NewBankAccountService(repository.NewBankChangeRepository(db), userRepo, testPayouts, bankDirectory, emailService, false, 0))
+	bankHandler := handlers.NewBankHandler(testPayouts, bankDirectory)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	securityHandler := handlers.NewSecurityHandler(loginHistoryRepo)
 	healthHandler := handlers.NewHealthHandler(db, redisCache)
 
 	// Middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtManager)
+	authMiddleware := middleware.NewAuthMiddleware(jwtManager, accessService)
+	auditMiddleware := middleware.NewAuditMiddleware(auditRepo)
 
 	// Router
 	r := chi.NewRouter()
@@ -88,27 +119,51 @@ func setupTestServer(t *testing.T) (http.Handler, func()) {
 			r.Post("/register", authHandler.Register)
 			r.Post("/login", authHandler.Login)
 			r.Post("/refresh", authHandler.RefreshToken)
+			r.Post("/logout", authHandler.Logout)
+			r.With(authMiddleware.Authenticate).Post("/logout-all", authHandler.LogoutAll)
+			r.Post("/verify-email", authHandler.VerifyEmail)
+			r.Post("/resend-verification", authHandler.ResendVerification)
+			r.Post("/2fa/verify", authHandler.VerifyMFA)
+			r.With(authMiddleware.Authenticate).Post("/2fa/setup", mfaHandler.Setup)
+			r.With(authMiddleware.Authenticate).Post("/2fa/enable", mfaHandler.Enable)
+			r.With(authMiddleware.Authenticate).Post("/2fa/disable", mfaHandler.Disable)
+		})
+
+		r.Route("/banks", func(r chi.Router) {
+			r.Get("/", bankHandler.ListBanks)
+			r.Get("/resolve", bankHandler.ResolveAccount)
 		})
 
 		r.Route("/admin", func(r chi.Router) {
 			r.Use(authMiddleware.Authenticate)
 			r.Use(authMiddleware.RequireRole(models.RoleAdmin))
-
-			r.Get("/dashboard", adminHandler.GetDashboard)
-			r.Get("/referrals", adminHandler.GetReferrals)
-			r.Get("/students", adminHandler.GetStudents)
-			r.Get("/payouts", adminHandler.GetPayouts)
-			r.Patch("/payouts/{id}", adminHandler.UpdatePayoutStatus)
+			r.Use(authMiddleware.RequireMFA)
+			r.Use(auditMiddleware.Record)
+
+			can := authMiddleware.RequirePermission
+
+			r.With(can(models.PermReportsRead)).Get("/dashboard", adminHandler.GetDashboard)
+			r.With(can(models.PermReportsRead)).Get("/referrals", adminHandler.GetReferrals)
+			r.With(can(models.PermReportsRead)).Get("/students", adminHandler.GetStudents)
+			r.With(can(models.PermPayoutsRead)).Get("/payouts", adminHandler.GetPayouts)
+			r.With(can(models.PermPayoutsApprove)).Patch("/payouts/{id}", adminHandler.UpdatePayoutStatus)
+			r.With(can(models.PermPayoutsApprove)).Post("/payouts/{id}/sync-transfer", adminHandler.SyncPayoutTransfer)
+			r.With(can(models.PermAuditRead)).Get("/audit", auditHandler.ListEvents)
 		})
 
 		r.Route("/user", func(r chi.Router) {
 			r.Use(authMiddleware.Authenticate)
-			r.Use(authMiddleware.RequireRole(models.RoleUser))
+			r.Patch("/password", authHandler.ChangePassword)
+			r.Get("/security/logins", securityHandler.GetLogins)
+
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequireRole(models.RoleUser))
 
-			r.Get("/dashboard", userHandler.GetDashboard)
-			r.Get("/referrals", userHandler.GetMyReferrals)
-			r.Get("/profile", userHandler.GetProfile)
-			r.Patch("/profile", userHandler.UpdateProfile)
+				r.With(authMiddleware.RequireVerifiedEmail).Get("/dashboard", userHandler.GetDashboard)
+				r.Get("/referrals", userHandler.GetMyReferrals)
+				r.Get("/profile", userHandler.GetProfile)
+				r.Patch("/profile", userHandler.UpdateProfile)
+			})
 		})
 	})
 
@@ -119,3 +174,12 @@ func setupTestServer(t *testing.T) (http.Handler, func()) {
 
 	return r, cleanup
 }
+
+// markEmailVerified confirms a user's email address as if they had followed
+// the verification link
+func markEmailVerified(t *testing.T, email string) {
+	_, err := testDB.Pool.Exec(context.Background(), "UPDATE users SET email_verified_at = NOW() WHERE email = $1", email)
+	if err != nil {
+		t.Fatalf("Failed to verify email: %v", err)
+	}
+}